@@ -7,11 +7,10 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"backend/internal/app"
 	"backend/internal/config"
 	"backend/internal/db"
-	"backend/internal/llm"
 	"backend/internal/router"
-	"backend/internal/service"
 )
 
 func main() {
@@ -31,25 +30,36 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	if _, err := db.Init(cfg.DB); err != nil {
-		log.Fatalf("数据库连接失败: %v", err)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		dbx, err := db.Open(cfg.DB)
+		if err != nil {
+			log.Fatalf("数据库连接失败: %v", err)
+		}
+		defer dbx.Close()
+		if err := runMigrate(dbx, db.DialectOf(cfg.DB), os.Args[2:]); err != nil {
+			log.Fatalf("数据库迁移失败: %v", err)
+		}
+		return
 	}
 
-	if err := service.EnsureAdmin(context.Background(), cfg.Admin); err != nil {
-		log.Fatalf("admin init failed: %v", err)
+	a, err := app.New(cfg)
+	if err != nil {
+		log.Fatalf("初始化失败: %v", err)
 	}
+	defer a.Close()
 
-	service.InitDashscope(cfg.Dashscope)
-
-	if err := llm.Init(cfg.LLM); err != nil {
-		log.Fatalf("LLM 初始化失败: %v", err)
+	if err := a.Service.EnsureAdmin(context.Background(), cfg.Admin); err != nil {
+		log.Fatalf("admin init failed: %v", err)
 	}
 
-	if err := service.InitOSS(cfg.OSS); err != nil {
-		log.Fatalf("OSS init failed: %v", err)
-	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.Service.RunRetention(ctx)
 
-	r := router.NewRouter(cfg)
+	r, err := router.NewRouter(a)
+	if err != nil {
+		log.Fatalf("初始化失败: %v", err)
+	}
 
 	if err := r.Run(cfg.Server.Addr); err != nil {
 		log.Fatalf("服务启动失败: %v", err)
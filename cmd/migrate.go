@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"backend/internal/db"
+)
+
+// runMigrate 处理 `migrate up|down [n]|status|resolve <version> applied|reverted` 子命令。
+func runMigrate(dbx *sql.DB, dialect string, args []string) error {
+	ctx := context.Background()
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up | down [steps] | status | resolve <version> applied|reverted")
+	}
+	switch args[0] {
+	case "up":
+		n, err := db.MigrateUp(ctx, dbx, dialect)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("applied %d migration(s)\n", n)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			v, err := strconv.Atoi(args[1])
+			if err != nil || v <= 0 {
+				return fmt.Errorf("invalid steps: %s", args[1])
+			}
+			steps = v
+		}
+		n, err := db.MigrateDown(ctx, dbx, dialect, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("rolled back %d migration(s)\n", n)
+	case "status":
+		statuses, err := db.MigrationStatuses(ctx, dbx, dialect)
+		if err != nil {
+			return err
+		}
+		for _, st := range statuses {
+			state := "pending"
+			if st.Applied {
+				state = "applied " + st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if st.Dirty {
+				state = "dirty"
+			}
+			fmt.Printf("%04d_%s\t%s\n", st.Version, st.Name, state)
+		}
+	case "resolve":
+		if len(args) != 3 || (args[2] != "applied" && args[2] != "reverted") {
+			return fmt.Errorf("usage: migrate resolve <version> applied|reverted")
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version: %s", args[1])
+		}
+		if err := db.ResolveDirty(ctx, dbx, version, args[2] == "applied"); err != nil {
+			return err
+		}
+		fmt.Printf("migration %04d marked %s\n", version, args[2])
+	default:
+		return fmt.Errorf("unknown migrate command: %s", args[0])
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"backend/internal/config"
+	"backend/internal/db"
+	"backend/internal/jwtauth"
+	"backend/internal/llm"
+	"backend/internal/loginguard"
+	"backend/internal/passwordpolicy"
+	"backend/internal/service"
+	"backend/internal/store"
+)
+
+// App 应用容器，持有配置、数据库连接、模型目录、对象存储、语音客户端与 JWT 密钥，
+// 各实例互不共享状态，可在同一进程内以不同配置并存。
+type App struct {
+	Config  *config.Config
+	DB      *sql.DB
+	Dialect string
+	Store   store.Store
+	LLM     *llm.Registry
+	OSS     *service.OSSStorage
+	Speech  *service.Dashscope
+	Tokens  *jwtauth.Manager
+	// LoginGuard 登录失败计数，默认使用进程内存储；禁用时为 nil。
+	LoginGuard *loginguard.Guard
+	Service    *service.Service
+}
+
+// New 按配置创建 App：打开数据库（开启 auto_migrate 时执行迁移）并初始化各客户端。
+func New(cfg *config.Config) (*App, error) {
+	dbx, err := db.Open(cfg.DB)
+	if err != nil {
+		return nil, fmt.Errorf("open db: %w", err)
+	}
+	dialect := db.DialectOf(cfg.DB)
+	if cfg.DB.AutoMigrate {
+		n, err := db.MigrateUp(context.Background(), dbx, dialect)
+		if err != nil {
+			_ = dbx.Close()
+			return nil, fmt.Errorf("migrate: %w", err)
+		}
+		if n > 0 {
+			log.Printf("applied %d migration(s)", n)
+		}
+	}
+
+	a, err := NewWithStore(cfg, store.NewSQLStore(dbx))
+	if err != nil {
+		_ = dbx.Close()
+		return nil, err
+	}
+	a.DB = dbx
+	a.Dialect = dialect
+	return a, nil
+}
+
+// NewWithStore 使用给定存储创建 App，不打开数据库，便于以 memstore 测试。
+func NewWithStore(cfg *config.Config, st store.Store) (*App, error) {
+	models, err := llm.NewRegistry(cfg.LLM)
+	if err != nil {
+		return nil, fmt.Errorf("init llm: %w", err)
+	}
+	ossStorage, err := service.NewOSS(cfg.OSS)
+	if err != nil {
+		return nil, fmt.Errorf("init oss: %w", err)
+	}
+	speech := service.NewDashscope(cfg.Dashscope)
+	tokens, err := jwtauth.New(cfg.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("init auth: %w", err)
+	}
+	guard := loginguard.New(cfg.Auth.LoginGuard, loginguard.NewMemoryStore())
+	passwords, err := passwordpolicy.New(cfg.Auth.PasswordPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("init password policy: %w", err)
+	}
+
+	return &App{
+		Config:     cfg,
+		Store:      st,
+		LLM:        models,
+		OSS:        ossStorage,
+		Speech:     speech,
+		Tokens:     tokens,
+		LoginGuard: guard,
+		Service: service.New(service.Deps{
+			Store:          st,
+			Models:         models,
+			OSS:            ossStorage,
+			Speech:         speech,
+			Tokens:         tokens,
+			Auth:           cfg.Auth,
+			LoginGuard:     guard,
+			PasswordPolicy: passwords,
+			Summary:        cfg.LLM.Summary,
+			Title:          cfg.LLM.Title,
+			Retention:      cfg.Retention,
+		}),
+	}, nil
+}
+
+// Close 释放 App 持有的资源。
+func (a *App) Close() error {
+	if a.DB == nil {
+		return nil
+	}
+	return a.DB.Close()
+}
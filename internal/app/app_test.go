@@ -0,0 +1,54 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"backend/internal/config"
+	"backend/internal/store/memstore"
+)
+
+func TestNewWithStore(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.Config{
+		Auth: config.AuthConfig{Secret: "test-secret"},
+		LLM: config.LLMConfig{
+			Models: []config.LLMModelConfig{{Name: "fake", Provider: "fake", Model: "fake"}},
+			Title:  config.TitleConfig{Disabled: true},
+		},
+	}
+	a, err := NewWithStore(cfg, memstore.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	if a.DB != nil || a.LLM.DefaultModel() != "fake" || a.LoginGuard == nil {
+		t.Fatalf("app = %+v", a)
+	}
+
+	user, err := a.Service.CreateUser(ctx, "alice", "Str0ng-pass", "alice", "user", 1000, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair, err := a.Service.Login(ctx, "alice", "Str0ng-pass", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := a.Tokens.Parse(pair.AccessToken)
+	if err != nil || claims.UserID != user.UserID {
+		t.Fatalf("claims = %+v, err %v", claims, err)
+	}
+
+	conv, err := a.Service.NewConversation(ctx, user.UserID, "chat", sql.NullInt64{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := a.Service.SendMessage(ctx, user.UserID, conv.ConversationID, "TEXT", "hi", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Reply != "echo: hi" {
+		t.Errorf("reply = %q", result.Reply)
+	}
+}
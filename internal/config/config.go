@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -14,29 +16,88 @@ type Config struct {
 	OSS       OSSConfig       `yaml:"oss"`
 	Admin     AdminConfig     `yaml:"admin"`
 	Dashscope DashscopeConfig `yaml:"dashscope"`
+	Auth      AuthConfig      `yaml:"auth"`
+	Retention RetentionConfig `yaml:"retention"`
 }
 
 type ServerConfig struct {
 	Addr  string `yaml:"addr"`
 	Debug bool   `yaml:"debug"`
+	// TrustedProxies 可信反向代理的 IP 或 CIDR，仅来自这些地址的 X-Forwarded-For 用于确定客户端 IP；
+	// 为空时不信任任何代理，客户端 IP 取连接的对端地址。
+	TrustedProxies []string `yaml:"trusted_proxies"`
 }
 
+// LLMConfig 模型配置。顶层字段描述单个模型（兼容旧配置）；配置 models 后以其为模型目录，default_model 指定默认模型。
 type LLMConfig struct {
-	BaseURL string `yaml:"base_url"`
-	APIKey  string `yaml:"api_key"`
-	AK      string `yaml:"ak"`
-	SK      string `yaml:"sk"`
-	Region  string `yaml:"region"`
-	Model   string `yaml:"model"`
+	LLMModelConfig `yaml:",inline"`
+	DefaultModel   string           `yaml:"default_model"`
+	Models         []LLMModelConfig `yaml:"models"`
+	Summary        SummaryConfig    `yaml:"summary"`
+	Title          TitleConfig      `yaml:"title"`
+}
+
+// SummaryConfig 会话滚动摘要配置。
+type SummaryConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// TriggerMessages 未被摘要覆盖的消息数达到该值时触发摘要，默认 20。
+	TriggerMessages int `yaml:"trigger_messages"`
+	// KeepRecent 摘要时保留原文的最新消息数，默认 6。
+	KeepRecent int `yaml:"keep_recent"`
+}
+
+// TitleConfig 会话自动命名配置：新建会话未提供标题时，首条回复后在后台由模型生成标题。
+type TitleConfig struct {
+	Disabled bool `yaml:"disabled"`
+	// MaxLength 标题最大字符数，默认 30。
+	MaxLength int `yaml:"max_length"`
+}
+
+// LLMModelConfig 单个模型的接入配置。
+type LLMModelConfig struct {
+	// Name 对外展示与会话中保存的模型名，缺省为 Model。
+	Name string `yaml:"name"`
+	// Provider 可选 ark（默认）、openai（OpenAI 兼容端点）、fake（进程内假实现）。
+	Provider string `yaml:"provider"`
+	BaseURL  string `yaml:"base_url"`
+	APIKey   string `yaml:"api_key"`
+	AK       string `yaml:"ak"`
+	SK       string `yaml:"sk"`
+	Region   string `yaml:"region"`
+	// Model 上游模型名（或 Ark endpoint ID）。
+	Model         string `yaml:"model"`
+	ContextWindow int    `yaml:"context_window"`
+	// ContextBudget 发送给模型的上下文 token 上限，缺省为 context_window 的 3/4（为回复预留空间），两者均为 0 时不截断。
+	ContextBudget int  `yaml:"context_budget"`
+	Multimodal    bool `yaml:"multimodal"`
+	// AllowedRoles 可使用该模型的角色，为空表示所有角色。
+	AllowedRoles []string `yaml:"allowed_roles"`
+}
+
+// Catalog 返回模型目录：未配置 models 时以顶层字段作为唯一模型。
+func (l LLMConfig) Catalog() []LLMModelConfig {
+	if len(l.Models) > 0 {
+		return l.Models
+	}
+	if l.Model == "" {
+		return nil
+	}
+	return []LLMModelConfig{l.LLMModelConfig}
 }
 
 type DatabaseConfig struct {
+	// Driver 可选 mysql（默认）或 sqlite（纯 Go 驱动，无需数据库服务）。
+	Driver string `yaml:"driver"`
+	// Path sqlite 数据库文件路径，":memory:" 表示进程内内存库。
+	Path     string `yaml:"path"`
 	Host     string `yaml:"host"`
 	Port     int    `yaml:"port"`
 	User     string `yaml:"user"`
 	Password string `yaml:"password"`
 	Name     string `yaml:"name"`
 	Params   string `yaml:"params"`
+	// AutoMigrate 启动时自动执行未应用的数据库迁移。
+	AutoMigrate bool `yaml:"auto_migrate"`
 }
 
 type OSSConfig struct {
@@ -54,6 +115,122 @@ func (o OSSConfig) Enabled() bool {
 	return o.Bucket != "" && o.AccessKeyID != "" && o.AccessKeySecret != ""
 }
 
+// AuthConfig JWT 签发配置。secret 为单个 HS256 密钥的简写；配置 keys 后以其为密钥集合，
+// active_kid 指定签发所用密钥，其余密钥仅用于校验，便于轮换。
+type AuthConfig struct {
+	Issuer                  string               `yaml:"issuer"`
+	TokenTTLSeconds         int                  `yaml:"token_ttl_seconds"`
+	RefreshTokenTTLSeconds  int                  `yaml:"refresh_token_ttl_seconds"`
+	PasswordResetTTLSeconds int                  `yaml:"password_reset_ttl_seconds"`
+	Secret                  string               `yaml:"secret"`
+	ActiveKeyID             string               `yaml:"active_kid"`
+	Keys                    []JWTKeyConfig       `yaml:"keys"`
+	LoginGuard              LoginGuardConfig     `yaml:"login_guard"`
+	PasswordPolicy          PasswordPolicyConfig `yaml:"password_policy"`
+}
+
+// PasswordPolicyConfig 新密码校验策略，未配置的字段使用默认值。
+type PasswordPolicyConfig struct {
+	// MinLength 最少字符数，默认 8。
+	MinLength int `yaml:"min_length"`
+	// MaxLength 最大字节数，默认且不超过 72（bcrypt 上限）。
+	MaxLength int `yaml:"max_length"`
+	// MinClasses 大写、小写、数字、符号中至少包含的类别数，默认 2。
+	MinClasses int `yaml:"min_classes"`
+	// DenylistFile 追加的弱密码列表文件，每行一个，与内置列表合并，比较时忽略大小写。
+	DenylistFile string `yaml:"denylist_file"`
+}
+
+// LoginGuardConfig 登录防暴力破解配置，按用户名与客户端 IP 分别统计失败次数。
+type LoginGuardConfig struct {
+	Disabled bool `yaml:"disabled"`
+	// WindowSeconds 失败次数统计窗口，最后一次失败后经过该时长计数清零，默认 900。
+	WindowSeconds int `yaml:"window_seconds"`
+	// FreeAttempts 窗口内不触发退避的失败次数，默认 3。
+	FreeAttempts int `yaml:"free_attempts"`
+	// BaseDelaySeconds 首次退避等待时长，此后每次失败翻倍，默认 1。
+	BaseDelaySeconds int `yaml:"base_delay_seconds"`
+	// MaxDelaySeconds 单次退避等待上限，默认 60。
+	MaxDelaySeconds int `yaml:"max_delay_seconds"`
+	// MaxAttempts 同一用户名失败达到该次数后锁定，默认 10。
+	MaxAttempts int `yaml:"max_attempts"`
+	// IPMaxAttempts 同一 IP 失败达到该次数后锁定，默认 50。
+	IPMaxAttempts int `yaml:"ip_max_attempts"`
+	// LockoutSeconds 锁定时长，默认 900。
+	LockoutSeconds int `yaml:"lockout_seconds"`
+}
+
+// JWTKeyConfig 一个签名密钥。HS256 使用 secret；RS256/EdDSA 从 PEM 文件加载，
+// 只配置 public_key_file 的密钥仅用于校验。
+type JWTKeyConfig struct {
+	ID             string `yaml:"kid"`
+	Algorithm      string `yaml:"alg"`
+	Secret         string `yaml:"secret"`
+	PrivateKeyFile string `yaml:"private_key_file"`
+	PublicKeyFile  string `yaml:"public_key_file"`
+}
+
+// TokenTTL 返回访问令牌有效期，缺省 15 分钟。
+func (a AuthConfig) TokenTTL() time.Duration {
+	if a.TokenTTLSeconds > 0 {
+		return time.Duration(a.TokenTTLSeconds) * time.Second
+	}
+	return 15 * time.Minute
+}
+
+// RefreshTokenTTL 返回刷新令牌有效期，缺省 30 天。
+func (a AuthConfig) RefreshTokenTTL() time.Duration {
+	if a.RefreshTokenTTLSeconds > 0 {
+		return time.Duration(a.RefreshTokenTTLSeconds) * time.Second
+	}
+	return 30 * 24 * time.Hour
+}
+
+// PasswordResetTTL 返回管理员签发的重置密码令牌有效期，缺省 1 小时。
+func (a AuthConfig) PasswordResetTTL() time.Duration {
+	if a.PasswordResetTTLSeconds > 0 {
+		return time.Duration(a.PasswordResetTTLSeconds) * time.Second
+	}
+	return time.Hour
+}
+
+// RetentionConfig 已删除会话的保留与清除配置。
+type RetentionConfig struct {
+	// DeletedConversationDays 会话删除后保留的天数，期满后彻底删除消息与附件文件；0 表示不清除。
+	DeletedConversationDays int `yaml:"deleted_conversation_days"`
+	// IntervalMinutes 清除任务的执行间隔，默认 60。
+	IntervalMinutes int `yaml:"interval_minutes"`
+}
+
+// Enabled 是否启用已删除会话的定期清除。
+func (r RetentionConfig) Enabled() bool {
+	return r.DeletedConversationDays > 0
+}
+
+// RetentionPeriod 返回已删除会话的保留期。
+func (r RetentionConfig) RetentionPeriod() time.Duration {
+	return time.Duration(r.DeletedConversationDays) * 24 * time.Hour
+}
+
+// Interval 返回清除任务的执行间隔，缺省 1 小时。
+func (r RetentionConfig) Interval() time.Duration {
+	if r.IntervalMinutes > 0 {
+		return time.Duration(r.IntervalMinutes) * time.Minute
+	}
+	return time.Hour
+}
+
+// KeySet 返回密钥集合：未配置 keys 时以 secret 作为 kid 为 default 的 HS256 密钥。
+func (a AuthConfig) KeySet() []JWTKeyConfig {
+	if len(a.Keys) > 0 {
+		return a.Keys
+	}
+	if a.Secret == "" {
+		return nil
+	}
+	return []JWTKeyConfig{{ID: "default", Algorithm: "HS256", Secret: a.Secret}}
+}
+
 type AdminConfig struct {
 	Username   string `yaml:"username"`
 	Password   string `yaml:"password"`
@@ -73,6 +250,23 @@ type DashscopeServiceConfig struct {
 	Voice    string `yaml:"voice"`
 }
 
+// IsSQLite 是否使用 sqlite 驱动。
+func (d DatabaseConfig) IsSQLite() bool {
+	return strings.EqualFold(d.Driver, "sqlite") || strings.EqualFold(d.Driver, "sqlite3")
+}
+
+// SQLiteDSN 生成 sqlite 连接串，开启外键并设置忙等待超时。
+func (d DatabaseConfig) SQLiteDSN() string {
+	path := d.Path
+	if path == "" {
+		path = "data.db"
+	}
+	if path == ":memory:" {
+		return "file::memory:?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	}
+	return "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+}
+
 func (d DatabaseConfig) DSN() string {
 	host := d.Host
 	if host == "" {
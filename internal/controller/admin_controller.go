@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -10,7 +11,7 @@ import (
 )
 
 // HandleGetUserList 获取用户列表。
-func HandleGetUserList(c *gin.Context) {
+func (h *Controller) HandleGetUserList(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("current_page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
 	if page <= 0 {
@@ -20,7 +21,7 @@ func HandleGetUserList(c *gin.Context) {
 		pageSize = 10
 	}
 
-	users, totalCount, err := service.ListUsers(c.Request.Context(), page, pageSize)
+	users, totalCount, err := h.svc.ListUsers(c.Request.Context(), page, pageSize)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "db error", ErrCode: 500})
 		return
@@ -39,7 +40,7 @@ func HandleGetUserList(c *gin.Context) {
 }
 
 // HandleAddUser 添加用户。
-func HandleAddUser(c *gin.Context) {
+func (h *Controller) HandleAddUser(c *gin.Context) {
 	var req struct {
 		Username   string `json:"username" binding:"required"`
 		Password   string `json:"password" binding:"required"`
@@ -52,8 +53,12 @@ func HandleAddUser(c *gin.Context) {
 		c.JSON(http.StatusOK, BaseResponse{ErrMsg: "invalid params", ErrCode: 400})
 		return
 	}
-	user, err := service.CreateUser(c.Request.Context(), req.Username, req.Password, req.Nickname, req.Role, req.TotalQuota, req.UsedQuota)
+	user, err := h.svc.CreateUser(c.Request.Context(), req.Username, req.Password, req.Nickname, req.Role, req.TotalQuota, req.UsedQuota)
 	if err != nil {
+		if errors.Is(err, service.ErrWeakPassword) {
+			c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: err.Error(), ErrCode: 400})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "db error", ErrCode: 500})
 		return
 	}
@@ -65,7 +70,7 @@ func HandleAddUser(c *gin.Context) {
 }
 
 // HandleSetQuota 设置额度。
-func HandleSetQuota(c *gin.Context) {
+func (h *Controller) HandleSetQuota(c *gin.Context) {
 	userIDStr := c.Param("user_id")
 	userID, err := strconv.Atoi(userIDStr)
 	if err != nil {
@@ -79,7 +84,7 @@ func HandleSetQuota(c *gin.Context) {
 		c.JSON(http.StatusOK, BaseResponse{ErrMsg: "invalid params", ErrCode: 400})
 		return
 	}
-	updated, err := service.SetUserQuota(c.Request.Context(), userID, req.Quota)
+	updated, err := h.svc.SetUserQuota(c.Request.Context(), userID, req.Quota)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "db error", ErrCode: 500})
 		return
@@ -92,14 +97,14 @@ func HandleSetQuota(c *gin.Context) {
 }
 
 // HandleDeleteUser 删除用户。
-func HandleDeleteUser(c *gin.Context) {
+func (h *Controller) HandleDeleteUser(c *gin.Context) {
 	userIDStr := c.Param("user_id")
 	userID, err := strconv.Atoi(userIDStr)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: "invalid user_id", ErrCode: 400})
 		return
 	}
-	deleted, err := service.DeleteUser(c.Request.Context(), userID)
+	deleted, err := h.svc.DeleteUser(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "db error", ErrCode: 500})
 		return
@@ -110,3 +115,86 @@ func HandleDeleteUser(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, BaseResponse{ErrMsg: "success", ErrCode: 0})
 }
+
+// HandleRevokeUserSessions 吊销指定用户的全部会话，使其刷新令牌失效。
+func (h *Controller) HandleRevokeUserSessions(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: "invalid user_id", ErrCode: 400})
+		return
+	}
+	revoked, err := h.svc.RevokeUserSessions(c.Request.Context(), userID)
+	if err != nil {
+		if err == service.ErrUserNotFound {
+			c.JSON(http.StatusOK, BaseResponse{ErrMsg: "not found", ErrCode: 404})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "db error", ErrCode: 500})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"err_msg":          "success",
+		"err_code":         0,
+		"revoked_sessions": revoked,
+	})
+}
+
+// HandleUnlockUser 解除用户因登录失败过多导致的锁定。
+func (h *Controller) HandleUnlockUser(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: "invalid user_id", ErrCode: 400})
+		return
+	}
+	if err := h.svc.UnlockUser(c.Request.Context(), userID); err != nil {
+		if err == service.ErrUserNotFound {
+			c.JSON(http.StatusOK, BaseResponse{ErrMsg: "not found", ErrCode: 404})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "unlock error", ErrCode: 500})
+		return
+	}
+	c.JSON(http.StatusOK, BaseResponse{ErrMsg: "success", ErrCode: 0})
+}
+
+// HandleAdminResetPassword 重置用户密码：签发一次性重置令牌（默认）或设置临时密码。
+func (h *Controller) HandleAdminResetPassword(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: "invalid user_id", ErrCode: 400})
+		return
+	}
+	var req struct {
+		Mode string `json:"mode"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: "invalid params", ErrCode: 400})
+			return
+		}
+	}
+	reset, err := h.svc.AdminResetPassword(c.Request.Context(), userID, req.Mode)
+	if err != nil {
+		switch err {
+		case service.ErrInvalidResetMode:
+			c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: err.Error(), ErrCode: 400})
+		case service.ErrUserNotFound:
+			c.JSON(http.StatusOK, BaseResponse{ErrMsg: "not found", ErrCode: 404})
+		default:
+			c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "db error", ErrCode: 500})
+		}
+		return
+	}
+	resp := gin.H{
+		"err_msg":  "success",
+		"err_code": 0,
+		"mode":     reset.Mode,
+	}
+	if reset.Mode == service.ResetModeTempPassword {
+		resp["temp_password"] = reset.TempPassword
+	} else {
+		resp["reset_token"] = reset.Token
+		resp["expires_at"] = reset.ExpiresAt.Unix()
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -2,15 +2,20 @@ package controller
 
 import (
 	"database/sql"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
+	"backend/internal/loginguard"
 	"backend/internal/service"
 
 	"github.com/gin-gonic/gin"
 )
 
 // HandleLogin 账号密码登录。
-func HandleLogin(c *gin.Context) {
+func (h *Controller) HandleLogin(c *gin.Context) {
 	var req struct {
 		Username string `json:"username" binding:"required"`
 		Password string `json:"password" binding:"required"`
@@ -20,31 +25,27 @@ func HandleLogin(c *gin.Context) {
 		return
 	}
 
-	token, err := service.Login(c.Request.Context(), req.Username, req.Password)
+	pair, err := h.svc.Login(c.Request.Context(), req.Username, req.Password, c.ClientIP())
 	if err != nil {
-		if err == service.ErrInvalidCredentials {
-			c.JSON(http.StatusUnauthorized, BaseResponse{ErrMsg: "invalid credentials", ErrCode: 401})
+		var locked *loginguard.LockedError
+		if errors.As(err, &locked) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, BaseResponse{ErrMsg: "too many login attempts", ErrCode: 429})
 			return
 		}
-		if err == service.ErrUserNotFound || err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, BaseResponse{ErrMsg: "user not found", ErrCode: 404})
+		if err == service.ErrInvalidCredentials {
+			c.JSON(http.StatusUnauthorized, BaseResponse{ErrMsg: "invalid credentials", ErrCode: 401})
 			return
 		}
 		c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "db error", ErrCode: 500})
 		return
 	}
 
-	c.SetSameSite(http.SameSiteLaxMode)
-	c.SetCookie("jwt_token", token, 24*3600, "/", "", false, true)
-	c.JSON(http.StatusOK, gin.H{
-		"err_msg":   "success",
-		"err_code":  0,
-		"jwt_token": token,
-	})
+	h.writeTokenPair(c, pair)
 }
 
 // HandleSetPassword 修改密码。
-func HandleSetPassword(c *gin.Context) {
+func (h *Controller) HandleSetPassword(c *gin.Context) {
 	var req struct {
 		OldPassword string `json:"old_password" binding:"required"`
 		NewPassword string `json:"new_password" binding:"required"`
@@ -58,11 +59,15 @@ func HandleSetPassword(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, BaseResponse{ErrMsg: "unauthorized", ErrCode: 401})
 		return
 	}
-	if err := service.ResetPassword(c.Request.Context(), username, req.OldPassword, req.NewPassword); err != nil {
+	if err := h.svc.ResetPassword(c.Request.Context(), username, req.OldPassword, req.NewPassword); err != nil {
 		if err == service.ErrInvalidCredentials {
 			c.JSON(http.StatusUnauthorized, BaseResponse{ErrMsg: "old password incorrect", ErrCode: 401})
 			return
 		}
+		if errors.Is(err, service.ErrWeakPassword) {
+			c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: err.Error(), ErrCode: 400})
+			return
+		}
 		if err == sql.ErrNoRows || err == service.ErrUserNotFound {
 			c.JSON(http.StatusUnauthorized, BaseResponse{ErrMsg: "user not found", ErrCode: 401})
 			return
@@ -73,23 +78,91 @@ func HandleSetPassword(c *gin.Context) {
 	c.JSON(http.StatusOK, BaseResponse{ErrMsg: "success", ErrCode: 0})
 }
 
-// HandleRefreshToken 刷新 JWT。
-func HandleRefreshToken(c *gin.Context) {
-	username, err := getUsername(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, BaseResponse{ErrMsg: "unauthorized", ErrCode: 401})
+// HandleResetPasswordWithToken 凭管理员签发的一次性重置令牌设置新密码，无需登录。
+func (h *Controller) HandleResetPasswordWithToken(c *gin.Context) {
+	var req struct {
+		ResetToken  string `json:"reset_token" binding:"required"`
+		NewPassword string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: "invalid params", ErrCode: 400})
+		return
+	}
+	if err := h.svc.ResetPasswordWithToken(c.Request.Context(), req.ResetToken, req.NewPassword); err != nil {
+		if err == service.ErrInvalidResetToken {
+			c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: err.Error(), ErrCode: 400})
+			return
+		}
+		if errors.Is(err, service.ErrWeakPassword) {
+			c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: err.Error(), ErrCode: 400})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "db error", ErrCode: 500})
 		return
 	}
-	token, err := service.RefreshToken(username)
+	c.JSON(http.StatusOK, BaseResponse{ErrMsg: "success", ErrCode: 0})
+}
+
+// HandleRefreshToken 使用刷新令牌（请求体或 Cookie）换取新的令牌对。
+func (h *Controller) HandleRefreshToken(c *gin.Context) {
+	pair, err := h.svc.RefreshSession(c.Request.Context(), readRefreshToken(c))
 	if err != nil {
+		if err == service.ErrInvalidRefreshToken || err == service.ErrRefreshTokenReused || err == service.ErrRefreshTokenRevoked {
+			clearTokenCookies(c)
+			c.JSON(http.StatusUnauthorized, BaseResponse{ErrMsg: err.Error(), ErrCode: 401})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "token error", ErrCode: 500})
 		return
 	}
+	h.writeTokenPair(c, pair)
+}
+
+// HandleLogout 吊销当前会话的刷新令牌并清除 Cookie。
+func (h *Controller) HandleLogout(c *gin.Context) {
+	if err := h.svc.Logout(c.Request.Context(), readRefreshToken(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "db error", ErrCode: 500})
+		return
+	}
+	clearTokenCookies(c)
+	c.JSON(http.StatusOK, BaseResponse{ErrMsg: "success", ErrCode: 0})
+}
+
+// refreshCookiePath 刷新令牌 Cookie 只随 /auth 下的请求发送。
+const refreshCookiePath = "/auth"
+
+// readRefreshToken 优先读取请求体中的 refresh_token，其次读取 Cookie。
+func readRefreshToken(c *gin.Context) string {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if c.Request.ContentLength != 0 {
+		_ = c.ShouldBindJSON(&req)
+	}
+	if req.RefreshToken != "" {
+		return req.RefreshToken
+	}
+	token, _ := c.Cookie("refresh_token")
+	return token
+}
+
+func (h *Controller) writeTokenPair(c *gin.Context, pair service.TokenPair) {
+	accessMaxAge := int(time.Until(pair.AccessExpiresAt).Seconds())
 	c.SetSameSite(http.SameSiteLaxMode)
-	c.SetCookie("jwt_token", token, 24*3600, "/", "", false, true)
+	c.SetCookie("jwt_token", pair.AccessToken, accessMaxAge, "/", "", false, true)
+	c.SetCookie("refresh_token", pair.RefreshToken, int(time.Until(pair.RefreshExpiresAt).Seconds()), refreshCookiePath, "", false, true)
 	c.JSON(http.StatusOK, gin.H{
-		"err_msg":   "success",
-		"err_code":  0,
-		"jwt_token": token,
+		"err_msg":              "success",
+		"err_code":             0,
+		"jwt_token":            pair.AccessToken,
+		"expires_in":           accessMaxAge,
+		"refresh_token":        pair.RefreshToken,
+		"must_change_password": pair.MustChangePassword,
 	})
 }
+
+func clearTokenCookies(c *gin.Context) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie("jwt_token", "", -1, "/", "", false, true)
+	c.SetCookie("refresh_token", "", -1, refreshCookiePath, "", false, true)
+}
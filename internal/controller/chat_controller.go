@@ -1,13 +1,15 @@
 package controller
 
 import (
+	"context"
 	"database/sql"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 
-	"backend/internal/llm"
 	"backend/internal/service"
+	"backend/internal/store"
 
 	"github.com/gin-gonic/gin"
 )
@@ -22,7 +24,7 @@ type SendMessageRequest struct {
 }
 
 // HandleChatSend 发送消息。
-func HandleChatSend(c *gin.Context) {
+func (h *Controller) HandleChatSend(c *gin.Context) {
 	conversationID := c.Param("conversation_id")
 	if strings.TrimSpace(conversationID) == "" {
 		c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: "missing conversation_id", ErrCode: 400})
@@ -44,36 +46,224 @@ func HandleChatSend(c *gin.Context) {
 		return
 	}
 
-	userMsgID, modelMsgID, attachments, reply, err := service.SendMessage(
-		c.Request.Context(),
-		userID,
-		convID,
-		req.Message.ContentType,
-		req.Message.Content,
-		req.AttachmentIDs,
-	)
+	h.respondChatTurn(c, func(onDelta func(string)) (service.ChatTurnResult, error) {
+		if onDelta != nil {
+			return h.svc.SendMessageStream(c.Request.Context(), userID, convID, req.Message.ContentType, req.Message.Content, req.AttachmentIDs, onDelta)
+		}
+		return h.svc.SendMessage(c.Request.Context(), userID, convID, req.Message.ContentType, req.Message.Content, req.AttachmentIDs)
+	})
+}
+
+// HandleRegenerate 为当前分支上最后一条用户消息重新生成回复，旧回复保留为替代版本。
+func (h *Controller) HandleRegenerate(c *gin.Context) {
+	convID, err := strconv.Atoi(c.Param("conversation_id"))
 	if err != nil {
+		c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: "invalid conversation_id", ErrCode: 400})
+		return
+	}
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, BaseResponse{ErrMsg: "unauthorized", ErrCode: 401})
+		return
+	}
+
+	h.respondChatTurn(c, func(onDelta func(string)) (service.ChatTurnResult, error) {
+		return h.svc.Regenerate(c.Request.Context(), userID, convID, onDelta)
+	})
+}
+
+// EditMessageRequest 编辑消息请求体，message_id 为当前分支上要替换的用户消息。
+type EditMessageRequest struct {
+	MessageID int `json:"message_id" binding:"required"`
+	SendMessageRequest
+}
+
+// HandleEditMessage 编辑当前分支上的一条用户消息并从该处重新生成回复，原消息及其后续保留为替代分支。
+func (h *Controller) HandleEditMessage(c *gin.Context) {
+	convID, err := strconv.Atoi(c.Param("conversation_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: "invalid conversation_id", ErrCode: 400})
+		return
+	}
+	var req EditMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: err.Error(), ErrCode: 400})
+		return
+	}
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, BaseResponse{ErrMsg: "unauthorized", ErrCode: 401})
+		return
+	}
+
+	h.respondChatTurn(c, func(onDelta func(string)) (service.ChatTurnResult, error) {
+		return h.svc.EditMessage(c.Request.Context(), userID, convID, req.MessageID, req.Message.ContentType, req.Message.Content, req.AttachmentIDs, onDelta)
+	})
+}
+
+// HandleSwitchBranch 切换会话当前分支到包含指定消息的分支。
+func (h *Controller) HandleSwitchBranch(c *gin.Context) {
+	convID, err := strconv.Atoi(c.Param("conversation_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: "invalid conversation_id", ErrCode: 400})
+		return
+	}
+	var req struct {
+		MessageID int `json:"message_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: "invalid params", ErrCode: 400})
+		return
+	}
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, BaseResponse{ErrMsg: "unauthorized", ErrCode: 401})
+		return
+	}
+	if err := h.svc.SwitchBranch(c.Request.Context(), userID, convID, req.MessageID); err != nil {
 		switch err {
-		case service.ErrConversationNotFound:
-			c.JSON(http.StatusNotFound, BaseResponse{ErrMsg: "conversation not found", ErrCode: 404})
-		case service.ErrLLMNotReady:
-			c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "llm client not initialized", ErrCode: 500})
-		case service.ErrQuotaExceeded:
-			c.JSON(http.StatusForbidden, BaseResponse{ErrMsg: "quota exhausted", ErrCode: 403})
-		default:
-			c.JSON(http.StatusBadGateway, BaseResponse{ErrMsg: err.Error(), ErrCode: 502})
+		case service.ErrConversationNotFound, service.ErrMessageNotFound:
+			c.JSON(http.StatusNotFound, BaseResponse{ErrMsg: err.Error(), ErrCode: 404})
+			return
 		}
+		c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "db error", ErrCode: 500})
 		return
 	}
+	c.JSON(http.StatusOK, BaseResponse{ErrMsg: "success", ErrCode: 0})
+}
 
-	attachList := make([]gin.H, 0, len(attachments))
-	for _, a := range attachments {
-		urlOrPath, err := service.ResolveAttachmentURL(c.Request.Context(), a)
-		if err != nil {
+// respondChatTurn 执行一轮对话并返回结果；客户端请求 SSE 时向 run 传入增量回调，否则传入 nil。
+func (h *Controller) respondChatTurn(c *gin.Context, run func(onDelta func(string)) (service.ChatTurnResult, error)) {
+	if wantsEventStream(c) {
+		h.streamChatTurn(c, run)
+		return
+	}
+
+	result, err := run(nil)
+	if err != nil {
+		status, msg := chatSendError(err)
+		c.JSON(status, BaseResponse{ErrMsg: msg, ErrCode: status})
+		return
+	}
+
+	userMsg, modelMsg, err := h.buildChatTurnMessages(c, result)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "attachment url error", ErrCode: 500})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"err_msg":       "success",
+		"err_code":      0,
+		"user_message":  userMsg,
+		"model_message": modelMsg,
+	})
+}
+
+// streamChatTurn 以 SSE 推送模型增量（delta），结束时推送 done（含消息ID与用量），出错时推送 error。
+// 首个事件前不写出响应头：参数、额度或会话校验等在开始输出前失败时，仍以对应状态码返回普通 JSON。
+func (h *Controller) streamChatTurn(c *gin.Context, run func(onDelta func(string)) (service.ChatTurnResult, error)) {
+	started := false
+	send := func(event string, data any) {
+		if !started {
+			started = true
+			c.Header("Content-Type", "text/event-stream")
+			c.Header("Cache-Control", "no-cache")
+			c.Header("Connection", "keep-alive")
+			c.Header("X-Accel-Buffering", "no")
+			c.Status(http.StatusOK)
+		}
+		c.SSEvent(event, data)
+		c.Writer.Flush()
+	}
+
+	result, err := run(func(delta string) {
+		send("delta", gin.H{"content": delta})
+	})
+	if err != nil {
+		status, msg := chatSendError(err)
+		if !started {
+			c.JSON(status, BaseResponse{ErrMsg: msg, ErrCode: status})
+			return
+		}
+		send("error", BaseResponse{ErrMsg: msg, ErrCode: status})
+		return
+	}
+
+	userMsg, modelMsg, err := h.buildChatTurnMessages(c, result)
+	if err != nil {
+		if !started {
 			c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "attachment url error", ErrCode: 500})
 			return
 		}
-		attachList = append(attachList, gin.H{
+		send("error", BaseResponse{ErrMsg: "attachment url error", ErrCode: 500})
+		return
+	}
+
+	send("done", gin.H{
+		"err_msg":       "success",
+		"err_code":      0,
+		"user_message":  userMsg,
+		"model_message": modelMsg,
+		"usage": gin.H{
+			"prompt_tokens":     result.Usage.PromptTokens,
+			"completion_tokens": result.Usage.CompletionTokens,
+			"total_tokens":      result.Usage.TotalTokens,
+		},
+	})
+}
+
+// wantsEventStream 判断客户端是否请求 SSE 流式返回（Accept: text/event-stream 或 ?stream=true）。
+func wantsEventStream(c *gin.Context) bool {
+	if strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+		return true
+	}
+	stream, _ := strconv.ParseBool(c.Query("stream"))
+	return stream
+}
+
+// chatSendError 将发送消息的错误映射为 HTTP 状态码与错误信息。
+func chatSendError(err error) (int, string) {
+	switch err {
+	case service.ErrConversationNotFound:
+		return http.StatusNotFound, "conversation not found"
+	case service.ErrMessageNotFound:
+		return http.StatusNotFound, "message not found"
+	case service.ErrNotUserMessage, service.ErrNothingToRegenerate:
+		return http.StatusBadRequest, err.Error()
+	case service.ErrGenerationInProgress, service.ErrConversationArchived, service.ErrGenerationStopped:
+		return http.StatusConflict, err.Error()
+	case service.ErrModelNotFound:
+		return http.StatusConflict, "model not found"
+	case service.ErrModelNotAllowed:
+		return http.StatusForbidden, "model not allowed"
+	case service.ErrLLMNotReady:
+		return http.StatusInternalServerError, "llm client not initialized"
+	case service.ErrQuotaExceeded:
+		return http.StatusForbidden, "quota exhausted"
+	default:
+		return http.StatusBadGateway, err.Error()
+	}
+}
+
+// buildChatTurnMessages 组装一轮对话的用户消息与模型消息响应体。
+func (h *Controller) buildChatTurnMessages(c *gin.Context, result service.ChatTurnResult) (gin.H, gin.H, error) {
+	attachList, err := h.attachmentList(c, result.Attachments)
+	if err != nil {
+		return nil, nil, err
+	}
+	return messageToAPI(result.UserMessage, attachList), messageToAPI(result.ModelMessage, []gin.H{}), nil
+}
+
+// attachmentList 组装附件响应体，并解析附件访问地址。
+func (h *Controller) attachmentList(c *gin.Context, items []store.AttachmentInfo) ([]gin.H, error) {
+	list := make([]gin.H, 0, len(items))
+	for _, a := range items {
+		urlOrPath, err := h.svc.ResolveAttachmentURL(c.Request.Context(), a)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, gin.H{
 			"attachment_id":   a.AttachmentID,
 			"attachment_type": a.AttachmentType,
 			"mime_type":       a.MimeType,
@@ -81,37 +271,30 @@ func HandleChatSend(c *gin.Context) {
 			"duration_ms":     a.DurationMS,
 		})
 	}
+	return list, nil
+}
 
-	userMsg := gin.H{
-		"message_id":   userMsgID,
-		"sender_type":  "USER",
-		"content_type": req.Message.ContentType,
-		"content":      req.Message.Content,
-		"token_total":  len(req.Message.Content),
-		"attachments":  attachList,
-	}
-	modelMsg := gin.H{
-		"message_id":   modelMsgID,
-		"sender_type":  "ASSISTANT",
-		"content_type": "TEXT",
-		"content":      reply,
-		"token_total":  len(reply),
-		"attachments":  []any{},
+func messageToAPI(m store.MessageRow, attachments []gin.H) gin.H {
+	return gin.H{
+		"message_id":        m.MessageID,
+		"parent_id":         m.ParentID,
+		"sender_type":       senderTypeToAPI(m.SenderType),
+		"content_type":      m.ContentType,
+		"content":           m.Content,
+		"status":            m.Status,
+		"prompt_tokens":     m.PromptTokens,
+		"completion_tokens": m.CompletionTokens,
+		"token_total":       m.TokenTotal,
+		"attachments":       attachments,
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"err_msg":       "success",
-		"err_code":      0,
-		"user_message":  userMsg,
-		"model_message": modelMsg,
-	})
 }
 
-// HandleNewChat 新建对话。
-func HandleNewChat(c *gin.Context) {
+// HandleNewChat 新建对话，title 为空时在首条回复后自动生成标题。
+func (h *Controller) HandleNewChat(c *gin.Context) {
 	var req struct {
-		Title        string `json:"title" binding:"required"`
+		Title        string `json:"title"`
 		SystemPrompt string `json:"system_prompt"`
+		LLMModel     string `json:"llm_model"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: "invalid params", ErrCode: 400})
@@ -123,11 +306,6 @@ func HandleNewChat(c *gin.Context) {
 		return
 	}
 
-	llmModel := "unknown"
-	if client := llm.Get(); client != nil && client.Model() != "" {
-		llmModel = client.Model()
-	}
-
 	var systemPrompt sql.NullInt64
 	if req.SystemPrompt != "" {
 		if v, err := strconv.Atoi(req.SystemPrompt); err == nil {
@@ -135,8 +313,22 @@ func HandleNewChat(c *gin.Context) {
 		}
 	}
 
-	convInfo, err := service.NewConversation(c.Request.Context(), userID, req.Title, systemPrompt, llmModel)
+	convInfo, err := h.svc.NewConversation(c.Request.Context(), userID, strings.TrimSpace(req.Title), systemPrompt, req.LLMModel)
 	if err != nil {
+		switch err {
+		case service.ErrPromptPresetNotFound:
+			c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: "prompt preset not found", ErrCode: 400})
+			return
+		case service.ErrModelNotFound:
+			c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: "model not found", ErrCode: 400})
+			return
+		case service.ErrModelNotAllowed:
+			c.JSON(http.StatusForbidden, BaseResponse{ErrMsg: "model not allowed", ErrCode: 403})
+			return
+		case service.ErrLLMNotReady:
+			c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "llm client not initialized", ErrCode: 500})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "db error", ErrCode: 500})
 		return
 	}
@@ -147,14 +339,45 @@ func HandleNewChat(c *gin.Context) {
 		"conversation": gin.H{
 			"conversation_id": convInfo.ConversationID,
 			"title":           convInfo.Title,
+			"title_manual":    convInfo.TitleManual,
 			"status":          convInfo.Status,
 			"llm_model":       convInfo.LLMModel,
 		},
 	})
 }
 
+// HandleGetModels 获取当前用户可使用的模型列表。
+func (h *Controller) HandleGetModels(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, BaseResponse{ErrMsg: "unauthorized", ErrCode: 401})
+		return
+	}
+	models, err := h.svc.ListAvailableModels(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "db error", ErrCode: 500})
+		return
+	}
+	defaultModel := h.app.LLM.DefaultModel()
+	list := make([]gin.H, 0, len(models))
+	for _, m := range models {
+		list = append(list, gin.H{
+			"name":           m.Name,
+			"provider":       m.Provider,
+			"context_window": m.ContextWindow,
+			"multimodal":     m.Multimodal,
+			"is_default":     m.Name == defaultModel,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"err_msg":  "success",
+		"err_code": 0,
+		"models":   list,
+	})
+}
+
 // HandleRenameChat 重命名对话。
-func HandleRenameChat(c *gin.Context) {
+func (h *Controller) HandleRenameChat(c *gin.Context) {
 	conversationID := c.Param("conversation_id")
 	if conversationID == "" {
 		c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: "missing conversation_id", ErrCode: 400})
@@ -177,7 +400,7 @@ func HandleRenameChat(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, BaseResponse{ErrMsg: "unauthorized", ErrCode: 401})
 		return
 	}
-	updated, err := service.RenameConversation(c.Request.Context(), userID, convID, req.Title)
+	updated, err := h.svc.RenameConversation(c.Request.Context(), userID, convID, req.Title)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "db error", ErrCode: 500})
 		return
@@ -190,7 +413,7 @@ func HandleRenameChat(c *gin.Context) {
 }
 
 // HandleDeleteChat 删除对话。
-func HandleDeleteChat(c *gin.Context) {
+func (h *Controller) HandleDeleteChat(c *gin.Context) {
 	conversationID := c.Param("conversation_id")
 	if conversationID == "" {
 		c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: "missing conversation_id", ErrCode: 400})
@@ -206,7 +429,7 @@ func HandleDeleteChat(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, BaseResponse{ErrMsg: "unauthorized", ErrCode: 401})
 		return
 	}
-	deleted, err := service.DeleteConversation(c.Request.Context(), userID, convID)
+	deleted, err := h.svc.DeleteConversation(c.Request.Context(), userID, convID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "db error", ErrCode: 500})
 		return
@@ -218,8 +441,111 @@ func HandleDeleteChat(c *gin.Context) {
 	c.JSON(http.StatusOK, BaseResponse{ErrMsg: "success", ErrCode: 0})
 }
 
+// HandleArchiveChat 归档对话，归档后可查看但不能继续发送消息。
+func (h *Controller) HandleArchiveChat(c *gin.Context) {
+	h.updateChatLifecycle(c, h.svc.ArchiveConversation)
+}
+
+// HandleRestoreChat 将已归档或已删除（尚未清除）的对话恢复为进行中。
+func (h *Controller) HandleRestoreChat(c *gin.Context) {
+	h.updateChatLifecycle(c, h.svc.RestoreConversation)
+}
+
+// updateChatLifecycle 解析路径中的对话ID并执行状态变更，对话不存在或当前状态不允许该变更时返回 404。
+func (h *Controller) updateChatLifecycle(c *gin.Context, update func(ctx context.Context, userID, conversationID int) (bool, error)) {
+	convID, err := strconv.Atoi(c.Param("conversation_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: "invalid conversation_id", ErrCode: 400})
+		return
+	}
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, BaseResponse{ErrMsg: "unauthorized", ErrCode: 401})
+		return
+	}
+	updated, err := update(c.Request.Context(), userID, convID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "db error", ErrCode: 500})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusOK, BaseResponse{ErrMsg: "not found", ErrCode: 404})
+		return
+	}
+	c.JSON(http.StatusOK, BaseResponse{ErrMsg: "success", ErrCode: 0})
+}
+
+// HandleStopChat 停止对话进行中的生成，已生成的部分回复以 STOPPED 状态保存。
+func (h *Controller) HandleStopChat(c *gin.Context) {
+	convID, err := strconv.Atoi(c.Param("conversation_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: "invalid conversation_id", ErrCode: 400})
+		return
+	}
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, BaseResponse{ErrMsg: "unauthorized", ErrCode: 401})
+		return
+	}
+	if err := h.svc.StopGeneration(c.Request.Context(), userID, convID); err != nil {
+		switch err {
+		case service.ErrConversationNotFound, service.ErrNoGeneration:
+			c.JSON(http.StatusOK, BaseResponse{ErrMsg: err.Error(), ErrCode: 404})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "db error", ErrCode: 500})
+		return
+	}
+	c.JSON(http.StatusOK, BaseResponse{ErrMsg: "success", ErrCode: 0})
+}
+
+// HandleForkChat 从指定消息分叉出新对话，复制到该消息为止的消息与附件。
+func (h *Controller) HandleForkChat(c *gin.Context) {
+	convID, err := strconv.Atoi(c.Param("conversation_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: "invalid conversation_id", ErrCode: 400})
+		return
+	}
+	var req struct {
+		MessageID int    `json:"message_id" binding:"required"`
+		Title     string `json:"title"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: "invalid params", ErrCode: 400})
+		return
+	}
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, BaseResponse{ErrMsg: "unauthorized", ErrCode: 401})
+		return
+	}
+	convInfo, err := h.svc.ForkConversation(c.Request.Context(), userID, convID, req.MessageID, strings.TrimSpace(req.Title))
+	if err != nil {
+		switch err {
+		case service.ErrConversationNotFound, service.ErrMessageNotFound:
+			c.JSON(http.StatusNotFound, BaseResponse{ErrMsg: err.Error(), ErrCode: 404})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "db error", ErrCode: 500})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"err_msg":  "success",
+		"err_code": 0,
+		"conversation": gin.H{
+			"conversation_id":             convInfo.ConversationID,
+			"title":                       convInfo.Title,
+			"status":                      convInfo.Status,
+			"title_manual":                convInfo.TitleManual,
+			"llm_model":                   convInfo.LLMModel,
+			"forked_from_conversation_id": convInfo.ForkedFromConversationID,
+			"forked_from_message_id":      convInfo.ForkedFromMessageID,
+		},
+	})
+}
+
 // HandleGetChatHistory 获取对话历史。
-func HandleGetChatHistory(c *gin.Context) {
+func (h *Controller) HandleGetChatHistory(c *gin.Context) {
 	conversationID := c.Param("conversation_id")
 	if conversationID == "" {
 		c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: "missing conversation_id", ErrCode: 400})
@@ -244,40 +570,29 @@ func HandleGetChatHistory(c *gin.Context) {
 		pageSize = 10
 	}
 
-	items, attachmentsMap, totalCount, err := service.GetHistory(c.Request.Context(), userID, convID, page, pageSize)
+	items, attachmentsMap, totalCount, err := h.svc.GetHistory(c.Request.Context(), userID, convID, page, pageSize)
 	if err != nil {
+		if err == service.ErrConversationNotFound {
+			c.JSON(http.StatusOK, BaseResponse{ErrMsg: "not found", ErrCode: 404})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "db error", ErrCode: 500})
 		return
 	}
 
+	// sibling_ids 为同一位置的全部版本（重新生成或编辑产生），可据此调用 switch-branch 切换。
 	messages := make([]gin.H, 0, len(items))
 	for _, m := range items {
-		attachments := make([]gin.H, 0)
-		for _, a := range attachmentsMap[m.MessageID] {
-			urlOrPath, err := service.ResolveAttachmentURL(c.Request.Context(), a)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "attachment url error", ErrCode: 500})
-				return
-			}
-			attachments = append(attachments, gin.H{
-				"attachment_id":   a.AttachmentID,
-				"attachment_type": a.AttachmentType,
-				"mime_type":       a.MimeType,
-				"url_or_path":     urlOrPath,
-				"duration_ms":     a.DurationMS,
-			})
-		}
-		if attachments == nil {
-			attachments = []gin.H{}
+		attachments, err := h.attachmentList(c, attachmentsMap[m.MessageID])
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "attachment url error", ErrCode: 500})
+			return
 		}
-		messages = append(messages, gin.H{
-			"message_id":   m.MessageID,
-			"sender_type":  senderTypeToAPI(m.SenderType),
-			"content_type": m.ContentType,
-			"content":      m.Content,
-			"token_total":  m.TokenTotal,
-			"attachments":  attachments,
-		})
+		msg := messageToAPI(m.MessageRow, attachments)
+		msg["sibling_ids"] = m.SiblingIDs
+		msg["sibling_count"] = len(m.SiblingIDs)
+		msg["sibling_index"] = slices.Index(m.SiblingIDs, m.MessageID)
+		messages = append(messages, msg)
 	}
 
 	totalPage := (totalCount + pageSize - 1) / pageSize
@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"backend/internal/app"
+	"backend/internal/config"
+	"backend/internal/llm"
+	"backend/internal/store/memstore"
+
+	"github.com/gin-gonic/gin"
+)
+
+// brokenProvider 先输出一段增量再失败，模拟上游中途断开。
+type brokenProvider struct {
+	*llm.Fake
+}
+
+func (p brokenProvider) ChatCompletionStream(ctx context.Context, messages []llm.Message, onDelta func(string)) (string, llm.Usage, error) {
+	onDelta("par")
+	return "par", llm.Usage{}, errors.New("upstream reset")
+}
+
+// sseEvent SSE 响应中的一个事件。
+type sseEvent struct {
+	name string
+	data map[string]any
+}
+
+// newTestChat 创建基于 memstore 与 fake 模型的 App 和路由，路由以 userID 身份调用发送接口。
+func newTestChat(t *testing.T) (*app.App, *gin.Engine, func(userID int) int) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{
+		Auth: config.AuthConfig{Secret: "test-secret"},
+		LLM: config.LLMConfig{
+			Models: []config.LLMModelConfig{{Name: "fake", Provider: "fake", Model: "fake"}},
+			Title:  config.TitleConfig{Disabled: true},
+		},
+	}
+	a, err := app.NewWithStore(cfg, memstore.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctl := New(a)
+	r := gin.New()
+	r.POST("/send/:user_id/:conversation_id", func(c *gin.Context) {
+		userID, _ := strconv.Atoi(c.Param("user_id"))
+		c.Set("user_id", userID)
+	}, ctl.HandleChatSend)
+
+	newConversation := func(userID int) int {
+		t.Helper()
+		conv, err := a.Service.NewConversation(context.Background(), userID, "chat", sql.NullInt64{}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conv.ConversationID
+	}
+	return a, r, newConversation
+}
+
+func postStream(r *gin.Engine, userID, conversationID int, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/send/"+strconv.Itoa(userID)+"/"+strconv.Itoa(conversationID), strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func parseEvents(t *testing.T, body string) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var name string
+	sc := bufio.NewScanner(strings.NewReader(body))
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			name = strings.TrimPrefix(line, "event:")
+		case strings.HasPrefix(line, "data:"):
+			data := map[string]any{}
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data:")), &data); err != nil {
+				t.Fatalf("event %s data %q: %v", name, line, err)
+			}
+			events = append(events, sseEvent{name: name, data: data})
+		}
+	}
+	return events
+}
+
+func TestStreamChatTurn(t *testing.T) {
+	a, r, newConversation := newTestChat(t)
+	user, err := a.Store.CreateUserWithQuota(context.Background(), "alice", "hash", "alice", "user", 10000, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	convID := newConversation(user.UserID)
+
+	w := postStream(r, user.UserID, convID, `{"message":{"content_type":"TEXT","content":"hi"}}`)
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+		t.Fatalf("status %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	events := parseEvents(t, w.Body.String())
+	if len(events) < 2 {
+		t.Fatalf("events = %+v", events)
+	}
+	var reply strings.Builder
+	for _, e := range events[:len(events)-1] {
+		if e.name != "delta" {
+			t.Fatalf("event %q before done", e.name)
+		}
+		reply.WriteString(e.data["content"].(string))
+	}
+	if reply.String() != "echo: hi" {
+		t.Errorf("streamed reply = %q", reply.String())
+	}
+	done := events[len(events)-1]
+	if done.name != "done" || done.data["model_message"].(map[string]any)["content"] != "echo: hi" {
+		t.Fatalf("last event = %+v", done)
+	}
+	if usage := done.data["usage"].(map[string]any); usage["total_tokens"].(float64) <= 0 {
+		t.Errorf("usage = %v", usage)
+	}
+}
+
+func TestStreamChatTurnErrors(t *testing.T) {
+	a, r, newConversation := newTestChat(t)
+	ctx := context.Background()
+	user, err := a.Store.CreateUserWithQuota(ctx, "alice", "hash", "alice", "user", 10000, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	broke, err := a.Store.CreateUserWithQuota(ctx, "bob", "hash", "bob", "user", 100, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	convID := newConversation(user.UserID)
+	brokeConvID := newConversation(broke.UserID)
+
+	// 开始输出前的错误以状态码与 JSON 返回，不切换为 SSE。
+	tests := []struct {
+		name           string
+		userID, convID int
+		body           string
+		wantStatus     int
+	}{
+		{"bad input", user.UserID, convID, `{"message":{"content_type":"TEXT"}}`, http.StatusBadRequest},
+		{"not found", user.UserID, 9999, `{"message":{"content_type":"TEXT","content":"hi"}}`, http.StatusNotFound},
+		{"other user's conversation", broke.UserID, convID, `{"message":{"content_type":"TEXT","content":"hi"}}`, http.StatusNotFound},
+		{"quota exceeded", broke.UserID, brokeConvID, `{"message":{"content_type":"TEXT","content":"hi"}}`, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postStream(r, tt.userID, tt.convID, tt.body)
+			if w.Code != tt.wantStatus || strings.Contains(w.Header().Get("Content-Type"), "text/event-stream") {
+				t.Fatalf("status %d, content type %q, body %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+			}
+			var resp BaseResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.ErrCode != tt.wantStatus {
+				t.Fatalf("body %s, err %v", w.Body, err)
+			}
+		})
+	}
+
+	// 开始输出后的错误只能以 error 事件结束流。
+	a.LLM.Set(brokenProvider{llm.NewFake("fake", "")})
+	w := postStream(r, user.UserID, convID, `{"message":{"content_type":"TEXT","content":"hi"}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d", w.Code)
+	}
+	events := parseEvents(t, w.Body.String())
+	if len(events) != 2 || events[0].name != "delta" || events[1].name != "error" || events[1].data["err_code"].(float64) != http.StatusBadGateway {
+		t.Fatalf("events = %+v", events)
+	}
+}
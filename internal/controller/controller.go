@@ -0,0 +1,17 @@
+package controller
+
+import (
+	"backend/internal/app"
+	"backend/internal/service"
+)
+
+// Controller HTTP 处理器集合，依赖均来自 App。
+type Controller struct {
+	app *app.App
+	svc *service.Service
+}
+
+// New 创建绑定到 a 的 Controller。
+func New(a *app.App) *Controller {
+	return &Controller{app: a, svc: a.Service}
+}
@@ -21,17 +21,13 @@ func getUsername(c *gin.Context) (string, error) {
 	return u, nil
 }
 
-// getUserIDFromContext 通过用户名反查用户ID。
+// getUserIDFromContext 从上下文获取 AuthMiddleware 写入的用户ID。
 func getUserIDFromContext(c *gin.Context) (int, error) {
-	username, err := getUsername(c)
-	if err != nil {
-		return 0, err
+	userID := c.GetInt("user_id")
+	if userID <= 0 {
+		return 0, errors.New("missing user_id in context")
 	}
-	u, err := store.GetUserByUsername(c.Request.Context(), username)
-	if err != nil {
-		return 0, err
-	}
-	return u.UserID, nil
+	return userID, nil
 }
 
 // senderTypeToAPI 转换为 API 字段值。
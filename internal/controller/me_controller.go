@@ -3,6 +3,7 @@ package controller
 import (
 	"database/sql"
 	"net/http"
+	"strings"
 
 	"backend/internal/service"
 
@@ -10,13 +11,13 @@ import (
 )
 
 // HandleGetMeInfo 获取当前用户信息。
-func HandleGetMeInfo(c *gin.Context) {
-	username, err := getUsername(c)
+func (h *Controller) HandleGetMeInfo(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, BaseResponse{ErrMsg: "unauthorized", ErrCode: 401})
 		return
 	}
-	user, err := service.GetMeInfo(c.Request.Context(), username)
+	user, err := h.svc.GetMeInfo(c.Request.Context(), userID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusUnauthorized, BaseResponse{ErrMsg: "user not found", ErrCode: 401})
@@ -29,24 +30,29 @@ func HandleGetMeInfo(c *gin.Context) {
 		"err_msg":  "success",
 		"err_code": 0,
 		"user": gin.H{
-			"user_id":     user.UserID,
-			"username":    user.Username,
-			"nickname":    user.Nickname,
-			"role":        user.Role,
-			"total_quota": user.TotalQuota,
-			"used_quota":  user.UsedQuota,
+			"user_id":              user.UserID,
+			"username":             user.Username,
+			"nickname":             user.Nickname,
+			"role":                 user.Role,
+			"total_quota":          user.TotalQuota,
+			"used_quota":           user.UsedQuota,
+			"must_change_password": user.MustChangePassword,
 		},
 	})
 }
 
-// HandleGetMeConversations 获取当前用户会话列表。
-func HandleGetMeConversations(c *gin.Context) {
+// HandleGetMeConversations 获取当前用户会话列表，查询参数 status 可选 ACTIVE（默认）、ARCHIVED、DELETED。
+func (h *Controller) HandleGetMeConversations(c *gin.Context) {
 	userID, err := getUserIDFromContext(c)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, BaseResponse{ErrMsg: "unauthorized", ErrCode: 401})
 		return
 	}
-	conversations, err := service.ListMyConversations(c.Request.Context(), userID)
+	conversations, err := h.svc.ListMyConversations(c.Request.Context(), userID, strings.ToUpper(c.Query("status")))
+	if err == service.ErrInvalidConversationStatus {
+		c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: err.Error(), ErrCode: 400})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "db error", ErrCode: 500})
 		return
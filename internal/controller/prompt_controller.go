@@ -4,14 +4,12 @@ import (
 	"net/http"
 	"strconv"
 
-	"backend/internal/service"
-
 	"github.com/gin-gonic/gin"
 )
 
 // HandleGetPromptPreset 获取提示词列表（聊天模块）。
-func HandleGetPromptPreset(c *gin.Context) {
-	presets, err := service.ListPromptPresets(c.Request.Context())
+func (h *Controller) HandleGetPromptPreset(c *gin.Context) {
+	presets, err := h.svc.ListPromptPresets(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "db error", ErrCode: 500})
 		return
@@ -33,8 +31,8 @@ func HandleGetPromptPreset(c *gin.Context) {
 }
 
 // HandleAdminGetPromptPresets 获取提示词列表（管理端）。
-func HandleAdminGetPromptPresets(c *gin.Context) {
-	list, err := service.ListPromptPresets(c.Request.Context())
+func (h *Controller) HandleAdminGetPromptPresets(c *gin.Context) {
+	list, err := h.svc.ListPromptPresets(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "db error", ErrCode: 500})
 		return
@@ -47,7 +45,7 @@ func HandleAdminGetPromptPresets(c *gin.Context) {
 }
 
 // HandleAdminCreatePromptPreset 新增提示词。
-func HandleAdminCreatePromptPreset(c *gin.Context) {
+func (h *Controller) HandleAdminCreatePromptPreset(c *gin.Context) {
 	var req struct {
 		Name        string `json:"name" binding:"required"`
 		Description string `json:"description" binding:"required"`
@@ -57,7 +55,7 @@ func HandleAdminCreatePromptPreset(c *gin.Context) {
 		c.JSON(http.StatusOK, BaseResponse{ErrMsg: "invalid params", ErrCode: 400})
 		return
 	}
-	if err := service.CreatePromptPreset(c.Request.Context(), req.Name, req.Description, req.Content); err != nil {
+	if err := h.svc.CreatePromptPreset(c.Request.Context(), req.Name, req.Description, req.Content); err != nil {
 		c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "db error", ErrCode: 500})
 		return
 	}
@@ -65,14 +63,14 @@ func HandleAdminCreatePromptPreset(c *gin.Context) {
 }
 
 // HandleAdminDeletePromptPreset 删除提示词。
-func HandleAdminDeletePromptPreset(c *gin.Context) {
+func (h *Controller) HandleAdminDeletePromptPreset(c *gin.Context) {
 	idStr := c.Param("prompt_preset_id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: "invalid prompt_preset_id", ErrCode: 400})
 		return
 	}
-	deleted, err := service.DeletePromptPreset(c.Request.Context(), id)
+	deleted, err := h.svc.DeletePromptPreset(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "db error", ErrCode: 500})
 		return
@@ -9,7 +9,7 @@ import (
 )
 
 // HandleSTTUpload 语音转文本（占位）。
-func HandleSTTUpload(c *gin.Context) {
+func (h *Controller) HandleSTTUpload(c *gin.Context) {
 	file, header, err := c.Request.FormFile("audio")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -35,7 +35,7 @@ func HandleSTTUpload(c *gin.Context) {
 		}
 	}
 
-	result, err := service.SpeechToText(c.Request.Context(), userID, filename, mimeType, file)
+	result, err := h.svc.SpeechToText(c.Request.Context(), userID, filename, mimeType, file)
 	if err != nil {
 		if err == service.ErrQuotaExceeded {
 			c.JSON(http.StatusForbidden, BaseResponse{ErrMsg: "quota exhausted", ErrCode: 403})
@@ -12,7 +12,7 @@ import (
 )
 
 // HandleTTSConvert text-to-speech.
-func HandleTTSConvert(c *gin.Context) {
+func (h *Controller) HandleTTSConvert(c *gin.Context) {
 	messageID := c.Param("message_id")
 	if strings.TrimSpace(messageID) == "" {
 		c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: "missing message_id", ErrCode: 400})
@@ -30,7 +30,7 @@ func HandleTTSConvert(c *gin.Context) {
 		return
 	}
 
-	audioURL, err := service.RequestTTSURL(c.Request.Context(), userID, msgID)
+	audioURL, err := h.svc.RequestTTSURL(c.Request.Context(), userID, msgID)
 	if err != nil {
 		if err == service.ErrQuotaExceeded {
 			c.JSON(http.StatusForbidden, BaseResponse{ErrMsg: "quota exhausted", ErrCode: 403})
@@ -4,13 +4,11 @@ import (
 	"net/http"
 	"time"
 
-	"backend/internal/service"
-
 	"github.com/gin-gonic/gin"
 )
 
 // HandleUploadFile 上传附件并落库。
-func HandleUploadFile(c *gin.Context) {
+func (h *Controller) HandleUploadFile(c *gin.Context) {
 	file, header, err := c.Request.FormFile("file")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, BaseResponse{ErrMsg: "missing file", ErrCode: 400})
@@ -33,7 +31,7 @@ func HandleUploadFile(c *gin.Context) {
 		}
 	}
 
-	result, err := service.UploadAndRecord(c.Request.Context(), userID, filename, mimeType, file)
+	result, err := h.svc.UploadAndRecord(c.Request.Context(), userID, filename, mimeType, file)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, BaseResponse{ErrMsg: "upload failed", ErrCode: 500})
 		return
@@ -5,13 +5,33 @@ import (
 	"fmt"
 
 	_ "github.com/go-sql-driver/mysql"
+	_ "modernc.org/sqlite"
 
 	"backend/internal/config"
 )
 
-var global *sql.DB
+// 支持的数据库方言。
+const (
+	DialectMySQL  = "mysql"
+	DialectSQLite = "sqlite"
+)
+
+// Open 按配置打开数据库连接并检查连通性，连接由调用方持有并负责关闭。
+func Open(cfg config.DatabaseConfig) (*sql.DB, error) {
+	if cfg.IsSQLite() {
+		db, err := sql.Open("sqlite", cfg.SQLiteDSN())
+		if err != nil {
+			return nil, err
+		}
+		// sqlite 同一时刻只允许一个写者，内存库也只在单连接内可见，这里固定为单连接。
+		db.SetMaxOpenConns(1)
+		if err := db.Ping(); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+		return db, nil
+	}
 
-func Init(cfg config.DatabaseConfig) (*sql.DB, error) {
 	dsn := cfg.DSN()
 	if cfg.User == "" || cfg.Name == "" {
 		return nil, fmt.Errorf("db config missing user or name")
@@ -24,10 +44,13 @@ func Init(cfg config.DatabaseConfig) (*sql.DB, error) {
 		_ = db.Close()
 		return nil, err
 	}
-	global = db
 	return db, nil
 }
 
-func Get() *sql.DB {
-	return global
+// DialectOf 返回配置对应的数据库方言。
+func DialectOf(cfg config.DatabaseConfig) string {
+	if cfg.IsSQLite() {
+		return DialectSQLite
+	}
+	return DialectMySQL
 }
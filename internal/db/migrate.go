@@ -0,0 +1,352 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/mysql/*.sql migrations/sqlite/*.sql
+var migrationFS embed.FS
+
+// Migration 一个版本的迁移脚本，文件名形如 0001_init.up.sql / 0001_init.down.sql。
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus 迁移版本及其应用状态。
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	// Dirty 迁移执行中途失败，表结构可能只应用了一部分。
+	Dirty bool
+}
+
+// LoadMigrations 读取指定方言的内嵌迁移脚本并按版本升序返回。
+func LoadMigrations(dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrationFS, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		name := e.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		versionStr, label, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name: %s", name)
+		}
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version: %s", name)
+		}
+		body, err := fs.ReadFile(migrationFS, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: label}
+			byVersion[version] = m
+		} else if m.Name != label {
+			return nil, fmt.Errorf("migration %d has conflicting names: %s, %s", version, m.Name, label)
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	out := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d missing up script", m.Version)
+		}
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// ErrDirtyMigration 存在执行中途失败的迁移版本，需人工修复表结构后通过 ResolveDirty 标记结果。
+var ErrDirtyMigration = errors.New("dirty migration")
+
+// MigrateUp 依次执行所有未应用的迁移，返回本次应用的版本数。
+func MigrateUp(ctx context.Context, dbx *sql.DB, dialect string) (int, error) {
+	migrations, err := LoadMigrations(dialect)
+	if err != nil {
+		return 0, err
+	}
+	return migrateUp(ctx, dbx, dialect, migrations)
+}
+
+func migrateUp(ctx context.Context, dbx *sql.DB, dialect string, migrations []Migration) (int, error) {
+	applied, err := appliedVersions(ctx, dbx)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkDirty(applied); err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := applyMigration(ctx, dbx, dialect, m, true); err != nil {
+			return count, fmt.Errorf("migration %04d_%s up: %w", m.Version, m.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// MigrateDown 回滚最近应用的 steps 个迁移，返回实际回滚的版本数。
+func MigrateDown(ctx context.Context, dbx *sql.DB, dialect string, steps int) (int, error) {
+	migrations, err := LoadMigrations(dialect)
+	if err != nil {
+		return 0, err
+	}
+	return migrateDown(ctx, dbx, dialect, migrations, steps)
+}
+
+func migrateDown(ctx context.Context, dbx *sql.DB, dialect string, migrations []Migration, steps int) (int, error) {
+	applied, err := appliedVersions(ctx, dbx)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkDirty(applied); err != nil {
+		return 0, err
+	}
+	count := 0
+	for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if m.Down == "" {
+			return count, fmt.Errorf("migration %04d_%s has no down script", m.Version, m.Name)
+		}
+		if err := applyMigration(ctx, dbx, dialect, m, false); err != nil {
+			return count, fmt.Errorf("migration %04d_%s down: %w", m.Version, m.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// applyMigration 执行一个版本的 up 或 down 脚本并更新 schema_migrations。
+// sqlite 支持事务内 DDL，脚本与版本记录在同一事务中提交，失败时整体回滚；
+// MySQL 的 DDL 会隐式提交，执行前先将版本标记为 dirty，成功后再清除或删除记录，中途失败时标记保留。
+func applyMigration(ctx context.Context, dbx *sql.DB, dialect string, m Migration, up bool) error {
+	script := m.Up
+	if !up {
+		script = m.Down
+	}
+	if dialect == DialectSQLite {
+		tx, err := dbx.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if err := execScript(ctx, tx, script); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if up {
+			_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, m.Version, m.Name)
+		} else {
+			_, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, m.Version)
+		}
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	}
+
+	var err error
+	if up {
+		_, err = dbx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, dirty) VALUES (?, ?, 1)`, m.Version, m.Name)
+	} else {
+		_, err = dbx.ExecContext(ctx, `UPDATE schema_migrations SET dirty = 1 WHERE version = ?`, m.Version)
+	}
+	if err != nil {
+		return err
+	}
+	if err := execScript(ctx, dbx, script); err != nil {
+		return err
+	}
+	if up {
+		_, err = dbx.ExecContext(ctx, `UPDATE schema_migrations SET dirty = 0 WHERE version = ?`, m.Version)
+	} else {
+		_, err = dbx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, m.Version)
+	}
+	return err
+}
+
+// ResolveDirty 人工修复中途失败的迁移后标记其结果：applied 为 true 表示该版本已完整应用，
+// 为 false 表示已恢复到应用前的状态（删除版本记录）。版本不是 dirty 状态时返回错误。
+func ResolveDirty(ctx context.Context, dbx *sql.DB, version int, applied bool) error {
+	if _, err := appliedVersions(ctx, dbx); err != nil {
+		return err
+	}
+	query := `DELETE FROM schema_migrations WHERE version = ? AND dirty = 1`
+	if applied {
+		query = `UPDATE schema_migrations SET dirty = 0 WHERE version = ? AND dirty = 1`
+	}
+	res, err := dbx.ExecContext(ctx, query, version)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return fmt.Errorf("migration %04d is not dirty", version)
+	}
+	return nil
+}
+
+func checkDirty(applied map[int]appliedVersion) error {
+	versions := make([]int, 0)
+	for v, a := range applied {
+		if a.dirty {
+			versions = append(versions, v)
+		}
+	}
+	if len(versions) == 0 {
+		return nil
+	}
+	sort.Ints(versions)
+	return fmt.Errorf("%w: version %04d failed partway; fix the schema manually, then run migrate resolve", ErrDirtyMigration, versions[0])
+}
+
+// MigrationStatuses 返回所有迁移版本的应用状态。
+func MigrationStatuses(ctx context.Context, dbx *sql.DB, dialect string) ([]MigrationStatus, error) {
+	migrations, err := LoadMigrations(dialect)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(ctx, dbx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		st := MigrationStatus{Version: m.Version, Name: m.Name}
+		if a, ok := applied[m.Version]; ok {
+			st.Applied = true
+			appliedAt := a.at
+			st.AppliedAt = &appliedAt
+			st.Dirty = a.dirty
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+type appliedVersion struct {
+	at    time.Time
+	dirty bool
+}
+
+func appliedVersions(ctx context.Context, dbx *sql.DB) (map[int]appliedVersion, error) {
+	if _, err := dbx.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INT          NOT NULL PRIMARY KEY,
+			name       VARCHAR(255) NOT NULL,
+			dirty      TINYINT      NOT NULL DEFAULT 0,
+			applied_at DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`); err != nil {
+		return nil, err
+	}
+	// 早期版本创建的表没有 dirty 列，查询失败时补齐。
+	if rows, err := dbx.QueryContext(ctx, `SELECT dirty FROM schema_migrations WHERE 1 = 0`); err == nil {
+		rows.Close()
+	} else if _, err := dbx.ExecContext(ctx, `ALTER TABLE schema_migrations ADD COLUMN dirty TINYINT NOT NULL DEFAULT 0`); err != nil {
+		return nil, err
+	}
+	rows, err := dbx.QueryContext(ctx, `SELECT version, applied_at, dirty FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[int]appliedVersion)
+	for rows.Next() {
+		var (
+			version int
+			a       appliedVersion
+		)
+		if err := rows.Scan(&version, &a.at, &a.dirty); err != nil {
+			return nil, err
+		}
+		out[version] = a
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// execer 为 *sql.DB 与 *sql.Tx 的公共执行接口。
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// execScript 按语句逐条执行脚本（驱动默认不开启 multiStatements）。
+func execScript(ctx context.Context, dbx execer, script string) error {
+	for _, stmt := range splitStatements(script) {
+		if _, err := dbx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitStatements 以行尾分号切分语句，并忽略以 -- 开头的注释行。
+func splitStatements(script string) []string {
+	var (
+		stmts []string
+		b     strings.Builder
+	)
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			stmt := strings.TrimSuffix(strings.TrimSpace(b.String()), ";")
+			stmts = append(stmts, stmt)
+			b.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(b.String()); rest != "" {
+		stmts = append(stmts, rest)
+	}
+	return stmts
+}
@@ -0,0 +1,197 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"backend/internal/config"
+)
+
+func openTestSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	dbx, err := Open(config.DatabaseConfig{Driver: "sqlite", Path: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dbx.Close() })
+	return dbx
+}
+
+func tableExists(t *testing.T, dbx *sql.DB, name string) bool {
+	t.Helper()
+	var n int
+	if err := dbx.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n > 0
+}
+
+func TestMigrateUpDownUpSQLite(t *testing.T) {
+	ctx := context.Background()
+	dbx := openTestSQLite(t)
+	migrations, err := LoadMigrations(DialectSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mysqlMigrations, err := LoadMigrations(DialectMySQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != len(mysqlMigrations) {
+		t.Fatalf("sqlite has %d migrations, mysql has %d", len(migrations), len(mysqlMigrations))
+	}
+
+	n, err := MigrateUp(ctx, dbx, DialectSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(migrations) {
+		t.Fatalf("applied %d, want %d", n, len(migrations))
+	}
+	if n, err := MigrateUp(ctx, dbx, DialectSQLite); err != nil || n != 0 {
+		t.Fatalf("second up applied %d, err %v", n, err)
+	}
+	for _, table := range []string{"users", "conversations", "messages", "message_attachments", "conversation_summaries"} {
+		if !tableExists(t, dbx, table) {
+			t.Errorf("table %s missing after up", table)
+		}
+	}
+
+	n, err = MigrateDown(ctx, dbx, DialectSQLite, len(migrations)+5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(migrations) {
+		t.Fatalf("rolled back %d, want %d", n, len(migrations))
+	}
+	for _, table := range []string{"users", "conversations", "messages", "conversation_summaries"} {
+		if tableExists(t, dbx, table) {
+			t.Errorf("table %s still exists after down", table)
+		}
+	}
+
+	if n, err := MigrateUp(ctx, dbx, DialectSQLite); err != nil || n != len(migrations) {
+		t.Fatalf("re-up applied %d, err %v", n, err)
+	}
+	statuses, err := MigrationStatuses(ctx, dbx, DialectSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range statuses {
+		if !st.Applied || st.Dirty || st.AppliedAt == nil {
+			t.Errorf("status %+v", st)
+		}
+	}
+}
+
+func TestMigrateFailureRollsBackSQLite(t *testing.T) {
+	ctx := context.Background()
+	dbx := openTestSQLite(t)
+	migrations := []Migration{
+		{Version: 1, Name: "ok", Up: "CREATE TABLE a (id INTEGER);", Down: "DROP TABLE a;"},
+		{Version: 2, Name: "broken", Up: "CREATE TABLE b (id INTEGER);\nINSERT INTO missing VALUES (1);", Down: "DROP TABLE b;"},
+	}
+	n, err := migrateUp(ctx, dbx, DialectSQLite, migrations)
+	if err == nil || n != 1 {
+		t.Fatalf("applied %d, err %v", n, err)
+	}
+	if tableExists(t, dbx, "b") {
+		t.Error("partial migration 2 was not rolled back")
+	}
+	applied, err := appliedVersions(ctx, dbx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := applied[2]; ok || len(applied) != 1 {
+		t.Errorf("applied versions = %v", applied)
+	}
+
+	migrations[1].Up = "CREATE TABLE b (id INTEGER);"
+	if n, err := migrateUp(ctx, dbx, DialectSQLite, migrations); err != nil || n != 1 {
+		t.Fatalf("retry applied %d, err %v", n, err)
+	}
+}
+
+// 非事务方言（MySQL）的路径与具体数据库无关，这里在 sqlite 上按 MySQL 方式执行以验证 dirty 标记。
+func TestMigrateDirtyWithoutTransactionalDDL(t *testing.T) {
+	ctx := context.Background()
+	dbx := openTestSQLite(t)
+	migrations := []Migration{
+		{Version: 1, Name: "ok", Up: "CREATE TABLE a (id INTEGER);", Down: "DROP TABLE a;"},
+		{Version: 2, Name: "broken", Up: "CREATE TABLE b (id INTEGER);\nINSERT INTO missing VALUES (1);", Down: "DROP TABLE b;"},
+	}
+	if _, err := migrateUp(ctx, dbx, DialectMySQL, migrations); err == nil {
+		t.Fatal("expected failure")
+	}
+	statuses, err := appliedVersions(ctx, dbx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !statuses[2].dirty || statuses[1].dirty {
+		t.Fatalf("dirty flags = %+v", statuses)
+	}
+	if _, err := migrateUp(ctx, dbx, DialectMySQL, migrations); !errors.Is(err, ErrDirtyMigration) {
+		t.Fatalf("up err = %v, want ErrDirtyMigration", err)
+	}
+	if _, err := migrateDown(ctx, dbx, DialectMySQL, migrations, 1); !errors.Is(err, ErrDirtyMigration) {
+		t.Fatalf("down err = %v, want ErrDirtyMigration", err)
+	}
+	if err := ResolveDirty(ctx, dbx, 1, true); err == nil {
+		t.Error("resolving a clean version should fail")
+	}
+
+	// 人工撤销部分应用的变更后标记为已恢复，修正脚本再次执行。
+	if _, err := dbx.Exec("DROP TABLE b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ResolveDirty(ctx, dbx, 2, false); err != nil {
+		t.Fatal(err)
+	}
+	migrations[1].Up = "CREATE TABLE b (id INTEGER);"
+	if n, err := migrateUp(ctx, dbx, DialectMySQL, migrations); err != nil || n != 1 {
+		t.Fatalf("retry applied %d, err %v", n, err)
+	}
+	if n, err := migrateDown(ctx, dbx, DialectMySQL, migrations, 2); err != nil || n != 2 {
+		t.Fatalf("down rolled back %d, err %v", n, err)
+	}
+	if tableExists(t, dbx, "a") || tableExists(t, dbx, "b") {
+		t.Error("tables remain after down")
+	}
+}
+
+func TestAppliedVersionsUpgradesLegacyTable(t *testing.T) {
+	ctx := context.Background()
+	dbx := openTestSQLite(t)
+	if _, err := dbx.Exec(`CREATE TABLE schema_migrations (
+		version INT NOT NULL PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dbx.Exec(`INSERT INTO schema_migrations (version, name) VALUES (1, 'init')`); err != nil {
+		t.Fatal(err)
+	}
+	applied, err := appliedVersions(ctx, dbx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, ok := applied[1]; !ok || a.dirty {
+		t.Fatalf("applied = %+v", applied)
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	got := splitStatements("-- comment\nCREATE TABLE a (\n  id INT\n);\n\nINSERT INTO a VALUES (1);\nSELECT 1")
+	want := []string{"CREATE TABLE a (\n  id INT\n)", "INSERT INTO a VALUES (1)", "SELECT 1"}
+	if len(got) != len(want) {
+		t.Fatalf("got %q", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("stmt %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS message_attachments;
+DROP TABLE IF EXISTS messages;
+DROP TABLE IF EXISTS conversations;
+DROP TABLE IF EXISTS prompt_presets;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,55 @@
+-- 基础表结构。使用 IF NOT EXISTS 以便已有数据库接入迁移时直接登记该版本。
+CREATE TABLE IF NOT EXISTS users (
+    user_id     INT AUTO_INCREMENT PRIMARY KEY,
+    username    VARCHAR(64)  NOT NULL,
+    password    VARCHAR(255) NOT NULL,
+    nickname    VARCHAR(64)  NOT NULL DEFAULT '',
+    role        VARCHAR(16)  NOT NULL DEFAULT 'USER',
+    status      TINYINT      NOT NULL DEFAULT 1,
+    total_quota BIGINT       NOT NULL DEFAULT 0,
+    used_quota  BIGINT       NOT NULL DEFAULT 0,
+    created_at  DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_users_username (username)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS prompt_presets (
+    prompt_preset_id INT AUTO_INCREMENT PRIMARY KEY,
+    name             VARCHAR(128) NOT NULL,
+    description      VARCHAR(512) NOT NULL DEFAULT '',
+    content          TEXT         NOT NULL,
+    created_at       DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS conversations (
+    conversation_id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id         INT          NOT NULL,
+    title           VARCHAR(255) NOT NULL DEFAULT '',
+    status          VARCHAR(16)  NOT NULL DEFAULT 'ACTIVE',
+    llm_model       VARCHAR(128) NOT NULL DEFAULT '',
+    system_prompt   INT          NULL,
+    created_at      DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_conversations_user (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS messages (
+    message_id      INT AUTO_INCREMENT PRIMARY KEY,
+    conversation_id INT         NOT NULL,
+    sender_type     TINYINT     NOT NULL,
+    content_type    VARCHAR(16) NOT NULL DEFAULT 'TEXT',
+    content         MEDIUMTEXT  NOT NULL,
+    token_total     INT         NOT NULL DEFAULT 0,
+    created_at      DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    KEY idx_messages_conversation (conversation_id, created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS message_attachments (
+    attachment_id   INT AUTO_INCREMENT PRIMARY KEY,
+    message_id      INT           NOT NULL,
+    attachment_type VARCHAR(16)   NOT NULL,
+    mime_type       VARCHAR(128)  NOT NULL DEFAULT '',
+    storage_type    VARCHAR(16)   NOT NULL DEFAULT 'LOCAL',
+    url_or_path     VARCHAR(1024) NOT NULL,
+    duration_ms     DOUBLE        NULL,
+    created_at      DATETIME      NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_message_attachments_message (message_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1 @@
+ALTER TABLE conversations DROP COLUMN system_prompt_content;
@@ -0,0 +1 @@
+ALTER TABLE conversations ADD COLUMN system_prompt_content TEXT NULL AFTER system_prompt;
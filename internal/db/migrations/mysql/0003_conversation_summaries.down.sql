@@ -0,0 +1 @@
+DROP TABLE IF EXISTS conversation_summaries;
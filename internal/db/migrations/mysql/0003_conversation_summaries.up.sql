@@ -0,0 +1,9 @@
+CREATE TABLE conversation_summaries (
+    summary_id         INT AUTO_INCREMENT PRIMARY KEY,
+    conversation_id    INT        NOT NULL,
+    content            MEDIUMTEXT NOT NULL,
+    covered_message_id INT        NOT NULL,
+    token_total        INT        NOT NULL DEFAULT 0,
+    created_at         DATETIME   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_conversation_summaries_conversation (conversation_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,3 @@
+ALTER TABLE messages
+    DROP COLUMN completion_tokens,
+    DROP COLUMN prompt_tokens;
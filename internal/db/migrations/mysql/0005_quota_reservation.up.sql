@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN reserved_quota BIGINT NOT NULL DEFAULT 0 AFTER used_quota;
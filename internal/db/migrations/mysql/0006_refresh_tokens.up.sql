@@ -0,0 +1,13 @@
+CREATE TABLE refresh_tokens (
+    token_id   INT AUTO_INCREMENT PRIMARY KEY,
+    user_id    INT         NOT NULL,
+    family_id  VARCHAR(64) NOT NULL,
+    token_hash CHAR(64)    NOT NULL,
+    expires_at DATETIME    NOT NULL,
+    used_at    DATETIME    NULL,
+    revoked_at DATETIME    NULL,
+    created_at DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_refresh_tokens_hash (token_hash),
+    KEY idx_refresh_tokens_family (family_id),
+    KEY idx_refresh_tokens_user (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
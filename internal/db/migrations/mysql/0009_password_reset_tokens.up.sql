@@ -0,0 +1,10 @@
+CREATE TABLE password_reset_tokens (
+    token_id   INT AUTO_INCREMENT PRIMARY KEY,
+    user_id    INT      NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at    DATETIME NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_password_reset_tokens_hash (token_hash),
+    KEY idx_password_reset_tokens_user (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
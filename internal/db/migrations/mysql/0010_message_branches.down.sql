@@ -0,0 +1,2 @@
+ALTER TABLE conversations DROP COLUMN active_message_id;
+ALTER TABLE messages DROP KEY idx_messages_parent, DROP COLUMN parent_id;
@@ -0,0 +1,16 @@
+ALTER TABLE messages
+    ADD COLUMN parent_id INT NULL AFTER conversation_id,
+    ADD KEY idx_messages_parent (parent_id);
+ALTER TABLE conversations ADD COLUMN active_message_id INT NULL AFTER llm_model;
+UPDATE messages m
+JOIN (
+    SELECT cur.message_id, MAX(prev.message_id) AS prev_id
+    FROM messages cur
+    JOIN messages prev ON prev.conversation_id = cur.conversation_id AND prev.message_id < cur.message_id
+    GROUP BY cur.message_id
+) p ON p.message_id = m.message_id
+SET m.parent_id = p.prev_id;
+UPDATE conversations c
+SET c.active_message_id = (
+    SELECT MAX(m.message_id) FROM messages m WHERE m.conversation_id = c.conversation_id
+);
@@ -0,0 +1 @@
+ALTER TABLE conversations DROP COLUMN forked_from_message_id, DROP COLUMN forked_from_conversation_id;
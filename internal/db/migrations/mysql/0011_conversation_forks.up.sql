@@ -0,0 +1,3 @@
+ALTER TABLE conversations
+    ADD COLUMN forked_from_conversation_id INT NULL AFTER active_message_id,
+    ADD COLUMN forked_from_message_id INT NULL AFTER forked_from_conversation_id;
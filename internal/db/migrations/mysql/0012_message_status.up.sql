@@ -0,0 +1 @@
+ALTER TABLE messages ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'COMPLETED' AFTER content;
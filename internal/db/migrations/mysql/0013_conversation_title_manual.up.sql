@@ -0,0 +1 @@
+ALTER TABLE conversations ADD COLUMN title_manual TINYINT(1) NOT NULL DEFAULT 1 AFTER title;
@@ -0,0 +1 @@
+ALTER TABLE conversations DROP KEY idx_conversations_status_deleted, DROP COLUMN deleted_at;
@@ -0,0 +1,4 @@
+ALTER TABLE conversations
+    ADD COLUMN deleted_at DATETIME NULL AFTER status,
+    ADD KEY idx_conversations_status_deleted (status, deleted_at);
+UPDATE conversations SET deleted_at = UTC_TIMESTAMP() WHERE status = 'DELETED';
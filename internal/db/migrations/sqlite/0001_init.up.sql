@@ -0,0 +1,53 @@
+CREATE TABLE IF NOT EXISTS users (
+    user_id     INTEGER PRIMARY KEY AUTOINCREMENT,
+    username    TEXT    NOT NULL UNIQUE,
+    password    TEXT    NOT NULL,
+    nickname    TEXT    NOT NULL DEFAULT '',
+    role        TEXT    NOT NULL DEFAULT 'USER',
+    status      INTEGER NOT NULL DEFAULT 1,
+    total_quota INTEGER NOT NULL DEFAULT 0,
+    used_quota  INTEGER NOT NULL DEFAULT 0,
+    created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS prompt_presets (
+    prompt_preset_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name             TEXT    NOT NULL,
+    description      TEXT    NOT NULL DEFAULT '',
+    content          TEXT    NOT NULL,
+    created_at       DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS conversations (
+    conversation_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id         INTEGER NOT NULL,
+    title           TEXT    NOT NULL DEFAULT '',
+    status          TEXT    NOT NULL DEFAULT 'ACTIVE',
+    llm_model       TEXT    NOT NULL DEFAULT '',
+    system_prompt   INTEGER NULL,
+    created_at      DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_conversations_user ON conversations (user_id);
+
+CREATE TABLE IF NOT EXISTS messages (
+    message_id      INTEGER PRIMARY KEY AUTOINCREMENT,
+    conversation_id INTEGER NOT NULL,
+    sender_type     INTEGER NOT NULL,
+    content_type    TEXT    NOT NULL DEFAULT 'TEXT',
+    content         TEXT    NOT NULL,
+    token_total     INTEGER NOT NULL DEFAULT 0,
+    created_at      DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+CREATE INDEX IF NOT EXISTS idx_messages_conversation ON messages (conversation_id, created_at);
+
+CREATE TABLE IF NOT EXISTS message_attachments (
+    attachment_id   INTEGER PRIMARY KEY AUTOINCREMENT,
+    message_id      INTEGER NOT NULL,
+    attachment_type TEXT    NOT NULL,
+    mime_type       TEXT    NOT NULL DEFAULT '',
+    storage_type    TEXT    NOT NULL DEFAULT 'LOCAL',
+    url_or_path     TEXT    NOT NULL,
+    duration_ms     REAL    NULL,
+    created_at      DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_message_attachments_message ON message_attachments (message_id);
@@ -0,0 +1,9 @@
+CREATE TABLE conversation_summaries (
+    summary_id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    conversation_id    INTEGER NOT NULL,
+    content            TEXT    NOT NULL,
+    covered_message_id INTEGER NOT NULL,
+    token_total        INTEGER NOT NULL DEFAULT 0,
+    created_at         DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_conversation_summaries_conversation ON conversation_summaries (conversation_id);
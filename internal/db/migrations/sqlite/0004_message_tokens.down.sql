@@ -0,0 +1,2 @@
+ALTER TABLE messages DROP COLUMN completion_tokens;
+ALTER TABLE messages DROP COLUMN prompt_tokens;
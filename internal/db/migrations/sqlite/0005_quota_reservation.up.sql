@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN reserved_quota INTEGER NOT NULL DEFAULT 0;
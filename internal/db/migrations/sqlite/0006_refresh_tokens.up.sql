@@ -0,0 +1,12 @@
+CREATE TABLE refresh_tokens (
+    token_id   INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    INTEGER NOT NULL,
+    family_id  TEXT    NOT NULL,
+    token_hash TEXT    NOT NULL UNIQUE,
+    expires_at DATETIME NOT NULL,
+    used_at    DATETIME NULL,
+    revoked_at DATETIME NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX idx_refresh_tokens_family ON refresh_tokens (family_id);
+CREATE INDEX idx_refresh_tokens_user ON refresh_tokens (user_id);
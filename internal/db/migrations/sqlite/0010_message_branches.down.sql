@@ -0,0 +1,3 @@
+ALTER TABLE conversations DROP COLUMN active_message_id;
+DROP INDEX IF EXISTS idx_messages_parent;
+ALTER TABLE messages DROP COLUMN parent_id;
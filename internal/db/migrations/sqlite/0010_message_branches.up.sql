@@ -0,0 +1,12 @@
+ALTER TABLE messages ADD COLUMN parent_id INTEGER NULL;
+CREATE INDEX IF NOT EXISTS idx_messages_parent ON messages (parent_id);
+ALTER TABLE conversations ADD COLUMN active_message_id INTEGER NULL;
+UPDATE messages
+SET parent_id = (
+    SELECT MAX(p.message_id) FROM messages p
+    WHERE p.conversation_id = messages.conversation_id AND p.message_id < messages.message_id
+);
+UPDATE conversations
+SET active_message_id = (
+    SELECT MAX(m.message_id) FROM messages m WHERE m.conversation_id = conversations.conversation_id
+);
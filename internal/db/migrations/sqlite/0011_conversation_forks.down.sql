@@ -0,0 +1,2 @@
+ALTER TABLE conversations DROP COLUMN forked_from_message_id;
+ALTER TABLE conversations DROP COLUMN forked_from_conversation_id;
@@ -0,0 +1,2 @@
+ALTER TABLE conversations ADD COLUMN forked_from_conversation_id INTEGER NULL;
+ALTER TABLE conversations ADD COLUMN forked_from_message_id INTEGER NULL;
@@ -0,0 +1 @@
+ALTER TABLE messages ADD COLUMN status TEXT NOT NULL DEFAULT 'COMPLETED';
@@ -0,0 +1 @@
+ALTER TABLE conversations ADD COLUMN title_manual INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_conversations_status_deleted;
+ALTER TABLE conversations DROP COLUMN deleted_at;
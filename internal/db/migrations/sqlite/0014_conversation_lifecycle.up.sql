@@ -0,0 +1,3 @@
+ALTER TABLE conversations ADD COLUMN deleted_at DATETIME NULL;
+CREATE INDEX IF NOT EXISTS idx_conversations_status_deleted ON conversations (status, deleted_at);
+UPDATE conversations SET deleted_at = CURRENT_TIMESTAMP WHERE status = 'DELETED';
@@ -0,0 +1,220 @@
+// Package jwtauth 负责 JWT 的签发与校验，支持多密钥（按 kid 选择）轮换。
+package jwtauth
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"backend/internal/config"
+)
+
+// ErrInvalidToken 令牌无效或已过期。
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// Claims JWT 中存储的数据。
+type Claims struct {
+	Username     string `json:"username"`
+	UserID       int    `json:"uid"`
+	Role         string `json:"role"`
+	TokenVersion int    `json:"ver"`
+	jwt.RegisteredClaims
+}
+
+// Subject 令牌所代表的用户。
+type Subject struct {
+	UserID       int
+	Username     string
+	Role         string
+	TokenVersion int
+}
+
+type key struct {
+	id     string
+	method jwt.SigningMethod
+	sign   any // 签名密钥，nil 表示仅用于校验
+	verify any
+}
+
+// Manager 持有密钥集合，签发使用当前活动密钥，校验按令牌头部 kid 选择密钥。
+type Manager struct {
+	issuer string
+	ttl    time.Duration
+	active *key
+	keys   map[string]*key
+}
+
+// New 按配置加载密钥。
+func New(cfg config.AuthConfig) (*Manager, error) {
+	keySet := cfg.KeySet()
+	if len(keySet) == 0 {
+		return nil, errors.New("auth.secret or auth.keys is required")
+	}
+	m := &Manager{
+		issuer: cfg.Issuer,
+		ttl:    cfg.TokenTTL(),
+		keys:   make(map[string]*key, len(keySet)),
+	}
+	for _, kc := range keySet {
+		if kc.ID == "" {
+			return nil, errors.New("auth key kid is required")
+		}
+		if _, ok := m.keys[kc.ID]; ok {
+			return nil, fmt.Errorf("duplicate auth key kid: %s", kc.ID)
+		}
+		k, err := loadKey(kc)
+		if err != nil {
+			return nil, fmt.Errorf("auth key %s: %w", kc.ID, err)
+		}
+		m.keys[kc.ID] = k
+	}
+
+	activeID := cfg.ActiveKeyID
+	if activeID == "" {
+		activeID = keySet[0].ID
+	}
+	active, ok := m.keys[activeID]
+	if !ok {
+		return nil, fmt.Errorf("auth active_kid %s not found in keys", activeID)
+	}
+	if active.sign == nil {
+		return nil, fmt.Errorf("auth active key %s has no private key", activeID)
+	}
+	m.active = active
+	return m, nil
+}
+
+func loadKey(kc config.JWTKeyConfig) (*key, error) {
+	k := &key{id: kc.ID}
+	switch strings.ToUpper(kc.Algorithm) {
+	case "", "HS256":
+		if kc.Secret == "" {
+			return nil, errors.New("secret is required for HS256")
+		}
+		k.method = jwt.SigningMethodHS256
+		k.sign = []byte(kc.Secret)
+		k.verify = []byte(kc.Secret)
+	case "RS256":
+		k.method = jwt.SigningMethodRS256
+		if kc.PrivateKeyFile != "" {
+			data, err := os.ReadFile(kc.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			priv, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			k.sign = priv
+			k.verify = &priv.PublicKey
+		}
+		if kc.PublicKeyFile != "" {
+			data, err := os.ReadFile(kc.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			pub, err := jwt.ParseRSAPublicKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			k.verify = pub
+		}
+	case "EDDSA", "ED25519":
+		k.method = jwt.SigningMethodEdDSA
+		if kc.PrivateKeyFile != "" {
+			data, err := os.ReadFile(kc.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			priv, err := jwt.ParseEdPrivateKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			edPriv, ok := priv.(ed25519.PrivateKey)
+			if !ok {
+				return nil, errors.New("private key is not ed25519")
+			}
+			k.sign = edPriv
+			k.verify = edPriv.Public()
+		}
+		if kc.PublicKeyFile != "" {
+			data, err := os.ReadFile(kc.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			pub, err := jwt.ParseEdPublicKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			k.verify = pub
+		}
+	default:
+		return nil, fmt.Errorf("unsupported alg: %s", kc.Algorithm)
+	}
+	if k.verify == nil {
+		return nil, errors.New("private_key_file or public_key_file is required")
+	}
+	return k, nil
+}
+
+// TTL 返回令牌有效期。
+func (m *Manager) TTL() time.Duration {
+	return m.ttl
+}
+
+// Issue 使用活动密钥为用户签发令牌，返回令牌与过期时间。
+func (m *Manager) Issue(sub Subject) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(m.ttl)
+	claims := Claims{
+		Username:     sub.Username,
+		UserID:       sub.UserID,
+		Role:         sub.Role,
+		TokenVersion: sub.TokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.issuer,
+			Subject:   strconv.Itoa(sub.UserID),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	token := jwt.NewWithClaims(m.active.method, claims)
+	token.Header["kid"] = m.active.id
+	signed, err := token.SignedString(m.active.sign)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
+
+// Parse 校验令牌并返回其中的声明。未携带 kid 的令牌按活动密钥校验；
+// 令牌算法须与 kid 对应密钥的算法一致；不含用户ID的旧令牌视为无效。
+func (m *Manager) Parse(tokenString string) (*Claims, error) {
+	opts := []jwt.ParserOption{jwt.WithExpirationRequired()}
+	if m.issuer != "" {
+		opts = append(opts, jwt.WithIssuer(m.issuer))
+	}
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+		k := m.active
+		if kid, ok := token.Header["kid"].(string); ok {
+			if k, ok = m.keys[kid]; !ok {
+				return nil, fmt.Errorf("unknown kid: %s", kid)
+			}
+		}
+		if token.Method.Alg() != k.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %s", token.Method.Alg())
+		}
+		return k.verify, nil
+	}, opts...)
+	if err != nil || !token.Valid || claims.UserID <= 0 {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
@@ -0,0 +1,266 @@
+package jwtauth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"backend/internal/config"
+)
+
+// testKeys 测试用的 RSA 与 Ed25519 密钥及其 PEM 文件。
+type testKeys struct {
+	rsaPriv     *rsa.PrivateKey
+	rsaPrivFile string
+	rsaPubFile  string
+	rsaPubPEM   []byte
+	edPriv      ed25519.PrivateKey
+	edPrivFile  string
+	edPubFile   string
+}
+
+func writePEM(t *testing.T, dir, name, typ string, der []byte) (string, []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path, data
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+	dir := t.TempDir()
+	var k testKeys
+	var err error
+
+	k.rsaPriv, err = rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.rsaPrivFile, _ = writePEM(t, dir, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(k.rsaPriv))
+	der, err := x509.MarshalPKIXPublicKey(&k.rsaPriv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.rsaPubFile, k.rsaPubPEM = writePEM(t, dir, "rsa.pub.pem", "PUBLIC KEY", der)
+
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.edPriv = edPriv
+	der, err = x509.MarshalPKCS8PrivateKey(edPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.edPrivFile, _ = writePEM(t, dir, "ed.pem", "PRIVATE KEY", der)
+	der, err = x509.MarshalPKIXPublicKey(edPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.edPubFile, _ = writePEM(t, dir, "ed.pub.pem", "PUBLIC KEY", der)
+	return k
+}
+
+func mustNew(t *testing.T, cfg config.AuthConfig) *Manager {
+	t.Helper()
+	m, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// signToken 以任意算法、密钥与 kid 签发令牌，kid 为空时不写入头部。
+func signToken(t *testing.T, method jwt.SigningMethod, signKey any, kid string, claims jwt.Claims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(signKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func validClaims(uid int) Claims {
+	now := time.Now()
+	return Claims{
+		Username: "alice",
+		UserID:   uid,
+		Role:     "user",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "test",
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+}
+
+func TestIssueAndParse(t *testing.T) {
+	keys := newTestKeys(t)
+	tests := []struct {
+		name string
+		key  config.JWTKeyConfig
+	}{
+		{"HS256", config.JWTKeyConfig{ID: "hs", Secret: "s3cret"}},
+		{"RS256", config.JWTKeyConfig{ID: "rsa", Algorithm: "RS256", PrivateKeyFile: keys.rsaPrivFile}},
+		{"EdDSA", config.JWTKeyConfig{ID: "ed", Algorithm: "EdDSA", PrivateKeyFile: keys.edPrivFile}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mustNew(t, config.AuthConfig{Issuer: "test", Keys: []config.JWTKeyConfig{tt.key}})
+			token, expiresAt, err := m.Issue(Subject{UserID: 7, Username: "alice", Role: "admin", TokenVersion: 3})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := time.Until(expiresAt); d <= 0 || d > m.TTL() {
+				t.Errorf("expires in %v, ttl %v", d, m.TTL())
+			}
+			claims, err := m.Parse(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.UserID != 7 || claims.Username != "alice" || claims.Role != "admin" || claims.TokenVersion != 3 || claims.Subject != "7" {
+				t.Errorf("claims = %+v", claims)
+			}
+		})
+	}
+}
+
+func TestRotation(t *testing.T) {
+	keys := newTestKeys(t)
+	oldKey := config.JWTKeyConfig{ID: "2024", Secret: "old-secret"}
+	newKey := config.JWTKeyConfig{ID: "2025", Algorithm: "EdDSA", PrivateKeyFile: keys.edPrivFile}
+
+	before := mustNew(t, config.AuthConfig{Keys: []config.JWTKeyConfig{oldKey}})
+	oldToken, _, err := before.Issue(Subject{UserID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 轮换期间新密钥签发，旧密钥仍可校验。
+	during := mustNew(t, config.AuthConfig{ActiveKeyID: "2025", Keys: []config.JWTKeyConfig{oldKey, newKey}})
+	if _, err := during.Parse(oldToken); err != nil {
+		t.Errorf("old token during rotation: %v", err)
+	}
+	newToken, _, err := during.Issue(Subject{UserID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := before.Parse(newToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("new token on old manager err = %v", err)
+	}
+
+	// 旧密钥移除后其签发的令牌失效。
+	after := mustNew(t, config.AuthConfig{Keys: []config.JWTKeyConfig{newKey}})
+	if _, err := after.Parse(oldToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("rotated-out kid err = %v", err)
+	}
+	if _, err := after.Parse(newToken); err != nil {
+		t.Errorf("new token after rotation: %v", err)
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	keys := newTestKeys(t)
+	m := mustNew(t, config.AuthConfig{
+		Issuer:      "test",
+		ActiveKeyID: "hs",
+		Keys: []config.JWTKeyConfig{
+			{ID: "hs", Secret: "s3cret"},
+			{ID: "rsa", Algorithm: "RS256", PublicKeyFile: keys.rsaPubFile},
+			{ID: "ed", Algorithm: "EdDSA", PublicKeyFile: keys.edPubFile},
+		},
+	})
+	hsSecret := []byte("s3cret")
+
+	noExp := validClaims(1)
+	noExp.ExpiresAt = nil
+	expired := validClaims(1)
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	otherIssuer := validClaims(1)
+	otherIssuer.Issuer = "someone-else"
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"HS256 with kid", signToken(t, jwt.SigningMethodHS256, hsSecret, "hs", validClaims(1)), true},
+		{"no kid uses active key", signToken(t, jwt.SigningMethodHS256, hsSecret, "", validClaims(1)), true},
+		{"RS256 verified by public key", signToken(t, jwt.SigningMethodRS256, keys.rsaPriv, "rsa", validClaims(1)), true},
+		{"EdDSA verified by public key", signToken(t, jwt.SigningMethodEdDSA, keys.edPriv, "ed", validClaims(1)), true},
+		{"unknown kid", signToken(t, jwt.SigningMethodHS256, hsSecret, "gone", validClaims(1)), false},
+		{"wrong secret", signToken(t, jwt.SigningMethodHS256, []byte("guess"), "hs", validClaims(1)), false},
+		// 以公钥内容作为 HMAC 密钥伪造令牌，算法与 kid 对应密钥不一致须拒绝。
+		{"HS256 against RS256 key", signToken(t, jwt.SigningMethodHS256, keys.rsaPubPEM, "rsa", validClaims(1)), false},
+		{"HS256 against EdDSA key", signToken(t, jwt.SigningMethodHS256, hsSecret, "ed", validClaims(1)), false},
+		{"RS256 against HS256 key", signToken(t, jwt.SigningMethodRS256, keys.rsaPriv, "hs", validClaims(1)), false},
+		{"none algorithm", signToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "hs", validClaims(1)), false},
+		{"missing exp", signToken(t, jwt.SigningMethodHS256, hsSecret, "hs", noExp), false},
+		{"expired", signToken(t, jwt.SigningMethodHS256, hsSecret, "hs", expired), false},
+		{"other issuer", signToken(t, jwt.SigningMethodHS256, hsSecret, "hs", otherIssuer), false},
+		{"zero uid", signToken(t, jwt.SigningMethodHS256, hsSecret, "hs", validClaims(0)), false},
+		{"negative uid", signToken(t, jwt.SigningMethodHS256, hsSecret, "hs", validClaims(-1)), false},
+		{"malformed", "not.a.token", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := m.Parse(tt.token)
+			if tt.valid {
+				if err != nil || claims.UserID != 1 {
+					t.Fatalf("claims = %+v, err %v", claims, err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidToken) || claims != nil {
+				t.Fatalf("claims = %+v, err %v, want ErrInvalidToken", claims, err)
+			}
+		})
+	}
+}
+
+func TestNewRejectsBadConfig(t *testing.T) {
+	keys := newTestKeys(t)
+	tests := []struct {
+		name    string
+		cfg     config.AuthConfig
+		wantErr string
+	}{
+		{"no keys", config.AuthConfig{}, "required"},
+		{"missing kid", config.AuthConfig{Keys: []config.JWTKeyConfig{{Secret: "s"}}}, "kid is required"},
+		{"duplicate kid", config.AuthConfig{Keys: []config.JWTKeyConfig{{ID: "a", Secret: "s"}, {ID: "a", Secret: "t"}}}, "duplicate"},
+		{"HS256 without secret", config.AuthConfig{Keys: []config.JWTKeyConfig{{ID: "a"}}}, "secret is required"},
+		{"unsupported alg", config.AuthConfig{Keys: []config.JWTKeyConfig{{ID: "a", Algorithm: "ES256"}}}, "unsupported alg"},
+		{"RS256 without key files", config.AuthConfig{Keys: []config.JWTKeyConfig{{ID: "a", Algorithm: "RS256"}}}, "key_file is required"},
+		{"unknown active kid", config.AuthConfig{ActiveKeyID: "b", Keys: []config.JWTKeyConfig{{ID: "a", Secret: "s"}}}, "not found"},
+		{
+			"active key without private key",
+			config.AuthConfig{Keys: []config.JWTKeyConfig{{ID: "a", Algorithm: "EdDSA", PublicKeyFile: keys.edPubFile}}},
+			"no private key",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
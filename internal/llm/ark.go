@@ -3,6 +3,7 @@ package llm
 import (
 	"context"
 	"errors"
+	"io"
 	"strings"
 
 	"backend/internal/config"
@@ -11,16 +12,16 @@ import (
 	arkmodel "github.com/volcengine/volcengine-go-sdk/service/arkruntime/model"
 )
 
-type Client struct {
+// ArkClient 火山方舟 Ark provider。
+type ArkClient struct {
 	ark   *arkruntime.Client
 	model string
 }
 
-var global *Client
-
-func Init(cfg config.LLMConfig) error {
+// NewArk 创建 Ark provider。
+func NewArk(cfg config.LLMModelConfig) (*ArkClient, error) {
 	if cfg.Model == "" {
-		return errors.New("llm model is required")
+		return nil, errors.New("llm model is required")
 	}
 
 	opts := make([]arkruntime.ConfigOption, 0, 2)
@@ -37,41 +38,131 @@ func Init(cfg config.LLMConfig) error {
 	} else if cfg.AK != "" && cfg.SK != "" {
 		arkClient = arkruntime.NewClientWithAkSk(cfg.AK, cfg.SK, opts...)
 	} else {
-		return errors.New("llm credentials missing: api_key or ak/sk required")
+		return nil, errors.New("llm credentials missing: api_key or ak/sk required")
 	}
 
-	global = &Client{
+	return &ArkClient{
 		ark:   arkClient,
 		model: cfg.Model,
-	}
-	return nil
-}
-
-func Get() *Client {
-	return global
+	}, nil
 }
 
-func (c *Client) Model() string {
+func (c *ArkClient) Model() string {
 	if c == nil {
 		return ""
 	}
 	return c.model
 }
 
-func (c *Client) ChatCompletion(ctx context.Context, messages []*arkmodel.ChatCompletionMessage) (string, arkmodel.Usage, error) {
+func (c *ArkClient) ChatCompletion(ctx context.Context, messages []Message) (string, Usage, error) {
 	req := arkmodel.ChatCompletionRequest{
 		Model:    c.model,
-		Messages: messages,
+		Messages: toArkMessages(messages),
 	}
 
 	resp, err := c.ark.CreateChatCompletion(ctx, req)
 	if err != nil {
-		return "", arkmodel.Usage{}, err
+		return "", Usage{}, err
 	}
 	if len(resp.Choices) == 0 || resp.Choices[0] == nil {
-		return "", arkmodel.Usage{}, errors.New("empty llm response")
+		return "", Usage{}, errors.New("empty llm response")
+	}
+	return extractContent(resp.Choices[0].Message.Content), fromArkUsage(resp.Usage), nil
+}
+
+func (c *ArkClient) ChatCompletionStream(ctx context.Context, messages []Message, onDelta func(string)) (string, Usage, error) {
+	req := arkmodel.ChatCompletionRequest{
+		Model:    c.model,
+		Messages: toArkMessages(messages),
+		StreamOptions: &arkmodel.StreamOptions{
+			IncludeUsage: true,
+		},
+	}
+
+	stream, err := c.ark.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	defer stream.Close()
+
+	var (
+		b     strings.Builder
+		usage Usage
+	)
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return b.String(), usage, err
+		}
+		if chunk.Usage != nil {
+			usage = fromArkUsage(*chunk.Usage)
+		}
+		for _, choice := range chunk.Choices {
+			if choice == nil || choice.Delta.Content == "" {
+				continue
+			}
+			b.WriteString(choice.Delta.Content)
+			if onDelta != nil {
+				onDelta(choice.Delta.Content)
+			}
+		}
+	}
+	return b.String(), usage, nil
+}
+
+func toArkMessages(messages []Message) []*arkmodel.ChatCompletionMessage {
+	out := make([]*arkmodel.ChatCompletionMessage, 0, len(messages))
+	for _, msg := range messages {
+		if len(msg.Parts) == 0 {
+			text := msg.Content
+			out = append(out, &arkmodel.ChatCompletionMessage{
+				Role: msg.Role,
+				Content: &arkmodel.ChatCompletionMessageContent{
+					StringValue: &text,
+				},
+			})
+			continue
+		}
+
+		parts := make([]*arkmodel.ChatCompletionMessageContentPart, 0, len(msg.Parts))
+		for _, part := range msg.Parts {
+			switch part.Type {
+			case PartImageURL:
+				parts = append(parts, &arkmodel.ChatCompletionMessageContentPart{
+					Type:     arkmodel.ChatCompletionMessageContentPartTypeImageURL,
+					ImageURL: &arkmodel.ChatMessageImageURL{URL: part.URL},
+				})
+			case PartVideoURL:
+				parts = append(parts, &arkmodel.ChatCompletionMessageContentPart{
+					Type:     arkmodel.ChatCompletionMessageContentPartTypeVideoURL,
+					VideoURL: &arkmodel.ChatMessageVideoURL{URL: part.URL},
+				})
+			default:
+				parts = append(parts, &arkmodel.ChatCompletionMessageContentPart{
+					Type: arkmodel.ChatCompletionMessageContentPartTypeText,
+					Text: part.Text,
+				})
+			}
+		}
+		out = append(out, &arkmodel.ChatCompletionMessage{
+			Role: msg.Role,
+			Content: &arkmodel.ChatCompletionMessageContent{
+				ListValue: parts,
+			},
+		})
+	}
+	return out
+}
+
+func fromArkUsage(u arkmodel.Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
 	}
-	return extractContent(resp.Choices[0].Message.Content), resp.Usage, nil
 }
 
 func extractContent(content *arkmodel.ChatCompletionMessageContent) string {
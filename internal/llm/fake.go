@@ -0,0 +1,85 @@
+package llm
+
+import (
+	"context"
+	"sync"
+	"unicode/utf8"
+)
+
+// Fake 进程内假 provider，不访问网络，供测试与本地联调使用。
+// Reply 为空时回显最后一条用户消息；Err 非空时所有调用直接返回该错误。
+type Fake struct {
+	mu    sync.Mutex
+	model string
+	Reply string
+	Err   error
+	calls [][]Message
+}
+
+// NewFake 创建假 provider。
+func NewFake(model, reply string) *Fake {
+	return &Fake{model: model, Reply: reply}
+}
+
+func (f *Fake) Model() string {
+	return f.model
+}
+
+// Calls 返回历次调用收到的消息，便于断言上下文构造。
+func (f *Fake) Calls() [][]Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([][]Message, len(f.calls))
+	copy(out, f.calls)
+	return out
+}
+
+func (f *Fake) ChatCompletion(ctx context.Context, messages []Message) (string, Usage, error) {
+	return f.ChatCompletionStream(ctx, messages, nil)
+}
+
+func (f *Fake) ChatCompletionStream(ctx context.Context, messages []Message, onDelta func(string)) (string, Usage, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, messages)
+	reply, failure := f.Reply, f.Err
+	f.mu.Unlock()
+
+	if failure != nil {
+		return "", Usage{}, failure
+	}
+	if reply == "" {
+		reply = "echo: " + lastUserText(messages)
+	}
+
+	prompt := 0
+	for _, msg := range messages {
+		prompt += utf8.RuneCountInString(msg.Text())
+	}
+	usage := Usage{
+		PromptTokens:     prompt,
+		CompletionTokens: utf8.RuneCountInString(reply),
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+
+	if onDelta != nil {
+		for i, r := range reply {
+			if err := ctx.Err(); err != nil {
+				partial := reply[:i]
+				usage.CompletionTokens = utf8.RuneCountInString(partial)
+				usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+				return partial, usage, err
+			}
+			onDelta(string(r))
+		}
+	}
+	return reply, usage, nil
+}
+
+func lastUserText(messages []Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == RoleUser {
+			return messages[i].Text()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,232 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"backend/internal/config"
+)
+
+// OpenAIClient OpenAI 兼容协议的 provider，可对接 vLLM、Ollama 等 /v1/chat/completions 端点。
+type OpenAIClient struct {
+	baseURL string
+	apiKey  string
+	model   string
+	http    *http.Client
+}
+
+// NewOpenAI 创建 OpenAI 兼容 provider，base_url 形如 http://127.0.0.1:8000/v1。
+func NewOpenAI(cfg config.LLMModelConfig) (*OpenAIClient, error) {
+	if cfg.Model == "" {
+		return nil, errors.New("llm model is required")
+	}
+	if cfg.BaseURL == "" {
+		return nil, errors.New("llm base_url is required for openai provider")
+	}
+	return &OpenAIClient{
+		baseURL: strings.TrimRight(cfg.BaseURL, "/"),
+		apiKey:  cfg.APIKey,
+		model:   cfg.Model,
+		http:    &http.Client{},
+	}, nil
+}
+
+type openAIRequest struct {
+	Model         string               `json:"model"`
+	Messages      []openAIMessage      `json:"messages"`
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content any    `json:"content"`
+}
+
+type openAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIMediaURL `json:"image_url,omitempty"`
+	VideoURL *openAIMediaURL `json:"video_url,omitempty"`
+}
+
+type openAIMediaURL struct {
+	URL string `json:"url"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (c *OpenAIClient) Model() string {
+	if c == nil {
+		return ""
+	}
+	return c.model
+}
+
+func (c *OpenAIClient) ChatCompletion(ctx context.Context, messages []Message) (string, Usage, error) {
+	resp, err := c.do(ctx, openAIRequest{
+		Model:    c.model,
+		Messages: toOpenAIMessages(messages),
+	})
+	if err != nil {
+		return "", Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	var result openAIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", Usage{}, err
+	}
+	if len(result.Choices) == 0 {
+		return "", Usage{}, errors.New("empty llm response")
+	}
+	return result.Choices[0].Message.Content, fromOpenAIUsage(result.Usage), nil
+}
+
+func (c *OpenAIClient) ChatCompletionStream(ctx context.Context, messages []Message, onDelta func(string)) (string, Usage, error) {
+	resp, err := c.do(ctx, openAIRequest{
+		Model:         c.model,
+		Messages:      toOpenAIMessages(messages),
+		Stream:        true,
+		StreamOptions: &openAIStreamOptions{IncludeUsage: true},
+	})
+	if err != nil {
+		return "", Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	buf := make([]byte, 0, 64*1024)
+	scanner.Buffer(buf, 5*1024*1024)
+
+	var (
+		b     strings.Builder
+		usage Usage
+	)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if payload == "[DONE]" {
+			break
+		}
+		var chunk openAIResponse
+		if err := json.Unmarshal([]byte(payload), &chunk); err != nil {
+			return b.String(), usage, err
+		}
+		if chunk.Error != nil {
+			return b.String(), usage, errors.New(chunk.Error.Message)
+		}
+		if chunk.Usage != nil {
+			usage = fromOpenAIUsage(chunk.Usage)
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content == "" {
+				continue
+			}
+			b.WriteString(choice.Delta.Content)
+			if onDelta != nil {
+				onDelta(choice.Delta.Content)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return b.String(), usage, err
+	}
+	return b.String(), usage, nil
+}
+
+func (c *OpenAIClient) do(ctx context.Context, req openAIRequest) (*http.Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	if req.Stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("llm request failed: %s: %s", resp.Status, string(b))
+	}
+	return resp, nil
+}
+
+func toOpenAIMessages(messages []Message) []openAIMessage {
+	out := make([]openAIMessage, 0, len(messages))
+	for _, msg := range messages {
+		if len(msg.Parts) == 0 {
+			out = append(out, openAIMessage{Role: msg.Role, Content: msg.Content})
+			continue
+		}
+		parts := make([]openAIContentPart, 0, len(msg.Parts))
+		for _, part := range msg.Parts {
+			switch part.Type {
+			case PartImageURL:
+				parts = append(parts, openAIContentPart{Type: PartImageURL, ImageURL: &openAIMediaURL{URL: part.URL}})
+			case PartVideoURL:
+				parts = append(parts, openAIContentPart{Type: PartVideoURL, VideoURL: &openAIMediaURL{URL: part.URL}})
+			default:
+				parts = append(parts, openAIContentPart{Type: PartText, Text: part.Text})
+			}
+		}
+		out = append(out, openAIMessage{Role: msg.Role, Content: parts})
+	}
+	return out
+}
+
+func fromOpenAIUsage(u *openAIUsage) Usage {
+	if u == nil {
+		return Usage{}
+	}
+	return Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+}
@@ -0,0 +1,153 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"backend/internal/config"
+)
+
+func newTestOpenAI(t *testing.T, handler http.HandlerFunc) *OpenAIClient {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	c, err := NewOpenAI(config.LLMModelConfig{Model: "m", BaseURL: srv.URL + "/v1/", APIKey: "key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func writeSSE(w http.ResponseWriter, payloads ...string) {
+	for _, p := range payloads {
+		fmt.Fprintf(w, "data: %s\n\n", p)
+	}
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func TestOpenAIStreamDeltasAndUsage(t *testing.T) {
+	var req openAIRequest
+	c := newTestOpenAI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer key" {
+			t.Errorf("authorization = %q", got)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		writeSSE(w,
+			`{"choices":[{"delta":{"content":"Hel"}}]}`,
+			`{"choices":[{"delta":{"content":""}}]}`,
+			`{"choices":[{"delta":{"content":"lo"}}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":7,"completion_tokens":2,"total_tokens":9}}`,
+			`[DONE]`,
+			`{"choices":[{"delta":{"content":"ignored"}}]}`,
+		)
+	})
+
+	var deltas []string
+	reply, usage, err := c.ChatCompletionStream(context.Background(), []Message{{Role: RoleUser, Content: "hi"}}, func(d string) {
+		deltas = append(deltas, d)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "Hello" {
+		t.Errorf("reply = %q", reply)
+	}
+	if strings.Join(deltas, "|") != "Hel|lo" {
+		t.Errorf("deltas = %q", deltas)
+	}
+	if usage != (Usage{PromptTokens: 7, CompletionTokens: 2, TotalTokens: 9}) {
+		t.Errorf("usage = %+v", usage)
+	}
+	if !req.Stream || req.StreamOptions == nil || !req.StreamOptions.IncludeUsage || req.Model != "m" {
+		t.Errorf("request = %+v", req)
+	}
+}
+
+func TestOpenAIChatCompletion(t *testing.T) {
+	c := newTestOpenAI(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"pong"}}],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}`)
+	})
+	reply, usage, err := c.ChatCompletion(context.Background(), []Message{{Role: RoleUser, Content: "ping"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "pong" || usage.Total() != 4 {
+		t.Errorf("reply = %q, usage = %+v", reply, usage)
+	}
+}
+
+func TestOpenAIUpstreamErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		partial string
+		wantErr string
+	}{
+		{
+			name: "http status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "rate limited", http.StatusTooManyRequests)
+			},
+			wantErr: "429",
+		},
+		{
+			name: "error chunk",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				writeSSE(w, `{"choices":[{"delta":{"content":"par"}}]}`, `{"error":{"message":"model overloaded"}}`)
+			},
+			partial: "par",
+			wantErr: "model overloaded",
+		},
+		{
+			name: "malformed chunk",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				writeSSE(w, `{"choices":[{"delta":{"content":"par"}}]}`, `{not json`)
+			},
+			partial: "par",
+			wantErr: "invalid character",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestOpenAI(t, tt.handler)
+			reply, _, err := c.ChatCompletionStream(context.Background(), []Message{{Role: RoleUser, Content: "hi"}}, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want containing %q", err, tt.wantErr)
+			}
+			if reply != tt.partial {
+				t.Errorf("partial reply = %q, want %q", reply, tt.partial)
+			}
+		})
+	}
+}
+
+func TestOpenAIStreamCancel(t *testing.T) {
+	c := newTestOpenAI(t, func(w http.ResponseWriter, r *http.Request) {
+		writeSSE(w, `{"choices":[{"delta":{"content":"first"}}]}`)
+		<-r.Context().Done()
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reply, _, err := c.ChatCompletionStream(ctx, []Message{{Role: RoleUser, Content: "hi"}}, func(string) { cancel() })
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if reply != "first" {
+		t.Errorf("partial reply = %q", reply)
+	}
+}
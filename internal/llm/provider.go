@@ -0,0 +1,260 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"backend/internal/config"
+)
+
+// 消息角色。
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// 多模态内容片段类型。
+const (
+	PartText     = "text"
+	PartImageURL = "image_url"
+	PartVideoURL = "video_url"
+)
+
+// ContentPart 多模态消息中的一个片段，Text 与 URL 按 Type 二选一。
+type ContentPart struct {
+	Type string
+	Text string
+	URL  string
+}
+
+// Message 与具体厂商无关的对话消息；Parts 非空时按多模态发送，否则使用 Content。
+type Message struct {
+	Role    string
+	Content string
+	Parts   []ContentPart
+}
+
+// Text 返回消息中的纯文本内容。
+func (m Message) Text() string {
+	if len(m.Parts) == 0 {
+		return m.Content
+	}
+	var b strings.Builder
+	for _, part := range m.Parts {
+		if part.Type == PartText {
+			b.WriteString(part.Text)
+		}
+	}
+	return b.String()
+}
+
+// Usage 一次调用的 token 用量。
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+}
+
+// Total 返回总用量，上游未给出 total 时以 prompt + completion 计。
+func (u Usage) Total() int {
+	if u.TotalTokens > 0 {
+		return u.TotalTokens
+	}
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// Provider 模型服务提供方。
+type Provider interface {
+	// Model 返回调用时使用的模型名。
+	Model() string
+	// ChatCompletion 一次性返回完整回复与用量。
+	ChatCompletion(ctx context.Context, messages []Message) (string, Usage, error)
+	// ChatCompletionStream 流式调用，每收到一段增量文本即回调 onDelta，结束后返回完整回复与用量。
+	ChatCompletionStream(ctx context.Context, messages []Message, onDelta func(string)) (string, Usage, error)
+}
+
+// 支持的 provider 名称（config.LLMModelConfig.Provider）。
+const (
+	ProviderArk    = "ark"
+	ProviderOpenAI = "openai"
+	ProviderFake   = "fake"
+)
+
+// ModelInfo 模型目录中的一项。
+type ModelInfo struct {
+	Name          string
+	Provider      string
+	ContextWindow int
+	ContextBudget int
+	Multimodal    bool
+	AllowedRoles  []string
+}
+
+// PromptBudget 返回构造上下文时可用的 token 预算，0 表示不限制。
+func (m ModelInfo) PromptBudget() int {
+	if m.ContextBudget > 0 {
+		return m.ContextBudget
+	}
+	return m.ContextWindow * 3 / 4
+}
+
+// AllowsRole 判断角色是否可使用该模型。
+func (m ModelInfo) AllowsRole(role string) bool {
+	if len(m.AllowedRoles) == 0 {
+		return true
+	}
+	for _, r := range m.AllowedRoles {
+		if strings.EqualFold(r, role) {
+			return true
+		}
+	}
+	return false
+}
+
+type entry struct {
+	info     ModelInfo
+	provider Provider
+}
+
+// Registry 模型目录，按名称路由到对应 provider，可并发使用。零值为空目录。
+type Registry struct {
+	mu           sync.RWMutex
+	entries      map[string]entry
+	order        []string
+	defaultModel string
+}
+
+// New 按配置创建 provider，provider 为空时默认使用 Ark。
+func New(cfg config.LLMModelConfig) (Provider, error) {
+	if cfg.Model == "" {
+		return nil, errors.New("llm model is required")
+	}
+	switch strings.ToLower(cfg.Provider) {
+	case "", ProviderArk:
+		return NewArk(cfg)
+	case ProviderOpenAI:
+		return NewOpenAI(cfg)
+	case ProviderFake:
+		return NewFake(cfg.Model, ""), nil
+	default:
+		return nil, fmt.Errorf("unknown llm provider: %s", cfg.Provider)
+	}
+}
+
+// NewRegistry 按配置创建模型目录。
+func NewRegistry(cfg config.LLMConfig) (*Registry, error) {
+	catalog := cfg.Catalog()
+	if len(catalog) == 0 {
+		return nil, errors.New("llm model is required")
+	}
+
+	entries := make(map[string]entry, len(catalog))
+	names := make([]string, 0, len(catalog))
+	for _, m := range catalog {
+		name := m.Name
+		if name == "" {
+			name = m.Model
+		}
+		if _, ok := entries[name]; ok {
+			return nil, fmt.Errorf("duplicate llm model name: %s", name)
+		}
+		p, err := New(m)
+		if err != nil {
+			return nil, fmt.Errorf("llm model %s: %w", name, err)
+		}
+		providerName := strings.ToLower(m.Provider)
+		if providerName == "" {
+			providerName = ProviderArk
+		}
+		entries[name] = entry{
+			info: ModelInfo{
+				Name:          name,
+				Provider:      providerName,
+				ContextWindow: m.ContextWindow,
+				ContextBudget: m.ContextBudget,
+				Multimodal:    m.Multimodal,
+				AllowedRoles:  m.AllowedRoles,
+			},
+			provider: p,
+		}
+		names = append(names, name)
+	}
+
+	def := cfg.DefaultModel
+	if def == "" {
+		def = names[0]
+	}
+	if _, ok := entries[def]; !ok {
+		return nil, fmt.Errorf("llm default_model %s not found in models", def)
+	}
+	return &Registry{entries: entries, order: names, defaultModel: def}, nil
+}
+
+// Get 获取默认模型的 provider，目录为空时返回 nil。
+func (r *Registry) Get() Provider {
+	p, _, _ := r.Lookup("")
+	return p
+}
+
+// Lookup 按名称查找模型，name 为空时返回默认模型。
+func (r *Registry) Lookup(name string) (Provider, ModelInfo, bool) {
+	if r == nil {
+		return nil, ModelInfo{}, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if name == "" {
+		name = r.defaultModel
+	}
+	e, ok := r.entries[name]
+	if !ok {
+		return nil, ModelInfo{}, false
+	}
+	return e.provider, e.info, true
+}
+
+// DefaultModel 返回默认模型名。
+func (r *Registry) DefaultModel() string {
+	if r == nil {
+		return ""
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.defaultModel
+}
+
+// Models 按配置顺序返回模型目录。
+func (r *Registry) Models() []ModelInfo {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]ModelInfo, 0, len(r.order))
+	for _, name := range r.order {
+		out = append(out, r.entries[name].info)
+	}
+	return out
+}
+
+// Set 以 provider 的模型名注册并设为默认模型，便于测试注入 Fake。
+func (r *Registry) Set(p Provider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.entries == nil {
+		r.entries = make(map[string]entry)
+	}
+	name := p.Model()
+	if _, ok := r.entries[name]; !ok {
+		r.order = append(r.order, name)
+	}
+	r.entries[name] = entry{
+		info:     ModelInfo{Name: name, Provider: ProviderFake, Multimodal: true},
+		provider: p,
+	}
+	r.defaultModel = name
+}
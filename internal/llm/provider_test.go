@@ -0,0 +1,156 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"backend/internal/config"
+)
+
+func TestFakeReplies(t *testing.T) {
+	messages := []Message{
+		{Role: RoleSystem, Content: "sys"},
+		{Role: RoleUser, Parts: []ContentPart{{Type: PartText, Text: "hi"}, {Type: PartImageURL, URL: "http://x/img"}}},
+	}
+
+	echo := NewFake("fake", "")
+	reply, usage, err := echo.ChatCompletion(context.Background(), messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "echo: hi" {
+		t.Errorf("reply = %q", reply)
+	}
+	if usage.PromptTokens != 5 || usage.CompletionTokens != 8 || usage.Total() != 13 {
+		t.Errorf("usage = %+v", usage)
+	}
+	if calls := echo.Calls(); len(calls) != 1 || len(calls[0]) != 2 {
+		t.Errorf("calls = %v", calls)
+	}
+
+	fixed := NewFake("fake", "你好")
+	var deltas []string
+	reply, _, err = fixed.ChatCompletionStream(context.Background(), messages, func(d string) { deltas = append(deltas, d) })
+	if err != nil || reply != "你好" || strings.Join(deltas, "|") != "你|好" {
+		t.Errorf("reply = %q, deltas = %q, err = %v", reply, deltas, err)
+	}
+
+	failing := NewFake("fake", "unused")
+	failing.Err = errors.New("boom")
+	if _, _, err := failing.ChatCompletion(context.Background(), messages); err != failing.Err {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestFakeStreamCancel(t *testing.T) {
+	f := NewFake("fake", "abcdef")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n := 0
+	reply, usage, err := f.ChatCompletionStream(ctx, []Message{{Role: RoleUser, Content: "x"}}, func(string) {
+		if n++; n == 2 {
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v", err)
+	}
+	if reply != "ab" || usage.CompletionTokens != 2 || usage.TotalTokens != usage.PromptTokens+2 {
+		t.Errorf("reply = %q, usage = %+v", reply, usage)
+	}
+}
+
+func TestRegistrySet(t *testing.T) {
+	var r Registry
+	if r.Get() != nil || r.DefaultModel() != "" {
+		t.Fatal("zero registry should be empty")
+	}
+	a, b := NewFake("a", ""), NewFake("b", "")
+	r.Set(a)
+	r.Set(b)
+	r.Set(a)
+	if r.DefaultModel() != "a" || r.Get() != Provider(a) {
+		t.Errorf("default = %q", r.DefaultModel())
+	}
+	p, info, ok := r.Lookup("b")
+	if !ok || p != Provider(b) || info.Provider != ProviderFake {
+		t.Errorf("lookup b = %v %+v %v", p, info, ok)
+	}
+	if _, _, ok := r.Lookup("missing"); ok {
+		t.Error("lookup missing should fail")
+	}
+	var names []string
+	for _, m := range r.Models() {
+		names = append(names, m.Name)
+	}
+	if strings.Join(names, ",") != "a,b" {
+		t.Errorf("models = %v", names)
+	}
+}
+
+func TestNewRegistry(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.LLMConfig
+		wantDef string
+		wantErr string
+	}{
+		{
+			name: "default is first model",
+			cfg: config.LLMConfig{Models: []config.LLMModelConfig{
+				{Name: "small", Provider: "fake", Model: "s"},
+				{Name: "large", Provider: "fake", Model: "l"},
+			}},
+			wantDef: "small",
+		},
+		{
+			name: "explicit default",
+			cfg: config.LLMConfig{DefaultModel: "large", Models: []config.LLMModelConfig{
+				{Name: "small", Provider: "fake", Model: "s"},
+				{Name: "large", Provider: "fake", Model: "l"},
+			}},
+			wantDef: "large",
+		},
+		{
+			name: "duplicate name",
+			cfg: config.LLMConfig{Models: []config.LLMModelConfig{
+				{Name: "x", Provider: "fake", Model: "s"},
+				{Name: "x", Provider: "fake", Model: "l"},
+			}},
+			wantErr: "duplicate",
+		},
+		{
+			name: "unknown default",
+			cfg: config.LLMConfig{DefaultModel: "nope", Models: []config.LLMModelConfig{
+				{Name: "x", Provider: "fake", Model: "s"},
+			}},
+			wantErr: "not found",
+		},
+		{
+			name: "unknown provider",
+			cfg: config.LLMConfig{Models: []config.LLMModelConfig{
+				{Name: "x", Provider: "nope", Model: "s"},
+			}},
+			wantErr: "unknown llm provider",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewRegistry(tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.DefaultModel() != tt.wantDef {
+				t.Errorf("default = %q, want %q", r.DefaultModel(), tt.wantDef)
+			}
+		})
+	}
+}
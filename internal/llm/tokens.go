@@ -0,0 +1,52 @@
+package llm
+
+import "unicode"
+
+// 估算时每条消息的固定开销（角色、分隔符等）与每个图片/视频片段的折算 token 数。
+const (
+	messageOverheadTokens = 4
+	mediaPartTokens       = 1000
+)
+
+// EstimateTokens 粗略估算文本 token 数：CJK 字符按 1 字 1 token，其余按约 4 字节 1 token。
+func EstimateTokens(text string) int {
+	cjk := 0
+	other := 0
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+			continue
+		}
+		if r < 0x80 {
+			other++
+		} else {
+			other += 2
+		}
+	}
+	return cjk + (other+3)/4
+}
+
+// EstimateMessageTokens 估算单条消息的 token 数。
+func EstimateMessageTokens(m Message) int {
+	total := messageOverheadTokens
+	if len(m.Parts) == 0 {
+		return total + EstimateTokens(m.Content)
+	}
+	for _, part := range m.Parts {
+		if part.Type == PartText {
+			total += EstimateTokens(part.Text)
+		} else {
+			total += mediaPartTokens
+		}
+	}
+	return total
+}
+
+// EstimateMessagesTokens 估算消息列表的 token 数。
+func EstimateMessagesTokens(messages []Message) int {
+	total := 0
+	for _, m := range messages {
+		total += EstimateMessageTokens(m)
+	}
+	return total
+}
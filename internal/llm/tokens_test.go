@@ -0,0 +1,42 @@
+package llm
+
+import "testing"
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"abcd", 1},
+		{"abcde", 2},
+		{"你好", 2},
+		{"こんにちは", 5},
+		{"안녕", 2},
+		// 非 ASCII 的其他字符按 2 字节计。
+		{"héllo", 2},
+		{"hi 你好", 3},
+	}
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.text); got != tt.want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestEstimateMessageTokens(t *testing.T) {
+	text := Message{Role: RoleUser, Content: "abcd"}
+	if got := EstimateMessageTokens(text); got != messageOverheadTokens+1 {
+		t.Errorf("text message = %d", got)
+	}
+	multimodal := Message{Role: RoleUser, Content: "ignored when parts are set", Parts: []ContentPart{
+		{Type: PartText, Text: "abcd"},
+		{Type: PartImageURL, URL: "http://x/img"},
+	}}
+	if got := EstimateMessageTokens(multimodal); got != messageOverheadTokens+1+mediaPartTokens {
+		t.Errorf("multimodal message = %d", got)
+	}
+	if got := EstimateMessagesTokens([]Message{text, multimodal}); got != EstimateMessageTokens(text)+EstimateMessageTokens(multimodal) {
+		t.Errorf("messages = %d", got)
+	}
+}
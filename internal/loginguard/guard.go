@@ -0,0 +1,191 @@
+// Package loginguard 登录防暴力破解：按用户名与客户端 IP 统计失败次数。用户名超过
+// 免退避次数后按指数退避拒绝登录，用户名或 IP 达到各自上限后临时锁定；IP 不做退避，
+// 以免共享出口的用户互相影响。
+package loginguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"backend/internal/config"
+)
+
+// ErrLocked 登录尝试过于频繁或账号被临时锁定。
+var ErrLocked = errors.New("too many login attempts")
+
+// LockedError 携带需等待的时长，errors.Is(err, ErrLocked) 成立。
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrLocked, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LockedError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+const (
+	defaultWindow        = 15 * time.Minute
+	defaultFreeAttempts  = 3
+	defaultBaseDelay     = time.Second
+	defaultMaxDelay      = time.Minute
+	defaultMaxAttempts   = 10
+	defaultIPMaxAttempts = 50
+	defaultLockout       = 15 * time.Minute
+)
+
+// Guard 登录失败计数与锁定判定，可并发使用；nil 或已禁用的 Guard 不做任何限制。
+type Guard struct {
+	store         Store
+	window        time.Duration
+	freeAttempts  int64
+	baseDelay     time.Duration
+	maxDelay      time.Duration
+	maxAttempts   int64
+	ipMaxAttempts int64
+	lockout       time.Duration
+	// now 当前时间，测试时可替换。
+	now func() time.Time
+}
+
+// New 按配置创建 Guard，未配置的字段使用默认值；禁用时返回 nil。
+func New(cfg config.LoginGuardConfig, st Store) *Guard {
+	if cfg.Disabled {
+		return nil
+	}
+	g := &Guard{
+		store:         st,
+		window:        seconds(cfg.WindowSeconds, defaultWindow),
+		freeAttempts:  int64(cfg.FreeAttempts),
+		baseDelay:     seconds(cfg.BaseDelaySeconds, defaultBaseDelay),
+		maxDelay:      seconds(cfg.MaxDelaySeconds, defaultMaxDelay),
+		maxAttempts:   int64(cfg.MaxAttempts),
+		ipMaxAttempts: int64(cfg.IPMaxAttempts),
+		lockout:       seconds(cfg.LockoutSeconds, defaultLockout),
+		now:           time.Now,
+	}
+	if g.freeAttempts <= 0 {
+		g.freeAttempts = defaultFreeAttempts
+	}
+	if g.maxAttempts <= 0 {
+		g.maxAttempts = defaultMaxAttempts
+	}
+	if g.ipMaxAttempts <= 0 {
+		g.ipMaxAttempts = defaultIPMaxAttempts
+	}
+	return g
+}
+
+func seconds(v int, def time.Duration) time.Duration {
+	if v > 0 {
+		return time.Duration(v) * time.Second
+	}
+	return def
+}
+
+// subject 一个计数维度（用户名或 IP）。
+type subject struct {
+	name        string
+	maxAttempts int64
+	backoff     bool
+}
+
+func (g *Guard) subjects(username, ip string) []subject {
+	out := []subject{{name: "user:" + normalizeUsername(username), maxAttempts: g.maxAttempts, backoff: true}}
+	if ip != "" {
+		out = append(out, subject{name: "ip:" + ip, maxAttempts: g.ipMaxAttempts})
+	}
+	return out
+}
+
+// normalizeUsername 用户名不区分大小写计数，避免通过变换大小写绕过限制。
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+func failKey(name string) string  { return "login:fail:" + name }
+func blockKey(name string) string { return "login:block:" + name }
+
+// Check 在校验密码前调用，用户名或 IP 处于退避或锁定期时返回 *LockedError。
+// 不区分用户名是否存在，避免借此探测账号。
+func (g *Guard) Check(ctx context.Context, username, ip string) error {
+	if g == nil {
+		return nil
+	}
+	now := g.now()
+	var wait time.Duration
+	for _, sub := range g.subjects(username, ip) {
+		until, err := g.store.Get(ctx, blockKey(sub.name))
+		if err != nil {
+			return err
+		}
+		if d := time.Unix(0, until).Sub(now); until > 0 && d > wait {
+			wait = d
+		}
+	}
+	if wait > 0 {
+		return &LockedError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// Fail 记录一次失败登录，并按失败次数设置退避或锁定。
+func (g *Guard) Fail(ctx context.Context, username, ip string) error {
+	if g == nil {
+		return nil
+	}
+	now := g.now()
+	for _, sub := range g.subjects(username, ip) {
+		count, err := g.store.Incr(ctx, failKey(sub.name), g.window)
+		if err != nil {
+			return err
+		}
+		wait := g.delay(count, sub)
+		if wait <= 0 {
+			continue
+		}
+		if err := g.store.Set(ctx, blockKey(sub.name), now.Add(wait).UnixNano(), wait); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// delay 计算第 count 次失败后需等待的时长：达到上限锁定 lockout，
+// 超过免退避次数后从 baseDelay 起翻倍，不超过 maxDelay。
+func (g *Guard) delay(count int64, sub subject) time.Duration {
+	if count >= sub.maxAttempts {
+		return g.lockout
+	}
+	over := count - g.freeAttempts
+	if !sub.backoff || over <= 0 {
+		return 0
+	}
+	wait := g.baseDelay
+	for i := int64(1); i < over && wait < g.maxDelay; i++ {
+		wait *= 2
+	}
+	if wait > g.maxDelay {
+		wait = g.maxDelay
+	}
+	return wait
+}
+
+// Succeed 登录成功后清除该用户名的失败计数；IP 计数保留，避免以自有账号重置。
+func (g *Guard) Succeed(ctx context.Context, username string) error {
+	return g.Unlock(ctx, username)
+}
+
+// Unlock 清除用户名的失败计数与锁定。
+func (g *Guard) Unlock(ctx context.Context, username string) error {
+	if g == nil {
+		return nil
+	}
+	name := "user:" + normalizeUsername(username)
+	return g.store.Del(ctx, failKey(name), blockKey(name))
+}
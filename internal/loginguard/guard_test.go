@@ -0,0 +1,222 @@
+package loginguard
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"backend/internal/config"
+)
+
+// fakeClock 手动推进的时钟。
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+// newTestGuard 创建使用 MemoryStore 与 fakeClock 的 Guard：免退避 3 次，退避 1s 起翻倍至 4s，
+// 用户名 8 次、IP 10 次失败锁定 60s。
+func newTestGuard(t *testing.T) (*Guard, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{t: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	st := NewMemoryStore()
+	st.now = clock.now
+	g := New(config.LoginGuardConfig{
+		WindowSeconds:    900,
+		FreeAttempts:     3,
+		BaseDelaySeconds: 1,
+		MaxDelaySeconds:  4,
+		MaxAttempts:      8,
+		IPMaxAttempts:    10,
+		LockoutSeconds:   60,
+	}, st)
+	g.now = clock.now
+	return g, clock
+}
+
+// retryAfter 返回 Check 要求的等待时长，未被限制时为 0。
+func retryAfter(t *testing.T, g *Guard, username, ip string) time.Duration {
+	t.Helper()
+	err := g.Check(context.Background(), username, ip)
+	if err == nil {
+		return 0
+	}
+	var locked *LockedError
+	if !errors.As(err, &locked) || !errors.Is(err, ErrLocked) {
+		t.Fatalf("Check err = %v", err)
+	}
+	return locked.RetryAfter
+}
+
+func mustFail(t *testing.T, g *Guard, username, ip string) {
+	t.Helper()
+	if err := g.Fail(context.Background(), username, ip); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBackoffAndLockout(t *testing.T) {
+	g, clock := newTestGuard(t)
+	wants := []time.Duration{0, 0, 0, time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second, time.Minute}
+	for i, want := range wants {
+		// 用户名不区分大小写与首尾空白。
+		username := "alice"
+		if i%2 == 1 {
+			username = " Alice "
+		}
+		mustFail(t, g, username, "")
+		if got := retryAfter(t, g, "alice", ""); got != want {
+			t.Fatalf("after failure %d wait = %v, want %v", i+1, got, want)
+		}
+		if want == 0 {
+			continue
+		}
+		clock.advance(want - time.Millisecond)
+		if got := retryAfter(t, g, "ALICE", ""); got != time.Millisecond {
+			t.Fatalf("after failure %d remaining wait = %v", i+1, got)
+		}
+		clock.advance(time.Millisecond)
+		if got := retryAfter(t, g, "alice", ""); got != 0 {
+			t.Fatalf("after failure %d still blocked for %v", i+1, got)
+		}
+	}
+	if got := retryAfter(t, g, "bob", ""); got != 0 {
+		t.Errorf("other user blocked for %v", got)
+	}
+}
+
+func TestWindowExpiry(t *testing.T) {
+	g, clock := newTestGuard(t)
+	for range 3 {
+		mustFail(t, g, "alice", "")
+	}
+	clock.advance(15*time.Minute + time.Second)
+	mustFail(t, g, "alice", "")
+	if got := retryAfter(t, g, "alice", ""); got != 0 {
+		t.Errorf("failure count survived the window: wait %v", got)
+	}
+}
+
+func TestIPLockout(t *testing.T) {
+	g, clock := newTestGuard(t)
+	users := []string{"u0", "u1", "u2", "u3", "u4", "u5", "u6", "u7", "u8", "u9"}
+	for i, u := range users {
+		mustFail(t, g, u, "10.0.0.1")
+		// IP 达到上限前不做退避。
+		if got := retryAfter(t, g, "new-user", "10.0.0.1"); (got != 0) != (i == len(users)-1) {
+			t.Fatalf("after %d failures from the IP wait = %v", i+1, got)
+		}
+	}
+	if got := retryAfter(t, g, "new-user", "10.0.0.1"); got != time.Minute {
+		t.Errorf("IP lockout = %v", got)
+	}
+	if got := retryAfter(t, g, "new-user", "10.0.0.2"); got != 0 {
+		t.Errorf("other IP blocked for %v", got)
+	}
+	clock.advance(time.Minute)
+	if got := retryAfter(t, g, "new-user", "10.0.0.1"); got != 0 {
+		t.Errorf("IP still blocked after lockout: %v", got)
+	}
+}
+
+func TestUnlockAndSucceed(t *testing.T) {
+	g, clock := newTestGuard(t)
+	ctx := context.Background()
+	for range 8 {
+		mustFail(t, g, "alice", "10.0.0.1")
+		clock.advance(4 * time.Second)
+	}
+	if got := retryAfter(t, g, "alice", ""); got <= 0 {
+		t.Fatal("account not locked")
+	}
+
+	// 管理员解锁清除用户名计数，下一次失败不会立即退避。
+	if err := g.Unlock(ctx, "Alice"); err != nil {
+		t.Fatal(err)
+	}
+	if got := retryAfter(t, g, "alice", ""); got != 0 {
+		t.Fatalf("still locked after unlock: %v", got)
+	}
+	mustFail(t, g, "alice", "10.0.0.1")
+	if got := retryAfter(t, g, "alice", ""); got != 0 {
+		t.Errorf("unlock did not reset the count: wait %v", got)
+	}
+
+	// 登录成功只清除用户名计数，IP 计数保留：此时该 IP 共失败 9 次，再失败一次即锁定。
+	if err := g.Succeed(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	mustFail(t, g, "mallory", "10.0.0.1")
+	if got := retryAfter(t, g, "anyone", "10.0.0.1"); got != time.Minute {
+		t.Errorf("IP count reset by a successful login: wait %v", got)
+	}
+}
+
+func TestDisabledGuard(t *testing.T) {
+	g := New(config.LoginGuardConfig{Disabled: true}, NewMemoryStore())
+	if g != nil {
+		t.Fatal("disabled guard should be nil")
+	}
+	ctx := context.Background()
+	for range 20 {
+		if err := g.Fail(ctx, "alice", "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.Check(ctx, "alice", "10.0.0.1"); err != nil {
+		t.Errorf("Check err = %v", err)
+	}
+	if err := g.Unlock(ctx, "alice"); err != nil {
+		t.Errorf("Unlock err = %v", err)
+	}
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	st := NewMemoryStore()
+	st.now = clock.now
+	ctx := context.Background()
+
+	if n, _ := st.Incr(ctx, "k", time.Minute); n != 1 {
+		t.Fatalf("first Incr = %d", n)
+	}
+	clock.advance(59 * time.Second)
+	// Incr 重置过期时间。
+	if n, _ := st.Incr(ctx, "k", time.Minute); n != 2 {
+		t.Fatalf("second Incr = %d", n)
+	}
+	clock.advance(59 * time.Second)
+	if v, _ := st.Get(ctx, "k"); v != 2 {
+		t.Fatalf("Get = %d, want 2", v)
+	}
+	clock.advance(time.Second)
+	if v, _ := st.Get(ctx, "k"); v != 0 {
+		t.Errorf("expired Get = %d", v)
+	}
+	if n, _ := st.Incr(ctx, "k", time.Minute); n != 1 {
+		t.Errorf("Incr after expiry = %d", n)
+	}
+
+	if err := st.Set(ctx, "s", 42, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Del(ctx, "s", "missing"); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := st.Get(ctx, "s"); v != 0 {
+		t.Errorf("deleted Get = %d", v)
+	}
+
+	// 过期条目在下次写入时被清理。
+	clock.advance(2 * time.Minute)
+	if err := st.Set(ctx, "fresh", 1, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if len(st.entries) != 1 {
+		t.Errorf("entries after sweep = %v", st.entries)
+	}
+}
@@ -0,0 +1,100 @@
+package loginguard
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store 带过期时间的计数存储。方法语义对应 Redis 的 INCR+PEXPIRE、GET、SET PX、DEL，
+// 多实例部署时可换成共享的 Redis 实现。
+type Store interface {
+	// Incr 将 key 加一并把过期时间重置为 ttl，返回加一后的值。
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	// Get 返回 key 的值，不存在或已过期时返回 0。
+	Get(ctx context.Context, key string) (int64, error)
+	// Set 设置 key 的值与过期时间。
+	Set(ctx context.Context, key string, value int64, ttl time.Duration) error
+	// Del 删除 keys。
+	Del(ctx context.Context, keys ...string) error
+}
+
+type memoryEntry struct {
+	value     int64
+	expiresAt time.Time
+}
+
+// MemoryStore Store 的进程内实现，仅在单实例内生效。
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]memoryEntry
+	lastSweep time.Time
+	// now 当前时间，测试时可替换。
+	now func() time.Time
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// memorySweepInterval 清理过期条目的最小间隔。
+const memorySweepInterval = time.Minute
+
+// NewMemoryStore 创建空的 MemoryStore。
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry), now: time.Now}
+}
+
+func (m *MemoryStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	m.sweep(now)
+	e, ok := m.entries[key]
+	if !ok || !now.Before(e.expiresAt) {
+		e = memoryEntry{}
+	}
+	e.value++
+	e.expiresAt = now.Add(ttl)
+	m.entries[key] = e
+	return e.value, nil
+}
+
+func (m *MemoryStore) Get(ctx context.Context, key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[key]
+	if !ok || !m.now().Before(e.expiresAt) {
+		return 0, nil
+	}
+	return e.value, nil
+}
+
+func (m *MemoryStore) Set(ctx context.Context, key string, value int64, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	m.sweep(now)
+	m.entries[key] = memoryEntry{value: value, expiresAt: now.Add(ttl)}
+	return nil
+}
+
+func (m *MemoryStore) Del(ctx context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range keys {
+		delete(m.entries, key)
+	}
+	return nil
+}
+
+// sweep 定期删除过期条目，避免大量随机用户名撑大内存；调用方需持有 m.mu。
+func (m *MemoryStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < memorySweepInterval {
+		return
+	}
+	m.lastSweep = now
+	for key, e := range m.entries {
+		if !now.Before(e.expiresAt) {
+			delete(m.entries, key)
+		}
+	}
+}
@@ -1,25 +1,24 @@
 package middlewares
 
 import (
+	"database/sql"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-)
 
-// JWTSecret 建议实际从 config 中读取，这里为了演示和 Mock 兼容先定义一个常量
-// 之后可以改为：cfg.Server.JwtSecret
-var JWTSecret = []byte("your-256-bit-secret")
+	"backend/internal/jwtauth"
+	"backend/internal/store"
+)
 
-// MyClaims 定义 JWT 中存储的数据
-type MyClaims struct {
-	Username string `json:"username"`
-	jwt.RegisteredClaims
-}
+// PasswordChangePath 用户须修改密码时唯一放行的鉴权路由。
+const PasswordChangePath = "/auth/reset-password"
 
-// AuthMiddleware 完善后的 JWT 鉴权中间件
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware JWT 鉴权中间件，令牌由 tokens 按 kid 选择密钥校验。
+// 校验通过后将声明中的 username、user_id 与 users 中的当前角色写入上下文，角色变更即时生效；
+// 令牌版本与 users 中当前版本不一致（改密、删除用户等）时拒绝，该检查只按主键读取用户状态。
+// 用户被要求修改密码时，除 PasswordChangePath 外的路由一律返回 403。
+func AuthMiddleware(tokens *jwtauth.Manager, users store.UserRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		tokenString := ""
@@ -43,19 +42,35 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		token, err := jwt.ParseWithClaims(tokenString, &MyClaims{}, func(token *jwt.Token) (interface{}, error) {
-			return JWTSecret, nil
-		})
-
-		if err != nil || !token.Valid {
+		claims, err := tokens.Parse(tokenString)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"err_msg": "invalid or expired token", "err_code": 401})
 			c.Abort()
 			return
 		}
-
-		if claims, ok := token.Claims.(*MyClaims); ok {
-			c.Set("username", claims.Username)
+		state, err := users.GetUserAuthState(c.Request.Context(), claims.UserID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				c.JSON(http.StatusUnauthorized, gin.H{"err_msg": "invalid or expired token", "err_code": 401})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"err_msg": "db error", "err_code": 500})
+			}
+			c.Abort()
+			return
+		}
+		if state.TokenVersion != claims.TokenVersion {
+			c.JSON(http.StatusUnauthorized, gin.H{"err_msg": "invalid or expired token", "err_code": 401})
+			c.Abort()
+			return
+		}
+		if state.MustChangePassword && c.FullPath() != PasswordChangePath {
+			c.JSON(http.StatusForbidden, gin.H{"err_msg": "password change required", "err_code": 403})
+			c.Abort()
+			return
 		}
+		c.Set("username", claims.Username)
+		c.Set("user_id", claims.UserID)
+		c.Set("role", strings.ToUpper(state.Role))
 
 		c.Next()
 	}
@@ -0,0 +1,87 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"backend/internal/jwtauth"
+)
+
+func TestAuthMiddlewareTokenVersion(t *testing.T) {
+	r, tokens, st := newTestRouter(t)
+	ctx := context.Background()
+	user, err := st.CreateUserWithQuota(ctx, "alice", "hash", "alice", "user", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := issueFor(t, tokens, st, user)
+	if w := get(r, "/me", stale); w.Code != http.StatusOK {
+		t.Fatalf("valid token status %d", w.Code)
+	}
+
+	// 改密等操作递增令牌版本后，此前签发的令牌立即失效。
+	if err := st.BumpUserTokenVersion(ctx, user.UserID); err != nil {
+		t.Fatal(err)
+	}
+	if w := get(r, "/me", stale); w.Code != http.StatusUnauthorized {
+		t.Errorf("stale token status %d", w.Code)
+	}
+	fresh := issueFor(t, tokens, st, user)
+	if w := get(r, "/me", fresh); w.Code != http.StatusOK {
+		t.Errorf("reissued token status %d", w.Code)
+	}
+
+	if _, err := st.DeleteUser(ctx, user.UserID); err != nil {
+		t.Fatal(err)
+	}
+	if w := get(r, "/me", fresh); w.Code != http.StatusUnauthorized {
+		t.Errorf("deleted user's token status %d", w.Code)
+	}
+	if w := get(r, "/me", "not-a-jwt"); w.Code != http.StatusUnauthorized {
+		t.Errorf("malformed token status %d", w.Code)
+	}
+}
+
+func TestAuthMiddlewareUsesCurrentRole(t *testing.T) {
+	r, tokens, st := newTestRouter(t)
+	user, err := st.CreateUserWithQuota(context.Background(), "alice", "hash", "alice", "user", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 令牌签发于降级之前，声明中仍为 ADMIN。
+	token, _, err := tokens.Issue(jwtauth.Subject{UserID: user.UserID, Username: user.Username, Role: RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := get(r, "/admin/users", token); w.Code != http.StatusForbidden {
+		t.Errorf("demoted admin status %d", w.Code)
+	}
+	w := get(r, "/me", token)
+	var body struct {
+		Role string `json:"role"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Role != RoleUser {
+		t.Errorf("context role = %q, err %v", body.Role, err)
+	}
+}
+
+func TestAuthMiddlewareMustChangePassword(t *testing.T) {
+	r, tokens, st := newTestRouter(t)
+	ctx := context.Background()
+	user, err := st.CreateUserWithQuota(ctx, "alice", "hash", "alice", "user", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SetUserMustChangePassword(ctx, user.UserID, true); err != nil {
+		t.Fatal(err)
+	}
+	token := issueFor(t, tokens, st, user)
+	if w := get(r, "/me", token); w.Code != http.StatusForbidden {
+		t.Errorf("status %d before password change", w.Code)
+	}
+	if w := get(r, PasswordChangePath, token); w.Code != http.StatusOK {
+		t.Errorf("password change route status %d", w.Code)
+	}
+}
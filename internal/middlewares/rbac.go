@@ -0,0 +1,66 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 用户角色（与数据库 users.role 保持一致）。
+const (
+	RoleAdmin = "ADMIN"
+	RoleUser  = "USER"
+)
+
+// Permission 管理端接口权限点。
+type Permission string
+
+const (
+	PermUserRead    Permission = "user:read"
+	PermUserWrite   Permission = "user:write"
+	PermQuotaWrite  Permission = "quota:write"
+	PermPromptRead  Permission = "prompt:read"
+	PermPromptWrite Permission = "prompt:write"
+)
+
+// rolePermissions 角色到权限点的映射；新增角色（如只读审计员）只需在此登记。
+var rolePermissions = map[string][]Permission{
+	RoleAdmin: {
+		PermUserRead,
+		PermUserWrite,
+		PermQuotaWrite,
+		PermPromptRead,
+		PermPromptWrite,
+	},
+}
+
+// HasPermission 判断角色是否拥有指定权限点。
+func HasPermission(role string, perm Permission) bool {
+	for _, p := range rolePermissions[strings.ToUpper(role)] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// RequirePermission 按 AuthMiddleware 写入的当前角色校验权限，需挂在 AuthMiddleware 之后。
+func RequirePermission(perms ...Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		if role == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"err_msg": "unauthorized", "err_code": 401})
+			c.Abort()
+			return
+		}
+		for _, perm := range perms {
+			if !HasPermission(role, perm) {
+				c.JSON(http.StatusForbidden, gin.H{"err_msg": "forbidden", "err_code": 403})
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
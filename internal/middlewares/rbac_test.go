@@ -0,0 +1,103 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"backend/internal/config"
+	"backend/internal/jwtauth"
+	"backend/internal/store"
+	"backend/internal/store/memstore"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestRouter 创建挂载 AuthMiddleware 的路由：/me 与 PasswordChangePath 只需登录，/admin/users 需要 PermUserRead。
+func newTestRouter(t *testing.T) (*gin.Engine, *jwtauth.Manager, *memstore.Store) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	tokens, err := jwtauth.New(config.AuthConfig{Secret: "test-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := memstore.New()
+	ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"err_code": 0, "role": c.GetString("role")}) }
+
+	r := gin.New()
+	r.GET(PasswordChangePath, AuthMiddleware(tokens, st), ok)
+	r.GET("/me", AuthMiddleware(tokens, st), ok)
+	r.GET("/admin/users", AuthMiddleware(tokens, st), RequirePermission(PermUserRead), ok)
+	return r, tokens, st
+}
+
+// issueFor 按用户当前的角色与令牌版本签发访问令牌。
+func issueFor(t *testing.T, tokens *jwtauth.Manager, st store.Store, user store.User) string {
+	t.Helper()
+	state, err := st.GetUserAuthState(context.Background(), user.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := tokens.Issue(jwtauth.Subject{
+		UserID:       user.UserID,
+		Username:     user.Username,
+		Role:         user.Role,
+		TokenVersion: state.TokenVersion,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func get(r *gin.Engine, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRequirePermission(t *testing.T) {
+	r, tokens, st := newTestRouter(t)
+	ctx := context.Background()
+	admin, err := st.CreateUserWithQuota(ctx, "root", "hash", "root", "admin", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := st.CreateUserWithQuota(ctx, "alice", "hash", "alice", "user", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminToken := issueFor(t, tokens, st, admin)
+	userToken := issueFor(t, tokens, st, user)
+
+	tests := []struct {
+		name, path, token string
+		want              int
+	}{
+		{"admin on admin route", "/admin/users", adminToken, http.StatusOK},
+		{"user on admin route", "/admin/users", userToken, http.StatusForbidden},
+		{"user on user route", "/me", userToken, http.StatusOK},
+		{"no token", "/admin/users", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := get(r, tt.path, tt.token); w.Code != tt.want {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestHasPermission(t *testing.T) {
+	if !HasPermission("admin", PermQuotaWrite) || !HasPermission(RoleAdmin, PermPromptWrite) {
+		t.Error("admin should hold every permission")
+	}
+	if HasPermission(RoleUser, PermUserRead) || HasPermission("", PermUserRead) || HasPermission("auditor", PermUserRead) {
+		t.Error("only admin holds admin permissions")
+	}
+}
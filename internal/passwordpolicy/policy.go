@@ -0,0 +1,142 @@
+// Package passwordpolicy 校验新密码的长度、字符类别与常见弱密码。
+package passwordpolicy
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"backend/internal/config"
+)
+
+//go:embed common_passwords.txt
+var commonPasswords []byte
+
+var (
+	// ErrTooShort 密码长度不足。
+	ErrTooShort = errors.New("password too short")
+	// ErrTooLong 密码超过最大长度。
+	ErrTooLong = errors.New("password too long")
+	// ErrTooFewClasses 密码包含的字符类别不足。
+	ErrTooFewClasses = errors.New("password needs more character classes")
+	// ErrCommon 密码过于常见或包含用户名。
+	ErrCommon = errors.New("password too common")
+)
+
+const (
+	defaultMinLength  = 8
+	defaultMaxLength  = 72 // bcrypt 只使用前 72 字节
+	defaultMinClasses = 2
+	// minUsernameMatch 用户名达到该字符数时拒绝包含用户名的密码，更短的用户名只拒绝完全相同。
+	minUsernameMatch = 3
+)
+
+// Policy 密码策略，可并发使用。
+type Policy struct {
+	minLength  int
+	maxLength  int
+	minClasses int
+	denylist   map[string]struct{}
+}
+
+// New 按配置创建 Policy，内置常见弱密码列表，denylist_file 中的条目追加其后。
+func New(cfg config.PasswordPolicyConfig) (*Policy, error) {
+	p := &Policy{
+		minLength:  cfg.MinLength,
+		maxLength:  cfg.MaxLength,
+		minClasses: cfg.MinClasses,
+		denylist:   make(map[string]struct{}),
+	}
+	if p.minLength <= 0 {
+		p.minLength = defaultMinLength
+	}
+	if p.maxLength <= 0 || p.maxLength > defaultMaxLength {
+		p.maxLength = defaultMaxLength
+	}
+	if p.minClasses <= 0 {
+		p.minClasses = defaultMinClasses
+	}
+	if p.minLength > p.maxLength {
+		return nil, fmt.Errorf("password min_length %d exceeds max_length %d", p.minLength, p.maxLength)
+	}
+	p.addDenylist(commonPasswords)
+	if cfg.DenylistFile != "" {
+		data, err := os.ReadFile(cfg.DenylistFile)
+		if err != nil {
+			return nil, fmt.Errorf("read password denylist: %w", err)
+		}
+		p.addDenylist(data)
+	}
+	return p, nil
+}
+
+func (p *Policy) addDenylist(data []byte) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			p.denylist[strings.ToLower(line)] = struct{}{}
+		}
+	}
+}
+
+// Validate 校验 password 是否满足策略，username 用于拒绝包含用户名（不区分大小写）的密码。
+// 长度按字节计算上限（bcrypt 限制），按字符计算下限。
+func (p *Policy) Validate(password, username string) error {
+	if utf8.RuneCountInString(password) < p.minLength {
+		return fmt.Errorf("%w: at least %d characters", ErrTooShort, p.minLength)
+	}
+	if len(password) > p.maxLength {
+		return fmt.Errorf("%w: at most %d bytes", ErrTooLong, p.maxLength)
+	}
+	if n := classes(password); n < p.minClasses {
+		return fmt.Errorf("%w: need %d of upper, lower, digit, symbol", ErrTooFewClasses, p.minClasses)
+	}
+	lower := strings.ToLower(password)
+	if _, ok := p.denylist[lower]; ok {
+		return ErrCommon
+	}
+	if containsUsername(lower, strings.ToLower(strings.TrimSpace(username))) {
+		return ErrCommon
+	}
+	return nil
+}
+
+func containsUsername(password, username string) bool {
+	if username == "" {
+		return false
+	}
+	if utf8.RuneCountInString(username) < minUsernameMatch {
+		return password == username
+	}
+	return strings.Contains(password, username)
+}
+
+// classes 统计大写、小写、数字、符号四类中出现的类别数。
+func classes(password string) int {
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	n := 0
+	for _, ok := range []bool{upper, lower, digit, symbol} {
+		if ok {
+			n++
+		}
+	}
+	return n
+}
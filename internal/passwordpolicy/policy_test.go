@@ -0,0 +1,92 @@
+package passwordpolicy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"backend/internal/config"
+)
+
+func TestValidate(t *testing.T) {
+	p, err := New(config.PasswordPolicyConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		password string
+		username string
+		wantErr  error
+	}{
+		{"ok", "correct-horse", "alice", nil},
+		{"two classes", "abcdefg1", "", nil},
+		{"too short", "Ab1!xyz", "", ErrTooShort},
+		{"short counts characters not bytes", "密码密码密码密1", "", nil},
+		{"too long", strings.Repeat("a1", 37), "", ErrTooLong},
+		{"max bytes", strings.Repeat("a1", 36), "", nil},
+		{"single class lower", "abcdefgh", "", ErrTooFewClasses},
+		{"single class digits", "83749261", "", ErrTooFewClasses},
+		{"upper and symbol", "ABCDEFG!", "", nil},
+		{"denylisted", "password1", "", ErrCommon},
+		{"denylisted ignores case", "PassWord1", "", ErrCommon},
+		{"equals username", "Alice.2024", "alice.2024", ErrCommon},
+		{"contains username", "xx-alice-99", "alice", ErrCommon},
+		{"contains username ignoring case", "ALICE#rocks", "Alice", ErrCommon},
+		{"short username only rejected when equal", "al-password-9", "al", nil},
+		{"short username equal", "Al", "al", ErrTooShort},
+		{"no username", "xx-alice-99", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Validate(tt.password, tt.username)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Validate(%q) = %v", tt.password, err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Validate(%q) = %v, want %v", tt.password, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewPolicyConfig(t *testing.T) {
+	dir := t.TempDir()
+	denylist := filepath.Join(dir, "denylist.txt")
+	if err := os.WriteFile(denylist, []byte("\n  Company2025!  \n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := New(config.PasswordPolicyConfig{MinLength: 10, MinClasses: 3, DenylistFile: denylist})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		password string
+		wantErr  error
+	}{
+		{"Abcdefg1!", ErrTooShort},
+		{"abcdefghij1", ErrTooFewClasses},
+		{"Abcdefghij1", nil},
+		{"company2025!", ErrCommon},
+		// 内置列表仍然生效。
+		{"Password123", ErrCommon},
+	}
+	for _, tt := range tests {
+		if err := p.Validate(tt.password, ""); !errors.Is(err, tt.wantErr) {
+			t.Errorf("Validate(%q) = %v, want %v", tt.password, err, tt.wantErr)
+		}
+	}
+
+	if _, err := New(config.PasswordPolicyConfig{MinLength: 80}); err == nil {
+		t.Error("min_length above the bcrypt limit should be rejected")
+	}
+	if _, err := New(config.PasswordPolicyConfig{DenylistFile: filepath.Join(dir, "missing.txt")}); err == nil {
+		t.Error("missing denylist file should be rejected")
+	}
+}
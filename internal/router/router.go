@@ -1,24 +1,33 @@
 package router
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 
-	"backend/internal/config"
+	"backend/internal/app"
 	"backend/internal/controller"
 	"backend/internal/middlewares"
 )
 
-func NewRouter(cfg *config.Config) *gin.Engine {
+// NewRouter 创建绑定到 a 的路由，只信任 server.trusted_proxies 中代理转发的客户端 IP。
+func NewRouter(a *app.App) (*gin.Engine, error) {
 	r := gin.New()
+	if err := r.SetTrustedProxies(a.Config.Server.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("server.trusted_proxies: %w", err)
+	}
 	r.Use(gin.Logger(), gin.Recovery(), middlewares.CORSMiddleware())
-	_ = cfg
-	SetupRouter(r)
-	return r
+	SetupRouter(r, a)
+	return r, nil
 }
 
-func SetupRouter(r *gin.Engine) {
+// SetupRouter 在 r 上注册全部路由，处理器依赖均来自 a。
+func SetupRouter(r *gin.Engine, a *app.App) {
+	ctl := controller.New(a)
+	requireAuth := middlewares.AuthMiddleware(a.Tokens, a.Store)
+	perm := middlewares.RequirePermission
+
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
@@ -26,42 +35,55 @@ func SetupRouter(r *gin.Engine) {
 
 	auth := r.Group("/auth")
 	{
-		auth.POST("/login", controller.HandleLogin)
-		auth.POST("/reset-password", controller.HandleSetPassword)
-		auth.POST("/refresh-token", middlewares.AuthMiddleware(), controller.HandleRefreshToken)
+		auth.POST("/login", ctl.HandleLogin)
+		auth.POST("/reset-password", requireAuth, ctl.HandleSetPassword)
+		auth.POST("/reset-password-with-token", ctl.HandleResetPasswordWithToken)
+		auth.POST("/refresh-token", ctl.HandleRefreshToken)
+		auth.POST("/logout", ctl.HandleLogout)
 	}
 
 	chat := r.Group("/chat")
-	chat.Use(middlewares.AuthMiddleware())
+	chat.Use(requireAuth)
 	{
-		chat.POST("/send-message/:conversation_id", controller.HandleChatSend)
-		chat.GET("/history/:conversation_id", controller.HandleGetChatHistory)
-		chat.POST("/new-conversation", controller.HandleNewChat)
-		chat.PUT("/rename-conversation/:conversation_id", controller.HandleRenameChat)
-		chat.DELETE("/delete-conversation/:conversation_id", controller.HandleDeleteChat)
-		chat.POST("/upload-file", controller.HandleUploadFile)
-		chat.GET("/prompt-preset", controller.HandleGetPromptPreset)
+		chat.POST("/send-message/:conversation_id", ctl.HandleChatSend)
+		chat.POST("/regenerate/:conversation_id", ctl.HandleRegenerate)
+		chat.POST("/edit-message/:conversation_id", ctl.HandleEditMessage)
+		chat.POST("/switch-branch/:conversation_id", ctl.HandleSwitchBranch)
+		chat.POST("/stop/:conversation_id", ctl.HandleStopChat)
+		chat.GET("/history/:conversation_id", ctl.HandleGetChatHistory)
+		chat.POST("/new-conversation", ctl.HandleNewChat)
+		chat.PUT("/rename-conversation/:conversation_id", ctl.HandleRenameChat)
+		chat.DELETE("/delete-conversation/:conversation_id", ctl.HandleDeleteChat)
+		chat.POST("/archive-conversation/:conversation_id", ctl.HandleArchiveChat)
+		chat.POST("/restore-conversation/:conversation_id", ctl.HandleRestoreChat)
+		chat.POST("/fork/:conversation_id", ctl.HandleForkChat)
+		chat.POST("/upload-file", ctl.HandleUploadFile)
+		chat.GET("/prompt-preset", ctl.HandleGetPromptPreset)
+		chat.GET("/models", ctl.HandleGetModels)
 	}
 
-	r.POST("/stt/request-stt", middlewares.AuthMiddleware(), controller.HandleSTTUpload)
-	r.GET("/tts/request/:message_id", middlewares.AuthMiddleware(), controller.HandleTTSConvert)
+	r.POST("/stt/request-stt", requireAuth, ctl.HandleSTTUpload)
+	r.GET("/tts/request/:message_id", requireAuth, ctl.HandleTTSConvert)
 
 	admin := r.Group("/admin")
-	admin.Use(middlewares.AuthMiddleware())
+	admin.Use(requireAuth)
 	{
-		admin.POST("/new-user", controller.HandleAddUser)
-		admin.GET("/users", controller.HandleGetUserList)
-		admin.DELETE("/delete-user/:user_id", controller.HandleDeleteUser)
-		admin.POST("/set-quota/:user_id", controller.HandleSetQuota)
-		admin.GET("/prompt-preset", controller.HandleAdminGetPromptPresets)
-		admin.POST("/prompt-preset", controller.HandleAdminCreatePromptPreset)
-		admin.DELETE("/prompt-preset/:prompt_preset_id", controller.HandleAdminDeletePromptPreset)
+		admin.POST("/new-user", perm(middlewares.PermUserWrite), ctl.HandleAddUser)
+		admin.GET("/users", perm(middlewares.PermUserRead), ctl.HandleGetUserList)
+		admin.DELETE("/delete-user/:user_id", perm(middlewares.PermUserWrite), ctl.HandleDeleteUser)
+		admin.POST("/revoke-sessions/:user_id", perm(middlewares.PermUserWrite), ctl.HandleRevokeUserSessions)
+		admin.POST("/unlock-user/:user_id", perm(middlewares.PermUserWrite), ctl.HandleUnlockUser)
+		admin.POST("/reset-password/:user_id", perm(middlewares.PermUserWrite), ctl.HandleAdminResetPassword)
+		admin.POST("/set-quota/:user_id", perm(middlewares.PermQuotaWrite), ctl.HandleSetQuota)
+		admin.GET("/prompt-preset", perm(middlewares.PermPromptRead), ctl.HandleAdminGetPromptPresets)
+		admin.POST("/prompt-preset", perm(middlewares.PermPromptWrite), ctl.HandleAdminCreatePromptPreset)
+		admin.DELETE("/prompt-preset/:prompt_preset_id", perm(middlewares.PermPromptWrite), ctl.HandleAdminDeletePromptPreset)
 	}
 
 	me := r.Group("/me")
-	me.Use(middlewares.AuthMiddleware())
+	me.Use(requireAuth)
 	{
-		me.GET("/info", controller.HandleGetMeInfo)
-		me.GET("/conversations", controller.HandleGetMeConversations)
+		me.GET("/info", ctl.HandleGetMeInfo)
+		me.GET("/conversations", ctl.HandleGetMeConversations)
 	}
 }
@@ -0,0 +1,88 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"backend/internal/app"
+	"backend/internal/config"
+	"backend/internal/store/memstore"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestRouter 创建基于 memstore 的路由，同一 IP 失败 3 次即锁定，用户名计数不会先触发。
+func newTestRouter(t *testing.T, trustedProxies []string) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{
+		Server: config.ServerConfig{TrustedProxies: trustedProxies},
+		Auth: config.AuthConfig{
+			Secret:     "test-secret",
+			LoginGuard: config.LoginGuardConfig{FreeAttempts: 10, MaxAttempts: 10, IPMaxAttempts: 3},
+		},
+		LLM: config.LLMConfig{
+			Models: []config.LLMModelConfig{{Name: "fake", Provider: "fake", Model: "fake"}},
+		},
+	}
+	a, err := app.NewWithStore(cfg, memstore.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRouter(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// failLogins 每次换用不同的用户名与 X-Forwarded-For 登录失败 n 次，返回第 n+1 次登录的状态码。
+func failLogins(r *gin.Engine, n int) int {
+	login := func(i int) int {
+		body := `{"username":"user` + strconv.Itoa(i) + `","password":"wrong"}`
+		req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", "198.51.100."+strconv.Itoa(i))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	for i := 0; i < n; i++ {
+		login(i)
+	}
+	return login(n)
+}
+
+func TestLoginLockoutIgnoresSpoofedForwardedFor(t *testing.T) {
+	// httptest 请求的对端地址为 192.0.2.1，伪造的 X-Forwarded-For 不能绕过按 IP 的锁定。
+	r := newTestRouter(t, nil)
+	if code := failLogins(r, 3); code != http.StatusTooManyRequests {
+		t.Errorf("status after 3 failures from one peer = %d, want 429", code)
+	}
+}
+
+func TestLoginTrustsConfiguredProxy(t *testing.T) {
+	// 对端为可信代理时按 X-Forwarded-For 区分客户端，各客户端分别计数。
+	r := newTestRouter(t, []string{"192.0.2.0/24"})
+	if code := failLogins(r, 3); code != http.StatusUnauthorized {
+		t.Errorf("status for distinct clients behind a trusted proxy = %d, want 401", code)
+	}
+}
+
+func TestNewRouterRejectsBadProxy(t *testing.T) {
+	cfg := &config.Config{
+		Server: config.ServerConfig{TrustedProxies: []string{"not-an-ip"}},
+		Auth:   config.AuthConfig{Secret: "test-secret"},
+		LLM:    config.LLMConfig{Models: []config.LLMModelConfig{{Name: "fake", Provider: "fake", Model: "fake"}}},
+	}
+	a, err := app.NewWithStore(cfg, memstore.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewRouter(a); err == nil {
+		t.Error("invalid trusted proxy accepted")
+	}
+}
@@ -7,25 +7,49 @@ import (
 )
 
 // ListUsers 分页获取用户列表。
-func ListUsers(ctx context.Context, page, pageSize int) ([]store.User, int, error) {
-	return store.ListUsers(ctx, page, pageSize)
+func (s *Service) ListUsers(ctx context.Context, page, pageSize int) ([]store.User, int, error) {
+	return s.store.ListUsers(ctx, page, pageSize)
 }
 
-// CreateUser 创建用户并返回对象。
-func CreateUser(ctx context.Context, username, password, nickname, role string, total, used int) (store.User, error) {
-	hashed, err := hashPassword(password)
+// CreateUser 创建用户并返回对象。管理员设置的初始密码须符合密码策略，
+// 且用户首次登录后须修改密码。
+func (s *Service) CreateUser(ctx context.Context, username, password, nickname, role string, total, used int) (store.User, error) {
+	hashed, err := s.hashNewPassword(password, username)
 	if err != nil {
 		return store.User{}, err
 	}
-	return store.CreateUserWithQuota(ctx, username, hashed, nickname, role, total, used)
+	var user store.User
+	err = s.store.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = s.store.CreateUserWithQuota(ctx, username, hashed, nickname, role, total, used)
+		if err != nil {
+			return err
+		}
+		if err := s.store.SetUserMustChangePassword(ctx, user.UserID, true); err != nil {
+			return err
+		}
+		user.MustChangePassword = true
+		return nil
+	})
+	return user, err
 }
 
 // SetUserQuota 设置用户额度。
-func SetUserQuota(ctx context.Context, userID int, quota int) (bool, error) {
-	return store.SetUserQuota(ctx, userID, quota)
+func (s *Service) SetUserQuota(ctx context.Context, userID int, quota int) (bool, error) {
+	return s.store.SetUserQuota(ctx, userID, quota)
 }
 
-// DeleteUser 删除用户。
-func DeleteUser(ctx context.Context, userID int) (bool, error) {
-	return store.DeleteUser(ctx, userID)
+// DeleteUser 删除用户并吊销其全部会话；已签发的访问令牌因用户不存在而在鉴权时失效。
+func (s *Service) DeleteUser(ctx context.Context, userID int) (bool, error) {
+	var deleted bool
+	err := s.store.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		deleted, err = s.store.DeleteUser(ctx, userID)
+		if err != nil || !deleted {
+			return err
+		}
+		_, err = s.store.RevokeUserRefreshTokens(ctx, userID)
+		return err
+	})
+	return deleted, err
 }
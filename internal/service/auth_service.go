@@ -4,66 +4,91 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"log"
 	"strings"
-	"time"
+	"sync"
 
 	"backend/internal/config"
 	"backend/internal/middlewares"
-	"backend/internal/store"
 
-	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
 var (
 	// ErrInvalidCredentials 账号或密码错误。
 	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrWeakPassword 新密码不符合密码策略，错误链中包含 passwordpolicy 给出的具体原因。
+	ErrWeakPassword = errors.New("weak password")
 	// ErrUserNotFound 用户不存在。
 	ErrUserNotFound = errors.New("user not found")
 )
 
-// Login 校验用户并生成 JWT。
-func Login(ctx context.Context, username, password string) (string, error) {
-	_, current, err := store.GetUserPassword(ctx, username)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return "", ErrUserNotFound
-		} else {
-			return "", err
-		}
+// Login 校验用户并签发访问令牌与新会话的刷新令牌。用户不存在与密码错误均返回
+// ErrInvalidCredentials，且同样执行一次 bcrypt 比对，避免借响应或耗时探测账号；
+// 失败次数过多时返回 *loginguard.LockedError。
+func (s *Service) Login(ctx context.Context, username, password, clientIP string) (TokenPair, error) {
+	if err := s.guard.Check(ctx, username, clientIP); err != nil {
+		return TokenPair{}, err
+	}
+	_, current, err := s.store.GetUserPassword(ctx, username)
+	if err != nil && err != sql.ErrNoRows {
+		return TokenPair{}, err
+	}
+	if err == sql.ErrNoRows {
+		current = dummyPasswordHash()
 	}
 
-	ok, legacy, err := verifyPassword(current, password)
-	if err != nil {
-		return "", err
+	ok, legacy, verr := verifyPassword(current, password)
+	if verr != nil {
+		return TokenPair{}, verr
 	}
-	if !ok {
-		return "", ErrInvalidCredentials
+	if err == sql.ErrNoRows || !ok {
+		if ferr := s.guard.Fail(ctx, username, clientIP); ferr != nil {
+			return TokenPair{}, ferr
+		}
+		return TokenPair{}, ErrInvalidCredentials
+	}
+	if err := s.guard.Succeed(ctx, username); err != nil {
+		return TokenPair{}, err
 	}
 	if legacy {
 		if hashed, err := hashPassword(password); err == nil {
-			_ = store.UpdateUserPassword(ctx, username, hashed)
+			_ = s.store.UpdateUserPassword(ctx, username, hashed)
 		}
 	}
 
-	claims := middlewares.MyClaims{
-		Username: username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+	user, err := s.store.GetUserByUsername(ctx, username)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return s.issueTokenPair(ctx, user, "")
+}
+
+// dummyPasswordHash 用户不存在时参与比对的哈希，使耗时与密码错误一致。
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hashed, _ := hashPassword("login-timing-equalizer")
+	return hashed
+})
+
+// UnlockUser 清除用户的登录失败计数与锁定。
+func (s *Service) UnlockUser(ctx context.Context, userID int) error {
+	user, err := s.store.GetUserByID(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrUserNotFound
+		}
+		return err
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, _ := token.SignedString(middlewares.JWTSecret)
-	return tokenString, nil
+	return s.guard.Unlock(ctx, user.Username)
 }
 
 // EnsureAdmin 确保预置管理员存在。
-func EnsureAdmin(ctx context.Context, cfg config.AdminConfig) error {
+func (s *Service) EnsureAdmin(ctx context.Context, cfg config.AdminConfig) error {
 	if cfg.Username == "" || cfg.Password == "" {
 		return nil
 	}
-	count, err := store.CountUsersByUsername(ctx, cfg.Username)
+	count, err := s.store.CountUsersByUsername(ctx, cfg.Username)
 	if err != nil {
 		return err
 	}
@@ -78,17 +103,23 @@ func EnsureAdmin(ctx context.Context, cfg config.AdminConfig) error {
 	if total < 0 {
 		total = 0
 	}
+	// 预置管理员密码由运维在配置中指定，不满足策略时仅告警，避免服务无法启动。
+	if s.passwords != nil {
+		if err := s.passwords.Validate(cfg.Password, cfg.Username); err != nil {
+			log.Printf("admin password does not satisfy password policy: %v", err)
+		}
+	}
 	hashed, err := hashPassword(cfg.Password)
 	if err != nil {
 		return err
 	}
-	_, err = store.CreateUser(ctx, cfg.Username, hashed, nickname, "ADMIN", total, 0)
+	_, err = s.store.CreateUser(ctx, cfg.Username, hashed, nickname, middlewares.RoleAdmin, total, 0)
 	return err
 }
 
-// ResetPassword 校验旧密码并更新为新密码。
-func ResetPassword(ctx context.Context, username, oldPassword, newPassword string) error {
-	_, current, err := store.GetUserPassword(ctx, username)
+// ResetPassword 校验旧密码并更新为新密码，同时清除改密标记，并使该用户已签发的访问令牌与会话全部失效。
+func (s *Service) ResetPassword(ctx context.Context, username, oldPassword, newPassword string) error {
+	userID, current, err := s.store.GetUserPassword(ctx, username)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return ErrUserNotFound
@@ -102,11 +133,41 @@ func ResetPassword(ctx context.Context, username, oldPassword, newPassword strin
 	if !ok {
 		return ErrInvalidCredentials
 	}
-	hashed, err := hashPassword(newPassword)
+	hashed, err := s.hashNewPassword(newPassword, username)
 	if err != nil {
 		return err
 	}
-	return store.UpdateUserPassword(ctx, username, hashed)
+	return s.store.WithTx(ctx, func(ctx context.Context) error {
+		return s.applyPassword(ctx, userID, username, hashed, false)
+	})
+}
+
+// applyPassword 在事务中更新密码与改密标记，并使用户已签发的访问令牌、会话与未使用的重置令牌全部失效。
+func (s *Service) applyPassword(ctx context.Context, userID int, username, hashed string, mustChange bool) error {
+	if err := s.store.UpdateUserPassword(ctx, username, hashed); err != nil {
+		return err
+	}
+	if err := s.store.SetUserMustChangePassword(ctx, userID, mustChange); err != nil {
+		return err
+	}
+	if err := s.store.BumpUserTokenVersion(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.store.InvalidateUserPasswordResetTokens(ctx, userID); err != nil {
+		return err
+	}
+	_, err := s.store.RevokeUserRefreshTokens(ctx, userID)
+	return err
+}
+
+// hashNewPassword 按密码策略校验用户设置的新密码并生成哈希；未配置策略时仅要求非空。
+func (s *Service) hashNewPassword(password, username string) (string, error) {
+	if s.passwords != nil {
+		if err := s.passwords.Validate(password, username); err != nil {
+			return "", fmt.Errorf("%w: %w", ErrWeakPassword, err)
+		}
+	}
+	return hashPassword(password)
 }
 
 func hashPassword(password string) (string, error) {
@@ -137,17 +198,3 @@ func verifyPassword(stored, password string) (bool, bool, error) {
 func isBcryptHash(value string) bool {
 	return strings.HasPrefix(value, "$2") && len(value) >= 60
 }
-
-// RefreshToken 刷新 JWT。
-func RefreshToken(username string) (string, error) {
-	claims := middlewares.MyClaims{
-		Username: username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, _ := token.SignedString(middlewares.JWTSecret)
-	return tokenString, nil
-}
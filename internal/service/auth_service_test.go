@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"backend/internal/config"
+	"backend/internal/loginguard"
+)
+
+func TestLoginUnknownUserMatchesWrongPassword(t *testing.T) {
+	s, _, _ := newTestService(t)
+	s.guard = loginguard.New(config.LoginGuardConfig{FreeAttempts: 1, MaxAttempts: 2}, loginguard.NewMemoryStore())
+	userID := newTestLogin(t, s)
+	ctx := context.Background()
+
+	// 用户不存在时与已有用户密码错误一样，与假哈希做一次 bcrypt 比对。
+	if !isBcryptHash(dummyPasswordHash()) {
+		t.Fatalf("dummy hash %q is not bcrypt", dummyPasswordHash())
+	}
+	if ok, _, err := verifyPassword(dummyPasswordHash(), ""); ok || err != nil {
+		t.Fatalf("dummy hash matched an empty password: %v %v", ok, err)
+	}
+
+	for _, username := range []string{"alice", "nobody"} {
+		for i := range 2 {
+			if _, err := s.Login(ctx, username, "wrong", ""); !errors.Is(err, ErrInvalidCredentials) {
+				t.Fatalf("%s attempt %d err = %v", username, i+1, err)
+			}
+		}
+		// 不存在的用户名同样计数并锁定，不能据此探测账号。
+		if _, err := s.Login(ctx, username, "wrong", ""); !errors.Is(err, loginguard.ErrLocked) {
+			t.Errorf("%s after lockout err = %v", username, err)
+		}
+	}
+
+	if err := s.UnlockUser(ctx, userID); err != nil {
+		t.Fatal(err)
+	}
+	mustLogin(t, s, "old-password")
+	if err := s.UnlockUser(ctx, 9999); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("unlock unknown user err = %v", err)
+	}
+}
+
+func TestLoginUpgradesLegacyPassword(t *testing.T) {
+	s, st, _ := newTestService(t)
+	ctx := context.Background()
+	if _, err := st.CreateUserWithQuota(ctx, "alice", "plain-text", "alice", "user", 100, 0); err != nil {
+		t.Fatal(err)
+	}
+	mustLogin(t, s, "plain-text")
+	_, stored, err := st.GetUserPassword(ctx, "alice")
+	if err != nil || !isBcryptHash(stored) {
+		t.Fatalf("stored password = %q, err %v", stored, err)
+	}
+	mustLogin(t, s, "plain-text")
+}
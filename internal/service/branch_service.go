@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+
+	"backend/internal/store"
+)
+
+var (
+	// ErrMessageNotFound 消息不存在或不在当前分支上。
+	ErrMessageNotFound = errors.New("message not found")
+	// ErrNotUserMessage 只能编辑用户消息。
+	ErrNotUserMessage = errors.New("only user messages can be edited")
+	// ErrNothingToRegenerate 当前分支上没有可重新生成回复的用户消息。
+	ErrNothingToRegenerate = errors.New("no message to regenerate")
+)
+
+// HistoryMessage 当前分支上的一条消息及其替代版本。
+type HistoryMessage struct {
+	store.MessageRow
+	// SiblingIDs 与该消息同父的全部版本（含自身），按创建顺序排列。
+	SiblingIDs []int
+}
+
+// messageTree 会话的消息树：重新生成或编辑产生的新版本与原消息同父，互为兄弟。
+type messageTree struct {
+	byID map[int]store.MessageRow
+	// children 父消息ID（0 表示会话开头）到子消息ID，按ID升序。
+	children map[int][]int
+}
+
+func newMessageTree(rows []store.MessageRow) *messageTree {
+	t := &messageTree{
+		byID:     make(map[int]store.MessageRow, len(rows)),
+		children: make(map[int][]int),
+	}
+	for _, m := range rows {
+		t.byID[m.MessageID] = m
+	}
+	for _, m := range rows {
+		parent := t.parentOf(m)
+		t.children[parent] = append(t.children[parent], m.MessageID)
+	}
+	for _, ids := range t.children {
+		sort.Ints(ids)
+	}
+	return t
+}
+
+// parentOf 返回消息在树中的父消息ID，父消息不存在时视为会话开头。
+func (t *messageTree) parentOf(m store.MessageRow) int {
+	if _, ok := t.byID[m.ParentID]; ok && m.ParentID < m.MessageID {
+		return m.ParentID
+	}
+	return 0
+}
+
+// latestLeaf 从 id 出发沿最新的子消息走到分支末尾；id 为 0 时从会话开头出发。
+func (t *messageTree) latestLeaf(id int) int {
+	for {
+		kids := t.children[id]
+		if len(kids) == 0 {
+			return id
+		}
+		id = kids[len(kids)-1]
+	}
+}
+
+// path 返回从会话开头到 leafID（含）的消息。
+func (t *messageTree) path(leafID int) []store.MessageRow {
+	out := make([]store.MessageRow, 0)
+	for id := leafID; id != 0; {
+		m, ok := t.byID[id]
+		if !ok {
+			break
+		}
+		out = append(out, m)
+		id = t.parentOf(m)
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// siblings 返回与消息同父的全部消息ID（含自身）。
+func (t *messageTree) siblings(m store.MessageRow) []int {
+	return t.children[t.parentOf(m)]
+}
+
+// loadBranch 读取会话与全部消息，返回消息树与当前分支（从会话开头到活动消息所在分支的末尾）。
+func (s *Service) loadBranch(ctx context.Context, userID, conversationID int) (store.ConversationInfo, *messageTree, []store.MessageRow, error) {
+	conv, err := s.store.GetConversation(ctx, conversationID, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return store.ConversationInfo{}, nil, nil, ErrConversationNotFound
+		}
+		return store.ConversationInfo{}, nil, nil, err
+	}
+	rows, _, err := s.store.ListAllMessages(ctx, userID, conversationID)
+	if err != nil {
+		return store.ConversationInfo{}, nil, nil, err
+	}
+	tree := newMessageTree(rows)
+	active := conv.ActiveMessageID
+	if _, ok := tree.byID[active]; !ok {
+		active = 0
+	}
+	return conv, tree, tree.path(tree.latestLeaf(active)), nil
+}
+
+// Regenerate 为当前分支上最后一条用户消息重新生成回复，旧回复保留为替代版本；onDelta 为 nil 时不使用流式输出。
+func (s *Service) Regenerate(ctx context.Context, userID, conversationID int, onDelta func(string)) (ChatTurnResult, error) {
+	return s.generateTurn(ctx, userID, conversationID, onDelta, func(conv store.ConversationInfo, branch []store.MessageRow) (chatTurn, error) {
+		i := len(branch) - 1
+		for i >= 0 && branch[i].SenderType != store.SenderUser {
+			i--
+		}
+		if i < 0 {
+			return chatTurn{}, ErrNothingToRegenerate
+		}
+		return chatTurn{
+			userID:      userID,
+			conv:        conv,
+			history:     branch[:i],
+			userMessage: branch[i],
+		}, nil
+	})
+}
+
+// EditMessage 以新内容替换当前分支上的一条用户消息并从该处重新生成回复，原消息及其后续保留为替代分支。
+// attachmentIDs 中属于原消息的附件会复制到新消息，其余按发送消息的方式绑定；onDelta 为 nil 时不使用流式输出。
+func (s *Service) EditMessage(ctx context.Context, userID, conversationID, messageID int, contentType, content string, attachmentIDs []int, onDelta func(string)) (ChatTurnResult, error) {
+	return s.generateTurn(ctx, userID, conversationID, onDelta, func(conv store.ConversationInfo, branch []store.MessageRow) (chatTurn, error) {
+		i := indexOfMessage(branch, messageID)
+		if i < 0 {
+			return chatTurn{}, ErrMessageNotFound
+		}
+		if branch[i].SenderType != store.SenderUser {
+			return chatTurn{}, ErrNotUserMessage
+		}
+
+		original, err := s.store.LoadAttachmentsMap(ctx, []int{messageID})
+		if err != nil {
+			return chatTurn{}, err
+		}
+		owned := make(map[int]bool, len(original[messageID]))
+		for _, a := range original[messageID] {
+			owned[a.AttachmentID] = true
+		}
+		turn := chatTurn{
+			userID:      userID,
+			conv:        conv,
+			history:     branch[:i],
+			userMessage: store.MessageRow{SenderType: store.SenderUser, ContentType: contentType, Content: content},
+		}
+		for _, id := range attachmentIDs {
+			if owned[id] {
+				turn.copyAttachmentIDs = append(turn.copyAttachmentIDs, id)
+			} else {
+				turn.attachmentIDs = append(turn.attachmentIDs, id)
+			}
+		}
+		return turn, nil
+	})
+}
+
+// SwitchBranch 切换到包含 messageID 的分支：当前分支改为从该消息沿最新版本走到末尾。
+func (s *Service) SwitchBranch(ctx context.Context, userID, conversationID, messageID int) error {
+	_, tree, _, err := s.loadBranch(ctx, userID, conversationID)
+	if err != nil {
+		return err
+	}
+	if _, ok := tree.byID[messageID]; !ok {
+		return ErrMessageNotFound
+	}
+	return s.store.SetActiveMessage(ctx, conversationID, tree.latestLeaf(messageID))
+}
+
+// GetHistory 按时间倒序分页获取会话当前分支的消息，并附带各消息的替代版本。
+func (s *Service) GetHistory(ctx context.Context, userID, conversationID, page, pageSize int) ([]HistoryMessage, map[int][]store.AttachmentInfo, int, error) {
+	_, tree, branch, err := s.loadBranch(ctx, userID, conversationID)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	totalCount := len(branch)
+	items := make([]HistoryMessage, 0, pageSize)
+	for i := totalCount - 1 - (page-1)*pageSize; i >= 0 && len(items) < pageSize; i-- {
+		items = append(items, HistoryMessage{MessageRow: branch[i], SiblingIDs: tree.siblings(branch[i])})
+	}
+	ids := make([]int, 0, len(items))
+	for _, m := range items {
+		ids = append(ids, m.MessageID)
+	}
+	attachmentsMap, err := s.store.LoadAttachmentsMap(ctx, ids)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return items, attachmentsMap, totalCount, nil
+}
+
+func indexOfMessage(items []store.MessageRow, messageID int) int {
+	for i, m := range items {
+		if m.MessageID == messageID {
+			return i
+		}
+	}
+	return -1
+}
+
+func messageIDs(items []store.MessageRow) []int {
+	ids := make([]int, 0, len(items))
+	for _, m := range items {
+		ids = append(ids, m.MessageID)
+	}
+	return ids
+}
+
+// lastMessageID 返回分支末条消息ID，空分支返回 0。
+func lastMessageID(items []store.MessageRow) int {
+	if len(items) == 0 {
+		return 0
+	}
+	return items[len(items)-1].MessageID
+}
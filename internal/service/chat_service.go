@@ -8,233 +8,392 @@ import (
 
 	"backend/internal/llm"
 	"backend/internal/store"
-
-	arkmodel "github.com/volcengine/volcengine-go-sdk/service/arkruntime/model"
 )
 
 var (
 	// ErrConversationNotFound 对话不存在。
 	ErrConversationNotFound = errors.New("conversation not found")
+	// ErrConversationArchived 会话已归档，恢复前不能继续对话。
+	ErrConversationArchived = errors.New("conversation archived")
 	// ErrLLMNotReady 模型服务不可用。
 	ErrLLMNotReady = errors.New("llm not initialized")
 	// ErrQuotaExceeded ?????
 	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrPromptPresetNotFound 提示词预设不存在。
+	ErrPromptPresetNotFound = errors.New("prompt preset not found")
 )
 
+// completionReserveTokens 发送前为模型回复预占的额度。
+const completionReserveTokens = 1024
+
+// ChatTurnResult 一轮对话的落库结果。
+type ChatTurnResult struct {
+	// UserMessage 本轮的用户消息；重新生成时为已有的原消息。
+	UserMessage  store.MessageRow
+	ModelMessage store.MessageRow
+	Attachments  []store.AttachmentInfo
+	Reply        string
+	Usage        llm.Usage
+}
+
+// chatTurn 一轮对话的输入：在 history（当前分支）之后接上用户消息并生成回复。
+// userMessage.MessageID 为 0 时落库新的用户消息；否则为重新生成，只新增一条与旧回复同父的回复。
+type chatTurn struct {
+	userID      int
+	conv        store.ConversationInfo
+	history     []store.MessageRow
+	userMessage store.MessageRow
+	// attachmentIDs 移动到新用户消息的附件（如刚上传的文件），copyAttachmentIDs 复制一份的附件（如被编辑消息的原附件）。
+	attachmentIDs     []int
+	copyAttachmentIDs []int
+}
+
 // SendMessage 发送消息并写入用户消息与模型回复。
-func SendMessage(ctx context.Context, userID, conversationID int, contentType, content string, attachmentIDs []int) (int, int, []store.AttachmentInfo, string, error) {
-	if _, err := store.GetConversation(ctx, conversationID, userID); err != nil {
-		if err == sql.ErrNoRows {
-			return 0, 0, nil, "", ErrConversationNotFound
+func (s *Service) SendMessage(ctx context.Context, userID, conversationID int, contentType, content string, attachmentIDs []int) (ChatTurnResult, error) {
+	return s.sendMessage(ctx, userID, conversationID, contentType, content, attachmentIDs, nil)
+}
+
+// SendMessageStream 以流式方式发送消息，onDelta 依次收到模型增量文本；流结束后再扣减额度并写入消息。
+func (s *Service) SendMessageStream(ctx context.Context, userID, conversationID int, contentType, content string, attachmentIDs []int, onDelta func(string)) (ChatTurnResult, error) {
+	if onDelta == nil {
+		onDelta = func(string) {}
+	}
+	return s.sendMessage(ctx, userID, conversationID, contentType, content, attachmentIDs, onDelta)
+}
+
+func (s *Service) sendMessage(ctx context.Context, userID, conversationID int, contentType, content string, attachmentIDs []int, onDelta func(string)) (ChatTurnResult, error) {
+	return s.generateTurn(ctx, userID, conversationID, onDelta, func(conv store.ConversationInfo, branch []store.MessageRow) (chatTurn, error) {
+		return chatTurn{
+			userID:        userID,
+			conv:          conv,
+			history:       branch,
+			userMessage:   store.MessageRow{SenderType: store.SenderUser, ContentType: contentType, Content: content},
+			attachmentIDs: attachmentIDs,
+		}, nil
+	})
+}
+
+// generateTurn 先登记会话的生成再读取当前分支，由 prepare 据此构造本轮输入并交给 runTurn。
+// 登记在读取之前，保证同一会话的并发请求不会基于同一分支各自接上回复。
+func (s *Service) generateTurn(
+	ctx context.Context,
+	userID, conversationID int,
+	onDelta func(string),
+	prepare func(conv store.ConversationInfo, branch []store.MessageRow) (chatTurn, error),
+) (ChatTurnResult, error) {
+	genCtx, done, err := s.startGeneration(ctx, conversationID)
+	if err != nil {
+		// 不向非所有者透露会话是否正在生成。
+		if _, gerr := s.store.GetConversation(ctx, conversationID, userID); gerr == sql.ErrNoRows {
+			return ChatTurnResult{}, ErrConversationNotFound
 		}
-		return 0, 0, nil, "", err
+		return ChatTurnResult{}, err
 	}
+	defer done()
 
-	client := llm.Get()
-	if client == nil {
-		return 0, 0, nil, "", ErrLLMNotReady
+	conv, _, branch, err := s.loadBranch(ctx, userID, conversationID)
+	if err != nil {
+		return ChatTurnResult{}, err
 	}
+	turn, err := prepare(conv, branch)
+	if err != nil {
+		return ChatTurnResult{}, err
+	}
+	return s.runTurn(ctx, genCtx, turn, onDelta)
+}
 
-	totalQuota, usedQuota, err := store.GetUserQuotaUsage(ctx, userID)
+// runTurn 组装上下文、预占额度并以 genCtx 调用模型，完成后落库；onDelta 为 nil 时不使用流式输出。
+// 调用方须已通过 startGeneration 登记会话的生成，genCtx 即其返回的 ctx。
+func (s *Service) runTurn(ctx, genCtx context.Context, turn chatTurn, onDelta func(string)) (ChatTurnResult, error) {
+	conversationID := turn.conv.ConversationID
+	if turn.conv.Status != store.ConversationStatusActive {
+		return ChatTurnResult{}, ErrConversationArchived
+	}
+	client, model, err := s.resolveModel(ctx, turn.userID, turn.conv.LLMModel)
 	if err != nil {
-		return 0, 0, nil, "", err
+		return ChatTurnResult{}, err
 	}
-	if usedQuota >= totalQuota {
-		return 0, 0, nil, "", ErrQuotaExceeded
+
+	var attachmentsForLLM []store.AttachmentInfo
+	if turn.userMessage.MessageID != 0 {
+		attachmentsMap, err := s.store.LoadAttachmentsMap(ctx, []int{turn.userMessage.MessageID})
+		if err != nil {
+			return ChatTurnResult{}, err
+		}
+		attachmentsForLLM = attachmentsMap[turn.userMessage.MessageID]
+	} else {
+		attachmentIDs := append(append([]int(nil), turn.copyAttachmentIDs...), turn.attachmentIDs...)
+		attachmentsForLLM, err = s.store.LoadAttachmentsByIDs(ctx, turn.userID, attachmentIDs)
+		if err != nil {
+			return ChatTurnResult{}, err
+		}
 	}
-	attachmentsForLLM, err := store.LoadAttachmentsByIDs(ctx, userID, attachmentIDs)
+	historyAttachments, err := s.store.LoadAttachmentsMap(ctx, messageIDs(turn.history))
 	if err != nil {
-		return 0, 0, nil, "", err
+		return ChatTurnResult{}, err
 	}
-	historyItems, historyIDs, err := store.ListAllMessages(ctx, userID, conversationID)
+	systemPrompt, err := s.store.GetConversationSystemPrompt(ctx, conversationID)
 	if err != nil {
-		return 0, 0, nil, "", err
+		return ChatTurnResult{}, err
 	}
-	historyAttachments, err := store.LoadAttachmentsMap(ctx, historyIDs)
+	summary, historyItems, err := s.applySummary(ctx, conversationID, turn.history)
 	if err != nil {
-		return 0, 0, nil, "", err
+		return ChatTurnResult{}, err
 	}
-	messages, err := buildLLMMessages(ctx, historyItems, historyAttachments, content, attachmentsForLLM)
+	messages, err := s.buildLLMMessages(ctx, model.Multimodal, systemPrompt, summary, historyItems, historyAttachments, turn.userMessage.Content, attachmentsForLLM)
 	if err != nil {
-		return 0, 0, nil, "", err
+		return ChatTurnResult{}, err
 	}
-	reply, usage, err := client.ChatCompletion(ctx, messages)
+	messages = fitContext(messages, model.PromptBudget())
+
+	reservation, err := s.ReserveQuota(ctx, turn.userID, llm.EstimateMessagesTokens(messages)+completionReserveTokens)
 	if err != nil {
-		return 0, 0, nil, "", err
+		return ChatTurnResult{}, err
 	}
-	totalTokens := usage.TotalTokens
-	if totalTokens == 0 {
-		totalTokens = usage.PromptTokens + usage.CompletionTokens
+	defer reservation.Release(ctx)
+
+	var (
+		reply string
+		usage llm.Usage
+	)
+	if onDelta != nil {
+		reply, usage, err = client.ChatCompletionStream(genCtx, messages, onDelta)
+	} else {
+		reply, usage, err = client.ChatCompletion(genCtx, messages)
 	}
-	if totalTokens > 0 {
-		if err := store.IncreaseUserUsedQuota(ctx, userID, totalTokens); err != nil {
-			return 0, 0, nil, "", err
+	status := store.MessageStatusCompleted
+	if err != nil {
+		// 被 StopGeneration 停止或客户端断开时保留已生成的部分回复，按实际用量计费；
+		// 尚未生成任何内容时不保存空回复，预占额度随 Release 释放。
+		if genCtx.Err() == nil {
+			return ChatTurnResult{}, err
 		}
+		if reply == "" {
+			return ChatTurnResult{}, ErrGenerationStopped
+		}
+		status = store.MessageStatusStopped
+		usage = stoppedUsage(usage, messages, reply)
 	}
-
-	userMsgID, err := store.InsertMessage(ctx, conversationID, store.SenderUser, contentType, content, len(content))
+	result, err := s.persistTurn(ctx, reservation, turn, attachmentsForLLM, reply, usage, status)
 	if err != nil {
-		return 0, 0, nil, "", err
+		return ChatTurnResult{}, err
 	}
 
-	if err := store.AttachFilesToMessage(ctx, userID, userMsgID, attachmentIDs); err != nil {
-		return 0, 0, nil, "", err
+	s.maybeSummarize(turn.userID, conversationID, client)
+	s.maybeGenerateTitle(turn.userID, turn.conv, result.UserMessage.Content, result.Reply, client)
+
+	return result, nil
+}
+
+// persistTurn 在同一事务中完成额度结算、写入用户消息、绑定附件、写入模型回复（状态为 status）并将其设为当前分支末尾，任一步失败则整体回滚。
+// 上游已完成调用，客户端断开不应导致落库失败，因此事务不随请求取消。
+func (s *Service) persistTurn(
+	ctx context.Context,
+	reservation *QuotaReservation,
+	turn chatTurn,
+	attachments []store.AttachmentInfo,
+	reply string,
+	usage llm.Usage,
+	status string,
+) (ChatTurnResult, error) {
+	conversationID := turn.conv.ConversationID
+	result := ChatTurnResult{
+		UserMessage: turn.userMessage,
+		Attachments: make([]store.AttachmentInfo, 0),
+		Reply:       reply,
+		Usage:       usage,
 	}
+	err := s.store.WithTx(context.WithoutCancel(ctx), func(ctx context.Context) error {
+		if err := reservation.Settle(ctx, usage.Total()); err != nil {
+			return err
+		}
 
-	attachments := make([]store.AttachmentInfo, 0)
-	if len(attachmentIDs) > 0 {
-		attachmentsMap, err := store.LoadAttachmentsMap(ctx, []int{userMsgID})
-		if err != nil {
-			return 0, 0, nil, "", err
+		// 本轮 prompt 用量记在用户消息上，completion 用量记在模型回复上；
+		// 重新生成时用户消息已存在，prompt 用量改记在新的回复上。
+		replyPromptTokens := 0
+		if result.UserMessage.MessageID == 0 {
+			user := &result.UserMessage
+			user.ParentID = lastMessageID(turn.history)
+			user.Status = store.MessageStatusCompleted
+			user.PromptTokens = usage.PromptTokens
+			user.TokenTotal = usage.PromptTokens
+			userMsgID, err := s.store.InsertMessage(ctx, conversationID, user.ParentID, store.SenderUser, user.ContentType, user.Content, usage.PromptTokens, 0)
+			if err != nil {
+				return err
+			}
+			user.MessageID = userMsgID
+
+			if err := s.store.CopyAttachmentsToMessage(ctx, turn.userID, userMsgID, turn.copyAttachmentIDs); err != nil {
+				return err
+			}
+			if err := s.store.AttachFilesToMessage(ctx, turn.userID, userMsgID, turn.attachmentIDs); err != nil {
+				return err
+			}
+			if len(turn.attachmentIDs)+len(turn.copyAttachmentIDs) > 0 {
+				attachmentsMap, err := s.store.LoadAttachmentsMap(ctx, []int{userMsgID})
+				if err != nil {
+					return err
+				}
+				result.Attachments = attachmentsMap[userMsgID]
+			}
+		} else {
+			replyPromptTokens = usage.PromptTokens
+			if len(attachments) > 0 {
+				result.Attachments = attachments
+			}
 		}
-		attachments = attachmentsMap[userMsgID]
-	}
 
-	modelMsgID, err := store.InsertMessage(ctx, conversationID, store.SenderAssistant, "TEXT", reply, len(reply))
+		modelMsgID, err := s.store.InsertMessage(ctx, conversationID, result.UserMessage.MessageID, store.SenderAssistant, "TEXT", reply, replyPromptTokens, usage.CompletionTokens)
+		if err != nil {
+			return err
+		}
+		if status != store.MessageStatusCompleted {
+			if err := s.store.SetMessageStatus(ctx, modelMsgID, status); err != nil {
+				return err
+			}
+		}
+		result.ModelMessage = store.MessageRow{
+			MessageID:        modelMsgID,
+			ParentID:         result.UserMessage.MessageID,
+			SenderType:       store.SenderAssistant,
+			ContentType:      "TEXT",
+			Content:          reply,
+			Status:           status,
+			PromptTokens:     replyPromptTokens,
+			CompletionTokens: usage.CompletionTokens,
+			TokenTotal:       replyPromptTokens + usage.CompletionTokens,
+		}
+		return s.store.SetActiveMessage(ctx, conversationID, modelMsgID)
+	})
 	if err != nil {
-		return 0, 0, nil, "", err
+		return ChatTurnResult{}, err
 	}
-
-	return userMsgID, modelMsgID, attachments, reply, nil
+	return result, nil
 }
 
-func buildLLMMessages(
+func (s *Service) buildLLMMessages(
 	ctx context.Context,
+	multimodal bool,
+	systemPrompt string,
+	summary string,
 	history []store.MessageRow,
 	historyAttachments map[int][]store.AttachmentInfo,
 	content string,
 	currentAttachments []store.AttachmentInfo,
-) ([]*arkmodel.ChatCompletionMessage, error) {
-	messages := make([]*arkmodel.ChatCompletionMessage, 0, len(history)+1)
+) ([]llm.Message, error) {
+	messages := make([]llm.Message, 0, len(history)+2)
+
+	if strings.TrimSpace(systemPrompt) != "" {
+		messages = append(messages, llm.Message{Role: llm.RoleSystem, Content: systemPrompt})
+	}
+	if summary != "" {
+		messages = append(messages, llm.Message{Role: llm.RoleSystem, Content: summaryContextPrefix + summary})
+	}
 
 	for _, msg := range history {
 		role := senderTypeToRole(msg.SenderType)
 		attachments := historyAttachments[msg.MessageID]
 		if len(attachments) == 0 {
-			text := msg.Content
-			messages = append(messages, &arkmodel.ChatCompletionMessage{
-				Role: role,
-				Content: &arkmodel.ChatCompletionMessageContent{
-					StringValue: &text,
-				},
-			})
+			messages = append(messages, llm.Message{Role: role, Content: msg.Content})
 			continue
 		}
 
-		parts, err := buildContentParts(ctx, msg.Content, attachments)
+		parts, err := s.buildContentParts(ctx, multimodal, msg.Content, attachments)
 		if err != nil {
 			return nil, err
 		}
-		messages = append(messages, &arkmodel.ChatCompletionMessage{
-			Role: role,
-			Content: &arkmodel.ChatCompletionMessageContent{
-				ListValue: parts,
-			},
-		})
+		messages = append(messages, llm.Message{Role: role, Parts: parts})
 	}
 
 	if len(currentAttachments) == 0 {
-		messages = append(messages, &arkmodel.ChatCompletionMessage{
-			Role: arkmodel.ChatMessageRoleUser,
-			Content: &arkmodel.ChatCompletionMessageContent{
-				StringValue: &content,
-			},
-		})
+		messages = append(messages, llm.Message{Role: llm.RoleUser, Content: content})
 		return messages, nil
 	}
 
-	parts, err := buildContentParts(ctx, content, currentAttachments)
+	parts, err := s.buildContentParts(ctx, multimodal, content, currentAttachments)
 	if err != nil {
 		return nil, err
 	}
-	messages = append(messages, &arkmodel.ChatCompletionMessage{
-		Role: arkmodel.ChatMessageRoleUser,
-		Content: &arkmodel.ChatCompletionMessageContent{
-			ListValue: parts,
-		},
-	})
+	messages = append(messages, llm.Message{Role: llm.RoleUser, Parts: parts})
 	return messages, nil
 }
 
-func buildContentParts(ctx context.Context, text string, attachments []store.AttachmentInfo) ([]*arkmodel.ChatCompletionMessageContentPart, error) {
-	parts := make([]*arkmodel.ChatCompletionMessageContentPart, 0, len(attachments)+1)
+// buildContentParts 组装多模态片段；模型不支持多模态时附件以 URL 文本形式给出。
+func (s *Service) buildContentParts(ctx context.Context, multimodal bool, text string, attachments []store.AttachmentInfo) ([]llm.ContentPart, error) {
+	parts := make([]llm.ContentPart, 0, len(attachments)+1)
 	for _, attachment := range attachments {
-		url, err := ResolveAttachmentURL(ctx, attachment)
+		url, err := s.ResolveAttachmentURL(ctx, attachment)
 		if err != nil {
 			return nil, err
 		}
 
+		if !multimodal {
+			parts = append(parts, llm.ContentPart{Type: llm.PartText, Text: url})
+			continue
+		}
 		if strings.EqualFold(attachment.AttachmentType, "IMAGE") || strings.HasPrefix(strings.ToLower(attachment.MimeType), "image/") {
-			parts = append(parts, &arkmodel.ChatCompletionMessageContentPart{
-				Type: arkmodel.ChatCompletionMessageContentPartTypeImageURL,
-				ImageURL: &arkmodel.ChatMessageImageURL{
-					URL: url,
-				},
-			})
+			parts = append(parts, llm.ContentPart{Type: llm.PartImageURL, URL: url})
 			continue
 		}
 		if strings.HasPrefix(strings.ToLower(attachment.MimeType), "video/") {
-			parts = append(parts, &arkmodel.ChatCompletionMessageContentPart{
-				Type: arkmodel.ChatCompletionMessageContentPartTypeVideoURL,
-				VideoURL: &arkmodel.ChatMessageVideoURL{
-					URL: url,
-				},
-			})
+			parts = append(parts, llm.ContentPart{Type: llm.PartVideoURL, URL: url})
 			continue
 		}
 
-		parts = append(parts, &arkmodel.ChatCompletionMessageContentPart{
-			Type: arkmodel.ChatCompletionMessageContentPartTypeText,
-			Text: url,
-		})
+		parts = append(parts, llm.ContentPart{Type: llm.PartText, Text: url})
 	}
 
-	parts = append(parts, &arkmodel.ChatCompletionMessageContentPart{
-		Type: arkmodel.ChatCompletionMessageContentPartTypeText,
-		Text: text,
-	})
+	parts = append(parts, llm.ContentPart{Type: llm.PartText, Text: text})
 	return parts, nil
 }
 
 func senderTypeToRole(sender int) string {
 	switch sender {
 	case store.SenderAssistant:
-		return arkmodel.ChatMessageRoleAssistant
+		return llm.RoleAssistant
 	case store.SenderSystem:
-		return arkmodel.ChatMessageRoleSystem
+		return llm.RoleSystem
 	default:
-		return arkmodel.ChatMessageRoleUser
+		return llm.RoleUser
 	}
 }
 
-// NewConversation 创建会话。
-func NewConversation(ctx context.Context, userID int, title string, systemPrompt sql.NullInt64, llmModel string) (store.ConversationInfo, error) {
-	return store.CreateConversation(ctx, userID, title, llmModel, systemPrompt)
+// NewConversation 创建会话，llmModel 为模型目录中的名称（为空时使用默认模型），并保存所选预设内容的快照以防预设被删除。
+func (s *Service) NewConversation(ctx context.Context, userID int, title string, systemPrompt sql.NullInt64, llmModel string) (store.ConversationInfo, error) {
+	model, err := s.ResolveModel(ctx, userID, llmModel)
+	if err != nil {
+		return store.ConversationInfo{}, err
+	}
+	var snapshot sql.NullString
+	if systemPrompt.Valid {
+		preset, err := s.store.GetPromptPreset(ctx, int(systemPrompt.Int64))
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return store.ConversationInfo{}, ErrPromptPresetNotFound
+			}
+			return store.ConversationInfo{}, err
+		}
+		snapshot = sql.NullString{String: preset.Content, Valid: true}
+	}
+	return s.store.CreateConversation(ctx, userID, title, model.Name, systemPrompt, snapshot)
 }
 
 // RenameConversation 重命名会话。
-func RenameConversation(ctx context.Context, userID, conversationID int, title string) (bool, error) {
-	return store.RenameConversation(ctx, conversationID, userID, title)
+func (s *Service) RenameConversation(ctx context.Context, userID, conversationID int, title string) (bool, error) {
+	return s.store.RenameConversation(ctx, conversationID, userID, title)
 }
 
-// DeleteConversation 删除会话。
-func DeleteConversation(ctx context.Context, userID, conversationID int) (bool, error) {
-	return store.DeleteConversation(ctx, conversationID, userID)
+// DeleteConversation 删除会话，删除后在保留期内可恢复，期满由 RunRetention 彻底清除。
+func (s *Service) DeleteConversation(ctx context.Context, userID, conversationID int) (bool, error) {
+	return s.store.DeleteConversation(ctx, conversationID, userID)
 }
 
-// GetHistory 获取会话消息历史。
-func GetHistory(ctx context.Context, userID, conversationID, page, pageSize int) ([]store.MessageRow, map[int][]store.AttachmentInfo, int, error) {
-	totalCount, err := store.CountMessages(ctx, userID, conversationID)
-	if err != nil {
-		return nil, nil, 0, err
-	}
-	items, messageIDs, err := store.ListMessages(ctx, userID, conversationID, page, pageSize)
-	if err != nil {
-		return nil, nil, 0, err
-	}
-	attachmentsMap, err := store.LoadAttachmentsMap(ctx, messageIDs)
-	if err != nil {
-		return nil, nil, 0, err
-	}
-	return items, attachmentsMap, totalCount, nil
+// ArchiveConversation 归档会话，归档后可查看、分叉但不能继续对话。
+func (s *Service) ArchiveConversation(ctx context.Context, userID, conversationID int) (bool, error) {
+	return s.store.ArchiveConversation(ctx, conversationID, userID)
+}
+
+// RestoreConversation 将已归档或已删除的会话恢复为进行中。
+func (s *Service) RestoreConversation(ctx context.Context, userID, conversationID int) (bool, error) {
+	return s.store.RestoreConversation(ctx, conversationID, userID)
 }
@@ -0,0 +1,462 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"backend/internal/config"
+	"backend/internal/jwtauth"
+	"backend/internal/llm"
+	"backend/internal/store"
+	"backend/internal/store/memstore"
+)
+
+// newTestService 创建基于 memstore 与回显 llm.Fake 的 Service，关闭后台摘要与自动命名以免干扰额度断言。
+func newTestService(t *testing.T) (*Service, *memstore.Store, *llm.Fake) {
+	t.Helper()
+	st := memstore.New()
+	fake := llm.NewFake("fake", "")
+	var models llm.Registry
+	models.Set(fake)
+	tokens, err := jwtauth.New(config.AuthConfig{Secret: "test-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(Deps{
+		Store:  st,
+		Models: &models,
+		Tokens: tokens,
+		Title:  config.TitleConfig{Disabled: true},
+	}), st, fake
+}
+
+func newTestConversation(t *testing.T, s *Service, st *memstore.Store, quota int) (int, int) {
+	t.Helper()
+	ctx := context.Background()
+	u, err := st.CreateUserWithQuota(ctx, "alice", "hash", "alice", "user", quota, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conv, err := s.NewConversation(ctx, u.UserID, "chat", sql.NullInt64{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.UserID, conv.ConversationID
+}
+
+func assertBalance(t *testing.T, st store.Store, userID, wantUsed int) {
+	t.Helper()
+	_, used, reserved, err := st.GetUserQuotaBalance(context.Background(), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used != wantUsed || reserved != 0 {
+		t.Errorf("used = %d, reserved = %d, want used %d and nothing reserved", used, reserved, wantUsed)
+	}
+}
+
+// branchContents 返回当前分支上的消息内容，按时间顺序。
+func branchContents(t *testing.T, s *Service, userID, conversationID int) []string {
+	t.Helper()
+	_, _, branch, err := s.loadBranch(context.Background(), userID, conversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make([]string, 0, len(branch))
+	for _, m := range branch {
+		out = append(out, m.Content)
+	}
+	return out
+}
+
+// callTexts 返回 Fake 第 i 次调用收到的消息文本。
+func callTexts(fake *llm.Fake, i int) []string {
+	var out []string
+	for _, m := range fake.Calls()[i] {
+		out = append(out, m.Text())
+	}
+	return out
+}
+
+func TestSendMessage(t *testing.T) {
+	s, st, fake := newTestService(t)
+	userID, convID := newTestConversation(t, s, st, 1000)
+	ctx := context.Background()
+
+	first, err := s.SendMessage(ctx, userID, convID, "TEXT", "hi", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Reply != "echo: hi" || first.ModelMessage.Content != "echo: hi" || first.ModelMessage.Status != store.MessageStatusCompleted {
+		t.Fatalf("result = %+v", first)
+	}
+	if first.ModelMessage.ParentID != first.UserMessage.MessageID || first.UserMessage.ParentID != 0 {
+		t.Errorf("parents: user %d, model %d", first.UserMessage.ParentID, first.ModelMessage.ParentID)
+	}
+	if first.Usage.PromptTokens != 2 || first.Usage.CompletionTokens != 8 {
+		t.Errorf("usage = %+v", first.Usage)
+	}
+	if first.UserMessage.PromptTokens != 2 || first.ModelMessage.CompletionTokens != 8 {
+		t.Errorf("tokens on messages: user %+v, model %+v", first.UserMessage, first.ModelMessage)
+	}
+	assertBalance(t, st, userID, 10)
+
+	var deltas []string
+	second, err := s.SendMessageStream(ctx, userID, convID, "TEXT", "again", nil, func(d string) { deltas = append(deltas, d) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(deltas, "") != "echo: again" {
+		t.Errorf("deltas = %q", deltas)
+	}
+	if second.UserMessage.ParentID != first.ModelMessage.MessageID {
+		t.Errorf("second turn parent = %d, want %d", second.UserMessage.ParentID, first.ModelMessage.MessageID)
+	}
+	if got := callTexts(fake, 1); !slices.Equal(got, []string{"hi", "echo: hi", "again"}) {
+		t.Errorf("context = %q", got)
+	}
+	assertBalance(t, st, userID, 10+second.Usage.Total())
+
+	conv, err := st.GetConversation(ctx, convID, userID)
+	if err != nil || conv.ActiveMessageID != second.ModelMessage.MessageID {
+		t.Errorf("active message = %d, want %d (%v)", conv.ActiveMessageID, second.ModelMessage.MessageID, err)
+	}
+	if _, err := s.SendMessage(ctx, userID+1, convID, "TEXT", "x", nil); !errors.Is(err, ErrConversationNotFound) {
+		t.Errorf("other user err = %v", err)
+	}
+}
+
+func TestRegenerateAndSwitchBranch(t *testing.T) {
+	s, st, fake := newTestService(t)
+	userID, convID := newTestConversation(t, s, st, 1000)
+	ctx := context.Background()
+
+	if _, err := s.Regenerate(ctx, userID, convID, nil); !errors.Is(err, ErrNothingToRegenerate) {
+		t.Fatalf("regenerate on empty conversation err = %v", err)
+	}
+	first, err := s.SendMessage(ctx, userID, convID, "TEXT", "hi", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.Reply = "another answer"
+	again, err := s.Regenerate(ctx, userID, convID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.UserMessage.MessageID != first.UserMessage.MessageID || again.ModelMessage.ParentID != first.UserMessage.MessageID {
+		t.Fatalf("regenerated = %+v", again)
+	}
+	// 重新生成不重复写入用户消息，prompt 用量记在新回复上。
+	if again.ModelMessage.PromptTokens != again.Usage.PromptTokens {
+		t.Errorf("regenerated reply tokens = %+v", again.ModelMessage)
+	}
+	if got := callTexts(fake, 1); !slices.Equal(got, []string{"hi"}) {
+		t.Errorf("regenerate context = %q", got)
+	}
+	assertBalance(t, st, userID, first.Usage.Total()+again.Usage.Total())
+
+	history, _, total, err := s.GetHistory(ctx, userID, convID, 1, 10)
+	if err != nil || total != 2 {
+		t.Fatalf("history total = %d, err %v", total, err)
+	}
+	wantSiblings := []int{first.ModelMessage.MessageID, again.ModelMessage.MessageID}
+	if history[0].Content != "another answer" || !slices.Equal(history[0].SiblingIDs, wantSiblings) {
+		t.Errorf("latest = %+v", history[0])
+	}
+
+	if err := s.SwitchBranch(ctx, userID, convID, first.ModelMessage.MessageID); err != nil {
+		t.Fatal(err)
+	}
+	if got := branchContents(t, s, userID, convID); !slices.Equal(got, []string{"hi", "echo: hi"}) {
+		t.Errorf("branch after switch = %q", got)
+	}
+	if err := s.SwitchBranch(ctx, userID, convID, 9999); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("switch to unknown message err = %v", err)
+	}
+
+	// 在切换后的分支上继续对话。
+	fake.Reply = ""
+	next, err := s.SendMessage(ctx, userID, convID, "TEXT", "more", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.UserMessage.ParentID != first.ModelMessage.MessageID {
+		t.Errorf("next turn parent = %d, want %d", next.UserMessage.ParentID, first.ModelMessage.MessageID)
+	}
+}
+
+func TestEditMessage(t *testing.T) {
+	s, st, fake := newTestService(t)
+	userID, convID := newTestConversation(t, s, st, 1000)
+	ctx := context.Background()
+
+	first, err := s.SendMessage(ctx, userID, convID, "TEXT", "q1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.SendMessage(ctx, userID, convID, "TEXT", "q2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.EditMessage(ctx, userID, convID, first.ModelMessage.MessageID, "TEXT", "x", nil, nil); !errors.Is(err, ErrNotUserMessage) {
+		t.Errorf("editing a reply err = %v", err)
+	}
+	if _, err := s.EditMessage(ctx, userID, convID, 9999, "TEXT", "x", nil, nil); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("editing an unknown message err = %v", err)
+	}
+
+	edited, err := s.EditMessage(ctx, userID, convID, second.UserMessage.MessageID, "TEXT", "q2 edited", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited.UserMessage.MessageID == second.UserMessage.MessageID || edited.UserMessage.ParentID != first.ModelMessage.MessageID {
+		t.Fatalf("edited = %+v", edited.UserMessage)
+	}
+	calls := fake.Calls()
+	if got := callTexts(fake, len(calls)-1); !slices.Equal(got, []string{"q1", "echo: q1", "q2 edited"}) {
+		t.Errorf("edit context = %q", got)
+	}
+	if got := branchContents(t, s, userID, convID); !slices.Equal(got, []string{"q1", "echo: q1", "q2 edited", "echo: q2 edited"}) {
+		t.Errorf("branch after edit = %q", got)
+	}
+
+	// 原消息及其回复保留为替代分支。
+	if err := s.SwitchBranch(ctx, userID, convID, second.UserMessage.MessageID); err != nil {
+		t.Fatal(err)
+	}
+	if got := branchContents(t, s, userID, convID); !slices.Equal(got, []string{"q1", "echo: q1", "q2", "echo: q2"}) {
+		t.Errorf("original branch = %q", got)
+	}
+}
+
+func TestEditMessageCopiesAttachments(t *testing.T) {
+	s, st, _ := newTestService(t)
+	userID, convID := newTestConversation(t, s, st, 1000)
+	ctx := context.Background()
+
+	uploadConv, err := st.GetOrCreateUploadConversation(ctx, userID, "fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploadMsg, err := st.CreateUploadMessage(ctx, uploadConv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attID, err := st.CreateAttachment(ctx, uploadMsg, "FILE", "text/plain", store.StorageTypeLocal, "/uploads/a.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent, err := s.SendMessage(ctx, userID, convID, "TEXT", "read this", []int{attID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sent.Attachments) != 1 || sent.Attachments[0].AttachmentID != attID {
+		t.Fatalf("sent attachments = %+v", sent.Attachments)
+	}
+
+	edited, err := s.EditMessage(ctx, userID, convID, sent.UserMessage.MessageID, "TEXT", "read this again", []int{attID}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edited.Attachments) != 1 || edited.Attachments[0].AttachmentID == attID || edited.Attachments[0].URLOrPath != "/uploads/a.txt" {
+		t.Fatalf("edited attachments = %+v", edited.Attachments)
+	}
+	m, err := st.LoadAttachmentsMap(ctx, []int{sent.UserMessage.MessageID})
+	if err != nil || len(m[sent.UserMessage.MessageID]) != 1 {
+		t.Errorf("original message lost its attachment: %+v %v", m, err)
+	}
+}
+
+func TestQuotaReservation(t *testing.T) {
+	s, st, _ := newTestService(t)
+	ctx := context.Background()
+	u, err := st.CreateUserWithQuota(ctx, "alice", "hash", "alice", "user", 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 估算超出剩余额度时按剩余额度预占。
+	r, err := s.ReserveQuota(ctx, u.UserID, 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, reserved, _ := st.GetUserQuotaBalance(ctx, u.UserID); reserved != 100 {
+		t.Fatalf("reserved = %d, want 100", reserved)
+	}
+	if _, err := s.ReserveQuota(ctx, u.UserID, 1); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("reserve with nothing left err = %v", err)
+	}
+	if err := r.Settle(ctx, 30); err != nil {
+		t.Fatal(err)
+	}
+	r.Release(ctx)
+	if err := r.Settle(ctx, 50); err != nil {
+		t.Fatal(err)
+	}
+	assertBalance(t, st, u.UserID, 30)
+
+	// 事务回滚后结算不生效，Release 仍能释放预占。
+	r, err = s.ReserveQuota(ctx, u.UserID, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	boom := errors.New("boom")
+	err = st.WithTx(ctx, func(ctx context.Context) error {
+		if err := r.Settle(ctx, 20); err != nil {
+			return err
+		}
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("WithTx err = %v", err)
+	}
+	if _, _, reserved, _ := st.GetUserQuotaBalance(ctx, u.UserID); reserved != 20 {
+		t.Fatalf("reserved after rollback = %d, want 20", reserved)
+	}
+	r.Release(ctx)
+	assertBalance(t, st, u.UserID, 30)
+}
+
+func TestSendMessageQuota(t *testing.T) {
+	s, st, fake := newTestService(t)
+	userID, convID := newTestConversation(t, s, st, 1000)
+	ctx := context.Background()
+
+	// 上游失败时释放预占且不落库。
+	fake.Err = errors.New("upstream down")
+	if _, err := s.SendMessage(ctx, userID, convID, "TEXT", "hi", nil); !errors.Is(err, fake.Err) {
+		t.Fatalf("err = %v", err)
+	}
+	assertBalance(t, st, userID, 0)
+	if got := branchContents(t, s, userID, convID); len(got) != 0 {
+		t.Errorf("failed turn persisted %q", got)
+	}
+
+	fake.Err = nil
+	if _, err := s.SetUserQuota(ctx, userID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SendMessage(ctx, userID, convID, "TEXT", "hi", nil); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("err = %v, want ErrQuotaExceeded", err)
+	}
+	if n := len(fake.Calls()); n != 1 {
+		t.Errorf("provider called %d times, want 1", n)
+	}
+}
+
+func TestSendMessageOverReservation(t *testing.T) {
+	s, st, _ := newTestService(t)
+	ctx := context.Background()
+	u, err := st.CreateUserWithQuota(ctx, "alice", "hash", "alice", "user", 1000, 999)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conv, err := s.NewConversation(ctx, u.UserID, "chat", sql.NullInt64{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 只剩 1 个额度时预占 1，实际用量远超预占，已用额度止于总额度。
+	result, err := s.SendMessage(ctx, u.UserID, conv.ConversationID, "TEXT", "hi", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Usage.Total() <= 1 {
+		t.Fatalf("usage = %+v, want more than the 1 token reserved", result.Usage)
+	}
+	assertBalance(t, st, u.UserID, 1000)
+	if _, err := s.SendMessage(ctx, u.UserID, conv.ConversationID, "TEXT", "more", nil); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("send with no quota left err = %v", err)
+	}
+}
+
+func TestArchiveAndDeleteConversation(t *testing.T) {
+	s, st, _ := newTestService(t)
+	userID, convID := newTestConversation(t, s, st, 1000)
+	ctx := context.Background()
+
+	if _, err := s.SendMessage(ctx, userID, convID, "TEXT", "hi", nil); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := s.ArchiveConversation(ctx, userID, convID); err != nil || !ok {
+		t.Fatalf("archive = %v %v", ok, err)
+	}
+	if _, err := s.SendMessage(ctx, userID, convID, "TEXT", "more", nil); !errors.Is(err, ErrConversationArchived) {
+		t.Errorf("send to archived err = %v", err)
+	}
+	if _, err := s.Regenerate(ctx, userID, convID, nil); !errors.Is(err, ErrConversationArchived) {
+		t.Errorf("regenerate in archived err = %v", err)
+	}
+	// 归档会话仍可查看。
+	if _, _, total, err := s.GetHistory(ctx, userID, convID, 1, 10); err != nil || total != 2 {
+		t.Errorf("archived history total = %d, err %v", total, err)
+	}
+	archived, err := s.ListMyConversations(ctx, userID, store.ConversationStatusArchived)
+	if err != nil || len(archived) != 1 || archived[0].ConversationID != convID {
+		t.Errorf("archived list = %+v %v", archived, err)
+	}
+
+	if ok, _ := s.RestoreConversation(ctx, userID, convID); !ok {
+		t.Fatal("restore failed")
+	}
+	if _, err := s.SendMessage(ctx, userID, convID, "TEXT", "more", nil); err != nil {
+		t.Fatalf("send after restore err = %v", err)
+	}
+
+	if ok, _ := s.DeleteConversation(ctx, userID, convID); !ok {
+		t.Fatal("delete failed")
+	}
+	if _, err := s.SendMessage(ctx, userID, convID, "TEXT", "more", nil); !errors.Is(err, ErrConversationNotFound) {
+		t.Errorf("send to deleted err = %v", err)
+	}
+	if _, _, _, err := s.GetHistory(ctx, userID, convID, 1, 10); !errors.Is(err, ErrConversationNotFound) {
+		t.Errorf("deleted history err = %v", err)
+	}
+	if ok, _ := s.RestoreConversation(ctx, userID, convID); !ok {
+		t.Fatal("restore deleted failed")
+	}
+	if got := branchContents(t, s, userID, convID); len(got) != 4 {
+		t.Errorf("restored branch = %q", got)
+	}
+}
+
+func TestSummaryFollowsBranch(t *testing.T) {
+	s, st, fake := newTestService(t)
+	userID, convID := newTestConversation(t, s, st, 10000)
+	ctx := context.Background()
+
+	first, err := s.SendMessage(ctx, userID, convID, "TEXT", "q1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Regenerate(ctx, userID, convID, nil); err != nil {
+		t.Fatal(err)
+	}
+	// 摘要覆盖到旧回复所在的分支，当前分支不应使用它。
+	if _, err := st.InsertSummary(ctx, convID, "old branch summary", first.ModelMessage.MessageID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SendMessage(ctx, userID, convID, "TEXT", "q2", nil); err != nil {
+		t.Fatal(err)
+	}
+	calls := fake.Calls()
+	if got := callTexts(fake, len(calls)-1); !slices.Equal(got, []string{"q1", "echo: q1", "q2"}) {
+		t.Errorf("context on other branch = %q", got)
+	}
+
+	if err := s.SwitchBranch(ctx, userID, convID, first.ModelMessage.MessageID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SendMessage(ctx, userID, convID, "TEXT", "q3", nil); err != nil {
+		t.Fatal(err)
+	}
+	calls = fake.Calls()
+	if got := callTexts(fake, len(calls)-1); !slices.Equal(got, []string{summaryContextPrefix + "old branch summary", "q3"}) {
+		t.Errorf("context on summarized branch = %q", got)
+	}
+}
@@ -0,0 +1,40 @@
+package service
+
+import "backend/internal/llm"
+
+// fitContext 在 token 预算内裁剪上下文：始终保留开头的 system 消息与最后一条（本轮）用户消息，
+// 其余历史从新到旧依次纳入，超出预算的更早消息被丢弃。budget <= 0 时不裁剪。
+func fitContext(messages []llm.Message, budget int) []llm.Message {
+	if budget <= 0 || len(messages) == 0 || llm.EstimateMessagesTokens(messages) <= budget {
+		return messages
+	}
+
+	head := 0
+	for head < len(messages)-1 && messages[head].Role == llm.RoleSystem {
+		head++
+	}
+	system := messages[:head]
+	current := messages[len(messages)-1]
+	history := messages[head : len(messages)-1]
+
+	used := llm.EstimateMessagesTokens(system) + llm.EstimateMessageTokens(current)
+	start := len(history)
+	for start > 0 {
+		cost := llm.EstimateMessageTokens(history[start-1])
+		if used+cost > budget {
+			break
+		}
+		used += cost
+		start--
+	}
+	// 不以孤立的助手回复开头，保证历史从一轮用户提问开始。
+	for start < len(history) && history[start].Role == llm.RoleAssistant {
+		start++
+	}
+
+	out := make([]llm.Message, 0, len(system)+len(history)-start+1)
+	out = append(out, system...)
+	out = append(out, history[start:]...)
+	out = append(out, current)
+	return out
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"backend/internal/llm"
+)
+
+func TestFitContext(t *testing.T) {
+	// 8 个 ASCII 字符估算为 2 token，加上每条消息 4 token 的开销，每条共 6 token。
+	msg := func(role, content string) llm.Message {
+		return llm.Message{Role: role, Content: content + strings.Repeat(".", 8-len(content))}
+	}
+	sys := msg(llm.RoleSystem, "sys")
+	summary := msg(llm.RoleSystem, "summary")
+	u1, a1 := msg(llm.RoleUser, "u1"), msg(llm.RoleAssistant, "a1")
+	u2, a2 := msg(llm.RoleUser, "u2"), msg(llm.RoleAssistant, "a2")
+	cur := msg(llm.RoleUser, "cur")
+	huge := llm.Message{Role: llm.RoleUser, Content: "huge" + strings.Repeat("x", 400)}
+
+	tests := []struct {
+		name     string
+		messages []llm.Message
+		budget   int
+		want     []llm.Message
+	}{
+		{"no budget", []llm.Message{sys, u1, a1, u2, a2, cur}, 0, []llm.Message{sys, u1, a1, u2, a2, cur}},
+		{"fits", []llm.Message{sys, u1, a1, u2, a2, cur}, 36, []llm.Message{sys, u1, a1, u2, a2, cur}},
+		{"drops oldest turn", []llm.Message{sys, u1, a1, u2, a2, cur}, 24, []llm.Message{sys, u2, a2, cur}},
+		{"skips orphaned reply", []llm.Message{sys, u1, a1, u2, a2, cur}, 30, []llm.Message{sys, u2, a2, cur}},
+		{"only reply fits", []llm.Message{sys, u1, a1, u2, a2, cur}, 18, []llm.Message{sys, cur}},
+		{"keeps every system message", []llm.Message{sys, summary, u1, a1, cur}, 12, []llm.Message{sys, summary, cur}},
+		{"no system prompt", []llm.Message{u1, a1, cur}, 12, []llm.Message{cur}},
+		{"history message over budget", []llm.Message{sys, u1, a1, huge, a2, cur}, 30, []llm.Message{sys, cur}},
+		{"current message over budget", []llm.Message{sys, u1, a1, huge}, 30, []llm.Message{sys, huge}},
+		{"only current message", []llm.Message{huge}, 10, []llm.Message{huge}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fitContext(tt.messages, tt.budget)
+			if !slices.EqualFunc(got, tt.want, func(a, b llm.Message) bool { return a.Role == b.Role && a.Content == b.Content }) {
+				t.Fatalf("fitContext = %v, want %v", contents(got), contents(tt.want))
+			}
+		})
+	}
+}
+
+func contents(messages []llm.Message) []string {
+	out := make([]string, 0, len(messages))
+	for _, m := range messages {
+		out = append(out, strings.TrimRight(m.Content, ".x"))
+	}
+	return out
+}
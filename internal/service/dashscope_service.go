@@ -16,17 +16,21 @@ import (
 	"backend/internal/config"
 )
 
-var (
-	dashscopeConfig config.DashscopeConfig
-	dashscopeClient = &http.Client{Timeout: 60 * time.Second}
+// ErrDashscopeNotReady Dashscope config not initialized.
+var ErrDashscopeNotReady = errors.New("dashscope not initialized")
 
-	// ErrDashscopeNotReady Dashscope config not initialized.
-	ErrDashscopeNotReady = errors.New("dashscope not initialized")
-)
+// Dashscope is the speech (ASR/TTS) client backed by Dashscope.
+type Dashscope struct {
+	cfg  config.DashscopeConfig
+	http *http.Client
+}
 
-// InitDashscope stores Dashscope config for later use.
-func InitDashscope(cfg config.DashscopeConfig) {
-	dashscopeConfig = cfg
+// NewDashscope creates a Dashscope client from config.
+func NewDashscope(cfg config.DashscopeConfig) *Dashscope {
+	return &Dashscope{
+		cfg:  cfg,
+		http: &http.Client{Timeout: 60 * time.Second},
+	}
 }
 
 type dashscopeRequest struct {
@@ -67,16 +71,16 @@ type dashscopeResponse struct {
 	} `json:"usage"`
 }
 
-// DashscopeAudioASR calls Dashscope ASR with audio URL.
-func DashscopeAudioASR(ctx context.Context, audioURL string) (string, int, error) {
-	if dashscopeConfig.APIKey == "" {
+// AudioASR calls Dashscope ASR with audio URL.
+func (d *Dashscope) AudioASR(ctx context.Context, audioURL string) (string, int, error) {
+	if d == nil || d.cfg.APIKey == "" {
 		return "", 0, ErrDashscopeNotReady
 	}
-	endpoint := dashscopeConfig.STT.Endpoint
+	endpoint := d.cfg.STT.Endpoint
 	if endpoint == "" {
 		endpoint = "https://dashscope.aliyuncs.com/api/v1/services/aigc/multimodal-generation/generation"
 	}
-	model := dashscopeConfig.STT.Model
+	model := d.cfg.STT.Model
 	if model == "" {
 		model = "qwen-audio-asr"
 	}
@@ -100,10 +104,10 @@ func DashscopeAudioASR(ctx context.Context, audioURL string) (string, int, error
 	if err != nil {
 		return "", 0, err
 	}
-	httpReq.Header.Set("Authorization", "Bearer "+dashscopeConfig.APIKey)
+	httpReq.Header.Set("Authorization", "Bearer "+d.cfg.APIKey)
 	httpReq.Header.Set("Content-Type", "application/json")
 
-	resp, err := dashscopeClient.Do(httpReq)
+	resp, err := d.http.Do(httpReq)
 	if err != nil {
 		return "", 0, err
 	}
@@ -158,18 +162,18 @@ type dashscopeTTSResponse struct {
 	} `json:"usage"`
 }
 
-// DashscopeTTS calls Dashscope TTS and returns audio URL and tokens.
-func DashscopeTTS(ctx context.Context, text, voice, language string) (string, int, error) {
-	if dashscopeConfig.APIKey == "" {
+// TTS calls Dashscope TTS and returns audio URL and tokens.
+func (d *Dashscope) TTS(ctx context.Context, text, voice, language string) (string, int, error) {
+	if d == nil || d.cfg.APIKey == "" {
 		return "", 0, ErrDashscopeNotReady
 	}
-	endpoint := dashscopeConfig.TTS.Endpoint
-	model := dashscopeConfig.TTS.Model
+	endpoint := d.cfg.TTS.Endpoint
+	model := d.cfg.TTS.Model
 	if endpoint == "" || model == "" {
 		return "", 0, ErrDashscopeNotReady
 	}
 	if voice == "" {
-		voice = dashscopeConfig.TTS.Voice
+		voice = d.cfg.TTS.Voice
 	}
 	if voice == "" {
 		return "", 0, ErrDashscopeNotReady
@@ -192,10 +196,10 @@ func DashscopeTTS(ctx context.Context, text, voice, language string) (string, in
 	if err != nil {
 		return "", 0, err
 	}
-	httpReq.Header.Set("Authorization", "Bearer "+dashscopeConfig.APIKey)
+	httpReq.Header.Set("Authorization", "Bearer "+d.cfg.APIKey)
 	httpReq.Header.Set("Content-Type", "application/json")
 
-	resp, err := dashscopeClient.Do(httpReq)
+	resp, err := d.http.Do(httpReq)
 	if err != nil {
 		return "", 0, err
 	}
@@ -225,18 +229,18 @@ func DashscopeTTS(ctx context.Context, text, voice, language string) (string, in
 	return result.Output.Audio.URL, totalTokens, nil
 }
 
-// DashscopeTTSStream calls Dashscope TTS with SSE enabled and streams audio chunks.
-func DashscopeTTSStream(ctx context.Context, text, voice, language string, onChunk func([]byte), onUsage func(int)) error {
-	if dashscopeConfig.APIKey == "" {
+// TTSStream calls Dashscope TTS with SSE enabled and streams audio chunks.
+func (d *Dashscope) TTSStream(ctx context.Context, text, voice, language string, onChunk func([]byte), onUsage func(int)) error {
+	if d == nil || d.cfg.APIKey == "" {
 		return ErrDashscopeNotReady
 	}
-	endpoint := dashscopeConfig.TTS.Endpoint
-	model := dashscopeConfig.TTS.Model
+	endpoint := d.cfg.TTS.Endpoint
+	model := d.cfg.TTS.Model
 	if endpoint == "" || model == "" {
 		return ErrDashscopeNotReady
 	}
 	if voice == "" {
-		voice = dashscopeConfig.TTS.Voice
+		voice = d.cfg.TTS.Voice
 	}
 	if voice == "" {
 		return ErrDashscopeNotReady
@@ -259,11 +263,11 @@ func DashscopeTTSStream(ctx context.Context, text, voice, language string, onChu
 	if err != nil {
 		return err
 	}
-	httpReq.Header.Set("Authorization", "Bearer "+dashscopeConfig.APIKey)
+	httpReq.Header.Set("Authorization", "Bearer "+d.cfg.APIKey)
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("X-DashScope-SSE", "enable")
 
-	resp, err := dashscopeClient.Do(httpReq)
+	resp, err := d.http.Do(httpReq)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+
+	"backend/internal/store"
+)
+
+// ForkConversation 以 messageID（可在任一分支上）为分叉点创建新会话：复制从会话开头到该消息的消息及其附件，
+// 沿用原会话的模型与系统提示词并记录来源；title 为空时沿用原标题。覆盖到复制范围内消息的最新摘要一并复制。
+func (s *Service) ForkConversation(ctx context.Context, userID, conversationID, messageID int, title string) (store.ConversationInfo, error) {
+	src, tree, _, err := s.loadBranch(ctx, userID, conversationID)
+	if err != nil {
+		return store.ConversationInfo{}, err
+	}
+	if _, ok := tree.byID[messageID]; !ok {
+		return store.ConversationInfo{}, ErrMessageNotFound
+	}
+	path := tree.path(messageID)
+	if title == "" {
+		title = src.Title
+	}
+	attachmentsMap, err := s.store.LoadAttachmentsMap(ctx, messageIDs(path))
+	if err != nil {
+		return store.ConversationInfo{}, err
+	}
+	summary, err := s.store.GetLatestSummary(ctx, conversationID, messageIDs(path))
+	if err != nil && err != sql.ErrNoRows {
+		return store.ConversationInfo{}, err
+	}
+
+	var fork store.ConversationInfo
+	err = s.store.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		fork, err = s.store.CreateForkedConversation(ctx, userID, conversationID, messageID, title)
+		if err != nil {
+			return err
+		}
+		copied := make(map[int]int, len(path))
+		parentID := 0
+		for _, m := range path {
+			id, err := s.store.InsertMessage(ctx, fork.ConversationID, parentID, m.SenderType, m.ContentType, m.Content, m.PromptTokens, m.CompletionTokens)
+			if err != nil {
+				return err
+			}
+			if m.Status != store.MessageStatusCompleted {
+				if err := s.store.SetMessageStatus(ctx, id, m.Status); err != nil {
+					return err
+				}
+			}
+			if attachments := attachmentsMap[m.MessageID]; len(attachments) > 0 {
+				ids := make([]int, 0, len(attachments))
+				for _, a := range attachments {
+					ids = append(ids, a.AttachmentID)
+				}
+				if err := s.store.CopyAttachmentsToMessage(ctx, userID, id, ids); err != nil {
+					return err
+				}
+			}
+			copied[m.MessageID] = id
+			parentID = id
+		}
+		if err := s.store.SetActiveMessage(ctx, fork.ConversationID, parentID); err != nil {
+			return err
+		}
+		fork.ActiveMessageID = parentID
+		if covered, ok := copied[summary.CoveredMessageID]; ok {
+			if _, err := s.store.InsertSummary(ctx, fork.ConversationID, summary.Content, covered, summary.TokenTotal); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return store.ConversationInfo{}, ErrConversationNotFound
+		}
+		return store.ConversationInfo{}, err
+	}
+	return fork, nil
+}
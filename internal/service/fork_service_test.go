@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+
+	"backend/internal/store"
+)
+
+func TestForkConversation(t *testing.T) {
+	s, st, fake := newTestService(t)
+	userID, convID := newTestConversation(t, s, st, 10000)
+	ctx := context.Background()
+
+	uploadConv, err := st.GetOrCreateUploadConversation(ctx, userID, "fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploadMsg, err := st.CreateUploadMessage(ctx, uploadConv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attID, err := st.CreateAttachment(ctx, uploadMsg, "FILE", "text/plain", store.StorageTypeLocal, "/uploads/a.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// q1 下有两个回复版本，当前分支为 q1 → second → q2 → echo: q2。
+	fake.Reply = "first"
+	first, err := s.SendMessage(ctx, userID, convID, "TEXT", "q1", []int{attID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.Reply = "second"
+	if _, err := s.Regenerate(ctx, userID, convID, nil); err != nil {
+		t.Fatal(err)
+	}
+	fake.Reply = ""
+	second, err := s.SendMessage(ctx, userID, convID, "TEXT", "q2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q1 := first.UserMessage.MessageID
+	if _, err := st.InsertSummary(ctx, convID, "up to q1", q1, 3); err != nil {
+		t.Fatal(err)
+	}
+	// 更新的摘要覆盖到复制范围之外，不应被复制。
+	if _, err := st.InsertSummary(ctx, convID, "up to q2", second.UserMessage.MessageID, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// 从非当前分支上的旧回复分叉。
+	fork, err := s.ForkConversation(ctx, userID, convID, first.ModelMessage.MessageID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fork.ConversationID == convID || fork.Title != "chat" || fork.ForkedFromConversationID != convID || fork.ForkedFromMessageID != first.ModelMessage.MessageID {
+		t.Fatalf("fork = %+v", fork)
+	}
+	if got := branchContents(t, s, userID, fork.ConversationID); !slices.Equal(got, []string{"q1", "first"}) {
+		t.Errorf("fork branch = %q", got)
+	}
+	rows, _, err := st.ListAllMessages(ctx, userID, fork.ConversationID)
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("fork copied %d messages, err %v", len(rows), err)
+	}
+	forkQ1 := rows[0].MessageID
+	if rows[0].ParentID != 0 || rows[1].ParentID != forkQ1 || rows[1].MessageID != fork.ActiveMessageID {
+		t.Errorf("fork tree = %+v, active %d", rows, fork.ActiveMessageID)
+	}
+
+	attachments, err := st.LoadAttachmentsMap(ctx, []int{forkQ1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := attachments[forkQ1]; len(got) != 1 || got[0].AttachmentID == attID || got[0].URLOrPath != "/uploads/a.txt" {
+		t.Errorf("fork attachments = %+v", got)
+	}
+	summary, err := st.GetLatestSummary(ctx, fork.ConversationID, []int{forkQ1, fork.ActiveMessageID})
+	if err != nil || summary.Content != "up to q1" || summary.CoveredMessageID != forkQ1 {
+		t.Errorf("fork summary = %+v, err %v", summary, err)
+	}
+
+	// 原会话不受影响。
+	if got := branchContents(t, s, userID, convID); !slices.Equal(got, []string{"q1", "second", "q2", "echo: q2"}) {
+		t.Errorf("source branch = %q", got)
+	}
+	named, err := s.ForkConversation(ctx, userID, convID, second.ModelMessage.MessageID, "named")
+	if err != nil || named.Title != "named" {
+		t.Fatalf("named fork = %+v, err %v", named, err)
+	}
+	if got := branchContents(t, s, userID, named.ConversationID); !slices.Equal(got, []string{"q1", "second", "q2", "echo: q2"}) {
+		t.Errorf("named fork branch = %q", got)
+	}
+}
+
+func TestForkConversationRejects(t *testing.T) {
+	s, st, _ := newTestService(t)
+	userID, convID := newTestConversation(t, s, st, 10000)
+	ctx := context.Background()
+	sent, err := s.SendMessage(ctx, userID, convID, "TEXT", "hi", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := st.CreateUserWithQuota(ctx, "mallory", "hash", "mallory", "user", 10000, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherConv, err := s.NewConversation(ctx, other.UserID, "mine", sql.NullInt64{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSent, err := s.SendMessage(ctx, other.UserID, otherConv.ConversationID, "TEXT", "secret", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		userID, convID int
+		messageID      int
+		wantErr        error
+	}{
+		{"other user's conversation", other.UserID, convID, sent.ModelMessage.MessageID, ErrConversationNotFound},
+		{"other user's message", userID, convID, otherSent.ModelMessage.MessageID, ErrMessageNotFound},
+		{"message from another conversation", other.UserID, otherConv.ConversationID, sent.UserMessage.MessageID, ErrMessageNotFound},
+		{"unknown message", userID, convID, 9999, ErrMessageNotFound},
+	}
+	for _, tt := range tests {
+		if _, err := s.ForkConversation(ctx, tt.userID, tt.convID, tt.messageID, ""); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+	for _, id := range []int{userID, other.UserID} {
+		if list, _ := s.ListMyConversations(ctx, id, store.ConversationStatusActive); len(list) != 1 {
+			t.Errorf("user %d has %d conversations after rejected forks", id, len(list))
+		}
+	}
+}
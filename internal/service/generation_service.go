@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"backend/internal/llm"
+)
+
+var (
+	// ErrGenerationInProgress 会话已有进行中的生成。
+	ErrGenerationInProgress = errors.New("generation in progress")
+	// ErrNoGeneration 会话没有进行中的生成。
+	ErrNoGeneration = errors.New("no generation in progress")
+	// ErrGenerationStopped 生成在产生任何内容前被停止或客户端已断开，本轮不落库也不计费；
+	// 同时作为 StopGeneration 的取消原因。
+	ErrGenerationStopped = errors.New("generation stopped")
+)
+
+// generation 进行中的一次生成，登记在 Service.generations 中。
+type generation struct {
+	cancel context.CancelCauseFunc
+}
+
+// startGeneration 为会话登记进行中的生成，返回可被 StopGeneration 取消的 ctx；
+// 同一会话已有生成时返回 ErrGenerationInProgress。生成结束后须调用 done 注销。
+func (s *Service) startGeneration(ctx context.Context, conversationID int) (context.Context, func(), error) {
+	genCtx, cancel := context.WithCancelCause(ctx)
+	g := &generation{cancel: cancel}
+	if _, busy := s.generations.LoadOrStore(conversationID, g); busy {
+		cancel(nil)
+		return nil, nil, ErrGenerationInProgress
+	}
+	return genCtx, func() {
+		s.generations.CompareAndDelete(conversationID, g)
+		cancel(nil)
+	}, nil
+}
+
+// StopGeneration 停止会话进行中的生成；已生成的部分回复以 STOPPED 状态落库，额度只按实际用量扣减，
+// 尚未生成任何内容时本轮不落库，生成请求返回 ErrGenerationStopped。
+// 生成登记在进程内，多实例部署时需将请求路由到发起生成的实例。
+func (s *Service) StopGeneration(ctx context.Context, userID, conversationID int) error {
+	if _, err := s.store.GetConversation(ctx, conversationID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrConversationNotFound
+		}
+		return err
+	}
+	v, ok := s.generations.Load(conversationID)
+	if !ok {
+		return ErrNoGeneration
+	}
+	v.(*generation).cancel(ErrGenerationStopped)
+	return nil
+}
+
+// stoppedUsage 补全被中断调用的用量：上游通常在流结束时才返回用量，缺失的部分按估算计。
+func stoppedUsage(usage llm.Usage, messages []llm.Message, partial string) llm.Usage {
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = llm.EstimateMessagesTokens(messages)
+	}
+	if usage.CompletionTokens == 0 {
+		usage.CompletionTokens = llm.EstimateTokens(partial)
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"backend/internal/llm"
+	"backend/internal/store"
+)
+
+// blockingProvider 在 release 关闭前阻塞，之后交给 Fake 回复；阻塞期间 ctx 被取消时不输出任何内容。
+// 用于在生成进行中发起其他请求。
+type blockingProvider struct {
+	*llm.Fake
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingProvider(reply string) *blockingProvider {
+	return &blockingProvider{
+		Fake:    llm.NewFake("fake", reply),
+		started: make(chan struct{}, 1),
+		release: make(chan struct{}),
+	}
+}
+
+func (p *blockingProvider) ChatCompletion(ctx context.Context, messages []llm.Message) (string, llm.Usage, error) {
+	return p.ChatCompletionStream(ctx, messages, nil)
+}
+
+func (p *blockingProvider) ChatCompletionStream(ctx context.Context, messages []llm.Message, onDelta func(string)) (string, llm.Usage, error) {
+	p.started <- struct{}{}
+	select {
+	case <-p.release:
+		return p.Fake.ChatCompletionStream(ctx, messages, onDelta)
+	case <-ctx.Done():
+		return "", llm.Usage{}, ctx.Err()
+	}
+}
+
+// useProvider 将 Service 的默认模型替换为 p。
+func useProvider(s *Service, p llm.Provider) {
+	var models llm.Registry
+	models.Set(p)
+	s.models = &models
+}
+
+func TestGenerationInProgress(t *testing.T) {
+	s, st, _ := newTestService(t)
+	userID, convID := newTestConversation(t, s, st, 10000)
+	other, err := st.CreateUserWithQuota(context.Background(), "mallory", "hash", "mallory", "user", 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := newBlockingProvider("")
+	useProvider(s, p)
+	ctx := context.Background()
+
+	type outcome struct {
+		result ChatTurnResult
+		err    error
+	}
+	first := make(chan outcome, 1)
+	go func() {
+		r, err := s.SendMessage(ctx, userID, convID, "TEXT", "q1", nil)
+		first <- outcome{r, err}
+	}()
+	<-p.started
+
+	if _, err := s.SendMessage(ctx, userID, convID, "TEXT", "q2", nil); !errors.Is(err, ErrGenerationInProgress) {
+		t.Errorf("concurrent send err = %v", err)
+	}
+	if _, err := s.Regenerate(ctx, userID, convID, nil); !errors.Is(err, ErrGenerationInProgress) {
+		t.Errorf("concurrent regenerate err = %v", err)
+	}
+	if _, err := s.SendMessage(ctx, other.UserID, convID, "TEXT", "x", nil); !errors.Is(err, ErrConversationNotFound) {
+		t.Errorf("other user's send err = %v, want ErrConversationNotFound", err)
+	}
+	if err := s.StopGeneration(ctx, other.UserID, convID); !errors.Is(err, ErrConversationNotFound) {
+		t.Errorf("other user's stop err = %v", err)
+	}
+
+	close(p.release)
+	done := <-first
+	if done.err != nil {
+		t.Fatal(done.err)
+	}
+	if err := s.StopGeneration(ctx, userID, convID); !errors.Is(err, ErrNoGeneration) {
+		t.Errorf("stop after completion err = %v", err)
+	}
+	next, err := s.SendMessage(ctx, userID, convID, "TEXT", "q2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.UserMessage.ParentID != done.result.ModelMessage.MessageID {
+		t.Errorf("next turn parent = %d, want %d", next.UserMessage.ParentID, done.result.ModelMessage.MessageID)
+	}
+}
+
+// 并发请求中成功的各轮须依次接在上一轮回复之后，不能基于同一分支各自生成。
+func TestConcurrentSendsStayLinear(t *testing.T) {
+	s, st, _ := newTestService(t)
+	userID, convID := newTestConversation(t, s, st, 1000000)
+	ctx := context.Background()
+
+	const workers = 8
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				var err error
+				if i%3 == 2 {
+					_, err = s.Regenerate(ctx, userID, convID, nil)
+				} else {
+					_, err = s.SendMessage(ctx, userID, convID, "TEXT", "q", nil)
+				}
+				if err != nil && !errors.Is(err, ErrGenerationInProgress) && !errors.Is(err, ErrNothingToRegenerate) {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	rows, _, err := st.ListAllMessages(ctx, userID, convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 用户消息只能接在会话开头或回复之后，且每条回复至多被一条用户消息接续。
+	continued := make(map[int]int)
+	senders := make(map[int]int, len(rows))
+	for _, m := range rows {
+		senders[m.MessageID] = m.SenderType
+	}
+	for _, m := range rows {
+		if m.SenderType != store.SenderUser {
+			continue
+		}
+		if m.ParentID != 0 && senders[m.ParentID] != store.SenderAssistant {
+			t.Errorf("user message %d follows message %d", m.MessageID, m.ParentID)
+		}
+		if prev, ok := continued[m.ParentID]; ok {
+			t.Errorf("user messages %d and %d both follow %d", prev, m.MessageID, m.ParentID)
+		}
+		continued[m.ParentID] = m.MessageID
+	}
+	_, used, reserved, _ := st.GetUserQuotaBalance(ctx, userID)
+	if reserved != 0 || used == 0 {
+		t.Errorf("used = %d, reserved = %d", used, reserved)
+	}
+}
+
+func TestStopBeforeOutputSavesNothing(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		s, st, _ := newTestService(t)
+		userID, convID := newTestConversation(t, s, st, 10000)
+		p := newBlockingProvider("")
+		useProvider(s, p)
+		ctx := context.Background()
+
+		errc := make(chan error, 1)
+		go func() {
+			var err error
+			if stream {
+				_, err = s.SendMessageStream(ctx, userID, convID, "TEXT", "hi", nil, nil)
+			} else {
+				_, err = s.SendMessage(ctx, userID, convID, "TEXT", "hi", nil)
+			}
+			errc <- err
+		}()
+		<-p.started
+		if err := s.StopGeneration(ctx, userID, convID); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-errc; !errors.Is(err, ErrGenerationStopped) {
+			t.Fatalf("stream=%v: err = %v, want ErrGenerationStopped", stream, err)
+		}
+		if rows, _, _ := st.ListAllMessages(ctx, userID, convID); len(rows) != 0 {
+			t.Errorf("stream=%v: stopped turn saved %d messages", stream, len(rows))
+		}
+		assertBalance(t, st, userID, 0)
+	}
+}
+
+func TestStopKeepsPartialReply(t *testing.T) {
+	s, st, fake := newTestService(t)
+	userID, convID := newTestConversation(t, s, st, 10000)
+	fake.Reply = "abcdef"
+	ctx := context.Background()
+
+	n := 0
+	result, err := s.SendMessageStream(ctx, userID, convID, "TEXT", "hi", nil, func(string) {
+		if n++; n == 2 {
+			if err := s.StopGeneration(ctx, userID, convID); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := result.ModelMessage
+	if m.Status != store.MessageStatusStopped || m.Content != "ab" || result.Reply != "ab" {
+		t.Fatalf("model message = %+v", m)
+	}
+	if result.Usage.PromptTokens != 2 || result.Usage.CompletionTokens != 2 {
+		t.Errorf("usage = %+v", result.Usage)
+	}
+	assertBalance(t, st, userID, result.Usage.Total())
+
+	rows, _, err := st.ListAllMessages(ctx, userID, convID)
+	if err != nil || len(rows) != 2 || rows[1].Status != store.MessageStatusStopped || rows[1].Content != "ab" {
+		t.Fatalf("saved = %+v, err %v", rows, err)
+	}
+	// 停止的回复与完成的一样可以重新生成。
+	fake.Reply = ""
+	again, err := s.Regenerate(ctx, userID, convID, nil)
+	if err != nil || again.ModelMessage.Status != store.MessageStatusCompleted {
+		t.Fatalf("regenerate after stop = %+v, err %v", again.ModelMessage, err)
+	}
+}
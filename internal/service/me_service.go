@@ -2,16 +2,27 @@ package service
 
 import (
 	"context"
+	"errors"
 
 	"backend/internal/store"
 )
 
+// ErrInvalidConversationStatus 会话状态不是 ACTIVE、ARCHIVED 或 DELETED。
+var ErrInvalidConversationStatus = errors.New("invalid conversation status")
+
 // GetMeInfo 获取用户信息。
-func GetMeInfo(ctx context.Context, username string) (store.User, error) {
-	return store.GetUserByUsername(ctx, username)
+func (s *Service) GetMeInfo(ctx context.Context, userID int) (store.User, error) {
+	return s.store.GetUserByID(ctx, userID)
 }
 
-// ListMyConversations 获取用户会话列表。
-func ListMyConversations(ctx context.Context, userID int) ([]store.ConversationInfo, error) {
-	return store.ListConversationsByUser(ctx, userID)
+// ListMyConversations 获取用户指定状态的会话列表，status 为空时返回进行中（ACTIVE）的会话。
+func (s *Service) ListMyConversations(ctx context.Context, userID int, status string) ([]store.ConversationInfo, error) {
+	switch status {
+	case "":
+		status = store.ConversationStatusActive
+	case store.ConversationStatusActive, store.ConversationStatusArchived, store.ConversationStatusDeleted:
+	default:
+		return nil, ErrInvalidConversationStatus
+	}
+	return s.store.ListConversationsByUser(ctx, userID, status)
 }
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"backend/internal/llm"
+)
+
+var (
+	// ErrModelNotFound 模型不在目录中。
+	ErrModelNotFound = errors.New("model not found")
+	// ErrModelNotAllowed 当前角色无权使用该模型。
+	ErrModelNotAllowed = errors.New("model not allowed")
+)
+
+// ListAvailableModels 返回当前用户可使用的模型。
+func (s *Service) ListAvailableModels(ctx context.Context, userID int) ([]llm.ModelInfo, error) {
+	user, err := s.store.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	models := s.models.Models()
+	out := make([]llm.ModelInfo, 0, len(models))
+	for _, m := range models {
+		if m.AllowsRole(user.Role) {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+// ResolveModel 校验用户可使用指定模型，name 为空时使用默认模型。
+func (s *Service) ResolveModel(ctx context.Context, userID int, name string) (llm.ModelInfo, error) {
+	_, info, err := s.resolveModel(ctx, userID, name)
+	return info, err
+}
+
+// resolveModel 查找模型并按用户当前角色校验，返回其 provider。发送时同样经此校验：
+// 会话所用模型已从目录移除时返回 ErrModelNotFound，不回退到默认模型；角色变更后无权使用时返回 ErrModelNotAllowed。
+func (s *Service) resolveModel(ctx context.Context, userID int, name string) (llm.Provider, llm.ModelInfo, error) {
+	p, info, ok := s.models.Lookup(name)
+	if !ok {
+		if s.models.Get() == nil {
+			return nil, llm.ModelInfo{}, ErrLLMNotReady
+		}
+		return nil, llm.ModelInfo{}, ErrModelNotFound
+	}
+	user, err := s.store.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, llm.ModelInfo{}, err
+	}
+	if !info.AllowsRole(user.Role) {
+		return nil, llm.ModelInfo{}, ErrModelNotAllowed
+	}
+	return p, info, nil
+}
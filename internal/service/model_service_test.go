@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"backend/internal/config"
+	"backend/internal/llm"
+)
+
+// useCatalog 将 Service 的模型目录替换为按 models 创建的目录，首项为默认模型。
+func useCatalog(t *testing.T, s *Service, models ...config.LLMModelConfig) {
+	t.Helper()
+	registry, err := llm.NewRegistry(config.LLMConfig{Models: models})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.models = registry
+}
+
+var (
+	basicModel = config.LLMModelConfig{Name: "basic", Provider: "fake", Model: "basic"}
+	proModel   = config.LLMModelConfig{Name: "pro", Provider: "fake", Model: "pro", AllowedRoles: []string{"admin"}}
+)
+
+func modelNames(models []llm.ModelInfo) []string {
+	out := make([]string, 0, len(models))
+	for _, m := range models {
+		out = append(out, m.Name)
+	}
+	return out
+}
+
+func TestListAvailableModels(t *testing.T) {
+	s, st, _ := newTestService(t)
+	useCatalog(t, s, basicModel, proModel)
+	ctx := context.Background()
+	admin, err := st.CreateUserWithQuota(ctx, "root", "hash", "root", "admin", 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := st.CreateUserWithQuota(ctx, "alice", "hash", "alice", "user", 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := s.ListAvailableModels(ctx, admin.UserID); err != nil || len(got) != 2 {
+		t.Errorf("admin models = %v, err %v", modelNames(got), err)
+	}
+	if got, err := s.ListAvailableModels(ctx, user.UserID); err != nil || len(got) != 1 || got[0].Name != "basic" {
+		t.Errorf("user models = %v, err %v", modelNames(got), err)
+	}
+
+	tests := []struct {
+		name    string
+		userID  int
+		model   string
+		want    string
+		wantErr error
+	}{
+		{"default", user.UserID, "", "basic", nil},
+		{"allowed", admin.UserID, "pro", "pro", nil},
+		{"role not allowed", user.UserID, "pro", "", ErrModelNotAllowed},
+		{"unknown", admin.UserID, "missing", "", ErrModelNotFound},
+	}
+	for _, tt := range tests {
+		info, err := s.ResolveModel(ctx, tt.userID, tt.model)
+		if !errors.Is(err, tt.wantErr) || info.Name != tt.want {
+			t.Errorf("%s: ResolveModel = %q, %v", tt.name, info.Name, err)
+		}
+	}
+}
+
+func TestSendRechecksConversationModel(t *testing.T) {
+	s, st, _ := newTestService(t)
+	useCatalog(t, s, basicModel, proModel)
+	ctx := context.Background()
+	admin, err := st.CreateUserWithQuota(ctx, "root", "hash", "root", "admin", 10000, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conv, err := s.NewConversation(ctx, admin.UserID, "chat", sql.NullInt64{}, "pro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SendMessage(ctx, admin.UserID, conv.ConversationID, "TEXT", "hi", nil); err != nil {
+		t.Fatal(err)
+	}
+	_, used, _, _ := st.GetUserQuotaBalance(ctx, admin.UserID)
+
+	// 会话创建后失去模型使用权（角色变更或 allowed_roles 收紧）时发送被拒绝。
+	restricted := proModel
+	restricted.AllowedRoles = []string{"auditor"}
+	useCatalog(t, s, basicModel, restricted)
+	if _, err := s.SendMessage(ctx, admin.UserID, conv.ConversationID, "TEXT", "again", nil); !errors.Is(err, ErrModelNotAllowed) {
+		t.Errorf("restricted model err = %v", err)
+	}
+
+	// 模型从目录移除后不回退到默认模型。
+	useCatalog(t, s, basicModel)
+	if _, err := s.SendMessage(ctx, admin.UserID, conv.ConversationID, "TEXT", "again", nil); !errors.Is(err, ErrModelNotFound) {
+		t.Errorf("removed model err = %v", err)
+	}
+	if got := branchContents(t, s, admin.UserID, conv.ConversationID); len(got) != 2 {
+		t.Errorf("rejected sends saved messages: %q", got)
+	}
+	assertBalance(t, st, admin.UserID, used)
+
+	// 未记录模型的旧会话使用默认模型。
+	legacy, err := st.CreateConversation(ctx, admin.UserID, "legacy", "", sql.NullInt64{}, sql.NullString{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := s.SendMessage(ctx, admin.UserID, legacy.ConversationID, "TEXT", "hi", nil)
+	if err != nil || result.Reply != "echo: hi" {
+		t.Fatalf("legacy conversation reply = %q, err %v", result.Reply, err)
+	}
+}
@@ -16,23 +16,22 @@ import (
 	"github.com/aliyun/alibabacloud-oss-go-sdk-v2/oss/credentials"
 )
 
-var (
-	ossClient *oss.Client
-	ossConfig config.OSSConfig
+// ErrOSSNotReady OSS client not initialized.
+var ErrOSSNotReady = errors.New("oss client not initialized")
 
-	// ErrOSSNotReady OSS client not initialized.
-	ErrOSSNotReady = errors.New("oss client not initialized")
-)
+// OSSStorage wraps an OSS client and its bucket settings. A nil *OSSStorage means OSS is disabled.
+type OSSStorage struct {
+	client *oss.Client
+	cfg    config.OSSConfig
+}
 
-// InitOSS initializes the OSS client if config is enabled.
-func InitOSS(cfg config.OSSConfig) error {
-	ossConfig = cfg
+// NewOSS creates the OSS storage, returning nil when config is disabled.
+func NewOSS(cfg config.OSSConfig) (*OSSStorage, error) {
 	if !cfg.Enabled() {
-		ossClient = nil
-		return nil
+		return nil, nil
 	}
 	if cfg.Region == "" || cfg.Endpoint == "" {
-		return fmt.Errorf("oss config missing region or endpoint")
+		return nil, fmt.Errorf("oss config missing region or endpoint")
 	}
 
 	provider := credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.AccessKeySecret, cfg.SecurityToken)
@@ -41,37 +40,48 @@ func InitOSS(cfg config.OSSConfig) error {
 		WithRegion(cfg.Region).
 		WithEndpoint(cfg.Endpoint)
 
-	ossClient = oss.NewClient(clientCfg)
-	return nil
+	return &OSSStorage{client: oss.NewClient(clientCfg), cfg: cfg}, nil
 }
 
-// PutObjectToOSS uploads content to OSS with the given object key.
-func PutObjectToOSS(ctx context.Context, objectKey string, reader io.Reader, mimeType string) error {
-	if ossClient == nil {
+// PutObject uploads content to OSS with the given object key.
+func (o *OSSStorage) PutObject(ctx context.Context, objectKey string, reader io.Reader, mimeType string) error {
+	if o == nil {
 		return ErrOSSNotReady
 	}
 	req := &oss.PutObjectRequest{
-		Bucket: oss.Ptr(ossConfig.Bucket),
+		Bucket: oss.Ptr(o.cfg.Bucket),
 		Key:    oss.Ptr(objectKey),
 		Body:   reader,
 	}
 	if mimeType != "" {
 		req.ContentType = oss.Ptr(mimeType)
 	}
-	_, err := ossClient.PutObject(ctx, req)
+	_, err := o.client.PutObject(ctx, req)
+	return err
+}
+
+// DeleteObject removes the object with the given key from OSS.
+func (o *OSSStorage) DeleteObject(ctx context.Context, objectKey string) error {
+	if o == nil {
+		return ErrOSSNotReady
+	}
+	_, err := o.client.DeleteObject(ctx, &oss.DeleteObjectRequest{
+		Bucket: oss.Ptr(o.cfg.Bucket),
+		Key:    oss.Ptr(objectKey),
+	})
 	return err
 }
 
 // PresignGetURL signs a temporary GET URL for the object key.
-func PresignGetURL(ctx context.Context, objectKey string, expires time.Duration) (string, error) {
-	if ossClient == nil {
+func (o *OSSStorage) PresignGetURL(ctx context.Context, objectKey string, expires time.Duration) (string, error) {
+	if o == nil {
 		return "", ErrOSSNotReady
 	}
 	if expires <= 0 {
-		expires = defaultOSSExpire()
+		expires = o.defaultExpire()
 	}
-	result, err := ossClient.Presign(ctx, &oss.GetObjectRequest{
-		Bucket: oss.Ptr(ossConfig.Bucket),
+	result, err := o.client.Presign(ctx, &oss.GetObjectRequest{
+		Bucket: oss.Ptr(o.cfg.Bucket),
 		Key:    oss.Ptr(objectKey),
 	}, oss.PresignExpires(expires))
 	if err != nil {
@@ -81,15 +91,15 @@ func PresignGetURL(ctx context.Context, objectKey string, expires time.Duration)
 }
 
 // PresignPutURL signs a temporary PUT URL for the object key.
-func PresignPutURL(ctx context.Context, objectKey string, expires time.Duration) (string, map[string]string, error) {
-	if ossClient == nil {
+func (o *OSSStorage) PresignPutURL(ctx context.Context, objectKey string, expires time.Duration) (string, map[string]string, error) {
+	if o == nil {
 		return "", nil, ErrOSSNotReady
 	}
 	if expires <= 0 {
-		expires = defaultOSSExpire()
+		expires = o.defaultExpire()
 	}
-	result, err := ossClient.Presign(ctx, &oss.PutObjectRequest{
-		Bucket: oss.Ptr(ossConfig.Bucket),
+	result, err := o.client.Presign(ctx, &oss.PutObjectRequest{
+		Bucket: oss.Ptr(o.cfg.Bucket),
 		Key:    oss.Ptr(objectKey),
 	}, oss.PresignExpires(expires))
 	if err != nil {
@@ -98,21 +108,24 @@ func PresignPutURL(ctx context.Context, objectKey string, expires time.Duration)
 	return result.URL, result.SignedHeaders, nil
 }
 
-func defaultOSSExpire() time.Duration {
-	if ossConfig.TempURLExpireSeconds > 0 {
-		return time.Duration(ossConfig.TempURLExpireSeconds) * time.Second
+func (o *OSSStorage) defaultExpire() time.Duration {
+	if o.cfg.TempURLExpireSeconds > 0 {
+		return time.Duration(o.cfg.TempURLExpireSeconds) * time.Second
 	}
 	return 15 * time.Minute
 }
 
-// BuildOSSObjectKey builds an OSS object key with optional sub-prefix.
-func BuildOSSObjectKey(subPrefix, filename string) string {
+// BuildObjectKey builds an OSS object key with optional sub-prefix.
+func (o *OSSStorage) BuildObjectKey(subPrefix, filename string) string {
 	name := "upload.bin"
 	if strings.TrimSpace(filename) != "" {
 		name = filepath.Base(filename)
 	}
 	objectName := fmt.Sprintf("%d_%s", time.Now().UnixNano(), name)
-	prefix := strings.Trim(ossConfig.Prefix, "/")
+	prefix := ""
+	if o != nil {
+		prefix = strings.Trim(o.cfg.Prefix, "/")
+	}
 	if strings.TrimSpace(subPrefix) != "" {
 		sub := strings.Trim(subPrefix, "/")
 		if prefix == "" {
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"time"
+)
+
+// 管理员重置密码的方式。
+const (
+	// ResetModeToken 签发一次性重置令牌，用户凭令牌自行设置新密码，原密码在此之前仍有效。
+	ResetModeToken = "token"
+	// ResetModeTempPassword 立即将密码改为随机临时密码，用户登录后须修改密码。
+	ResetModeTempPassword = "temp_password"
+)
+
+var (
+	// ErrInvalidResetMode 不支持的重置方式。
+	ErrInvalidResetMode = errors.New("invalid reset mode")
+	// ErrInvalidResetToken 重置令牌不存在、已使用或已过期。
+	ErrInvalidResetToken = errors.New("invalid or expired reset token")
+)
+
+// PasswordReset 管理员重置密码的结果，明文只在此返回一次。
+type PasswordReset struct {
+	Mode         string
+	TempPassword string
+	Token        string
+	ExpiresAt    time.Time
+}
+
+// AdminResetPassword 按 mode（缺省为 ResetModeToken）重置用户密码。
+func (s *Service) AdminResetPassword(ctx context.Context, userID int, mode string) (PasswordReset, error) {
+	if mode == "" {
+		mode = ResetModeToken
+	}
+	if mode != ResetModeToken && mode != ResetModeTempPassword {
+		return PasswordReset{}, ErrInvalidResetMode
+	}
+	user, err := s.store.GetUserByID(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return PasswordReset{}, ErrUserNotFound
+		}
+		return PasswordReset{}, err
+	}
+
+	if mode == ResetModeTempPassword {
+		temp, err := s.tempPassword(user.Username)
+		if err != nil {
+			return PasswordReset{}, err
+		}
+		hashed, err := hashPassword(temp)
+		if err != nil {
+			return PasswordReset{}, err
+		}
+		err = s.store.WithTx(ctx, func(ctx context.Context) error {
+			return s.applyPassword(ctx, user.UserID, user.Username, hashed, true)
+		})
+		if err != nil {
+			return PasswordReset{}, err
+		}
+		return PasswordReset{Mode: mode, TempPassword: temp}, nil
+	}
+
+	token, err := randomToken(32, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return PasswordReset{}, err
+	}
+	expiresAt := time.Now().Add(s.auth.PasswordResetTTL())
+	err = s.store.WithTx(ctx, func(ctx context.Context) error {
+		// 同一用户只保留最新签发的令牌。
+		if err := s.store.InvalidateUserPasswordResetTokens(ctx, user.UserID); err != nil {
+			return err
+		}
+		_, err := s.store.CreatePasswordResetToken(ctx, user.UserID, hashOpaqueToken(token), expiresAt)
+		return err
+	})
+	if err != nil {
+		return PasswordReset{}, err
+	}
+	return PasswordReset{Mode: mode, Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// ResetPasswordWithToken 凭管理员签发的重置令牌设置新密码，令牌随即失效。
+func (s *Service) ResetPasswordWithToken(ctx context.Context, token, newPassword string) error {
+	if token == "" {
+		return ErrInvalidResetToken
+	}
+	reset, err := s.store.GetPasswordResetTokenByHash(ctx, hashOpaqueToken(token))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+	if reset.Used || !time.Now().Before(reset.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+	user, err := s.store.GetUserByID(ctx, reset.UserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+	hashed, err := s.hashNewPassword(newPassword, user.Username)
+	if err != nil {
+		return err
+	}
+	return s.store.WithTx(ctx, func(ctx context.Context) error {
+		ok, err := s.store.MarkPasswordResetTokenUsed(ctx, reset.TokenID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidResetToken
+		}
+		return s.applyPassword(ctx, user.UserID, user.Username, hashed, false)
+	})
+}
+
+const (
+	tempPasswordLength   = 20
+	tempPasswordAttempts = 5
+)
+
+var tempPasswordClasses = []string{
+	"ABCDEFGHJKLMNPQRSTUVWXYZ",
+	"abcdefghijkmnpqrstuvwxyz",
+	"23456789",
+	"!@#$%^&*-_=+",
+}
+
+// tempPassword 生成包含全部字符类别的随机临时密码，并确保满足密码策略。
+func (s *Service) tempPassword(username string) (string, error) {
+	var lastErr error
+	for i := 0; i < tempPasswordAttempts; i++ {
+		password, err := randomPassword(tempPasswordLength)
+		if err != nil {
+			return "", err
+		}
+		if s.passwords == nil {
+			return password, nil
+		}
+		if lastErr = s.passwords.Validate(password, username); lastErr == nil {
+			return password, nil
+		}
+	}
+	return "", lastErr
+}
+
+func randomPassword(length int) (string, error) {
+	var all string
+	for _, class := range tempPasswordClasses {
+		all += class
+	}
+	buf := make([]byte, length)
+	for i := range buf {
+		// 前几位依次取自各类别，保证每类至少出现一次，最后整体打乱。
+		set := all
+		if i < len(tempPasswordClasses) {
+			set = tempPasswordClasses[i]
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(set))))
+		if err != nil {
+			return "", err
+		}
+		buf[i] = set[n.Int64()]
+	}
+	for i := len(buf) - 1; i > 0; i-- {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		j := n.Int64()
+		buf[i], buf[j] = buf[j], buf[i]
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/passwordpolicy"
+)
+
+func TestResetPasswordWithToken(t *testing.T) {
+	s, st, _ := newTestService(t)
+	policy, err := passwordpolicy.New(config.PasswordPolicyConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.passwords = policy
+	userID := newTestLogin(t, s)
+	ctx := context.Background()
+
+	session := mustLogin(t, s, "old-password")
+	before, err := st.GetUserAuthState(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stale, err := s.AdminResetPassword(ctx, userID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reset, err := s.AdminResetPassword(ctx, userID, ResetModeToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reset.Mode != ResetModeToken || reset.Token == "" || reset.TempPassword != "" || !reset.ExpiresAt.After(time.Now()) {
+		t.Fatalf("reset = %+v", reset)
+	}
+	// 签发令牌不改变原密码与会话。
+	mustLogin(t, s, "old-password")
+
+	if err := s.ResetPasswordWithToken(ctx, stale.Token, "Brand-new-1"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("superseded token err = %v", err)
+	}
+	// 新密码不符合策略时令牌不被消耗。
+	if err := s.ResetPasswordWithToken(ctx, reset.Token, "short"); !errors.Is(err, ErrWeakPassword) || !errors.Is(err, passwordpolicy.ErrTooShort) {
+		t.Fatalf("weak password err = %v", err)
+	}
+	if err := s.ResetPasswordWithToken(ctx, reset.Token, "Brand-new-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ResetPasswordWithToken(ctx, reset.Token, "Another-new-2"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("reused token err = %v", err)
+	}
+
+	after, err := st.GetUserAuthState(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.TokenVersion != before.TokenVersion+1 || after.MustChangePassword {
+		t.Errorf("auth state %+v -> %+v", before, after)
+	}
+	if _, err := s.RefreshSession(ctx, session.RefreshToken); !errors.Is(err, ErrRefreshTokenRevoked) {
+		t.Errorf("session after reset err = %v", err)
+	}
+	if _, err := s.Login(ctx, "alice", "old-password", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("old password err = %v", err)
+	}
+	mustLogin(t, s, "Brand-new-1")
+}
+
+func TestResetPasswordWithTokenRejects(t *testing.T) {
+	s, st, _ := newTestService(t)
+	userID := newTestLogin(t, s)
+	ctx := context.Background()
+
+	if err := s.ResetPasswordWithToken(ctx, "", "Brand-new-1"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("empty token err = %v", err)
+	}
+	if err := s.ResetPasswordWithToken(ctx, "unknown", "Brand-new-1"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("unknown token err = %v", err)
+	}
+	if _, err := st.CreatePasswordResetToken(ctx, userID, hashOpaqueToken("expired"), time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ResetPasswordWithToken(ctx, "expired", "Brand-new-1"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("expired token err = %v", err)
+	}
+	mustLogin(t, s, "old-password")
+
+	if _, err := s.AdminResetPassword(ctx, userID, "email"); !errors.Is(err, ErrInvalidResetMode) {
+		t.Errorf("unknown mode err = %v", err)
+	}
+	if _, err := s.AdminResetPassword(ctx, 9999, ResetModeToken); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("unknown user err = %v", err)
+	}
+}
+
+func TestAdminResetTempPassword(t *testing.T) {
+	s, st, _ := newTestService(t)
+	userID := newTestLogin(t, s)
+	ctx := context.Background()
+
+	// 用户改过密码后清除了改密标记。
+	if err := s.ResetPassword(ctx, "alice", "old-password", "chosen-password"); err != nil {
+		t.Fatal(err)
+	}
+	session := mustLogin(t, s, "chosen-password")
+	pending, err := s.AdminResetPassword(ctx, userID, ResetModeToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := st.GetUserAuthState(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reset, err := s.AdminResetPassword(ctx, userID, ResetModeTempPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reset.Mode != ResetModeTempPassword || len(reset.TempPassword) != tempPasswordLength || reset.Token != "" {
+		t.Fatalf("reset = %+v", reset)
+	}
+	after, err := st.GetUserAuthState(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.TokenVersion != before.TokenVersion+1 || !after.MustChangePassword {
+		t.Errorf("auth state %+v -> %+v", before, after)
+	}
+	if _, err := s.RefreshSession(ctx, session.RefreshToken); !errors.Is(err, ErrRefreshTokenRevoked) {
+		t.Errorf("session after reset err = %v", err)
+	}
+	// 临时密码使此前签发的重置令牌失效。
+	if err := s.ResetPasswordWithToken(ctx, pending.Token, "Brand-new-1"); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("pending token err = %v", err)
+	}
+	if pair := mustLogin(t, s, reset.TempPassword); !pair.MustChangePassword {
+		t.Error("login with temp password should require a password change")
+	}
+}
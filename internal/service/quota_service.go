@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"backend/internal/store"
+)
+
+// QuotaReservation 一次上游调用前预占的额度，调用结束后须 Settle 或 Release 且只生效一次。
+type QuotaReservation struct {
+	mu     sync.Mutex
+	users  store.UserRepository
+	userID int
+	amount int
+	done   atomic.Bool
+}
+
+// ReserveQuota 预占额度：estimate 超出剩余额度时按剩余额度预占，无剩余时返回 ErrQuotaExceeded。
+func (s *Service) ReserveQuota(ctx context.Context, userID, estimate int) (*QuotaReservation, error) {
+	total, used, reserved, err := s.store.GetUserQuotaBalance(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	remaining := total - used - reserved
+	if remaining <= 0 {
+		return nil, ErrQuotaExceeded
+	}
+	if estimate <= 0 {
+		estimate = 1
+	}
+	if estimate > remaining {
+		estimate = remaining
+	}
+	ok, err := s.store.ReserveQuota(ctx, userID, estimate)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrQuotaExceeded
+	}
+	return &QuotaReservation{users: s.store, userID: userID, amount: estimate}, nil
+}
+
+// Settle 按实际用量结算并释放预占，实际用量超出预占时已用额度至多计到总额度；在 Store.WithTx 中调用时随事务提交才生效，回滚后仍可 Release。
+func (r *QuotaReservation) Settle(ctx context.Context, actual int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.done.Load() {
+		return nil
+	}
+	if actual < 0 {
+		actual = 0
+	}
+	// 请求可能已被取消，结算不应随之失败。
+	if err := r.users.SettleQuota(context.WithoutCancel(ctx), r.userID, r.amount, actual); err != nil {
+		return err
+	}
+	store.AfterCommit(ctx, func() { r.done.Store(true) })
+	return nil
+}
+
+// Release 释放未使用的预占，用于上游调用失败；已结算时无操作。
+func (r *QuotaReservation) Release(ctx context.Context) {
+	_ = r.Settle(ctx, 0)
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"backend/internal/store"
+)
+
+// purgeBatchSize 每批读取的待清除会话数。
+const purgeBatchSize = 100
+
+// RunRetention 按配置的间隔定期彻底清除超过保留期的已删除会话，直到 ctx 结束；未配置保留天数时立即返回。
+func (s *Service) RunRetention(ctx context.Context) {
+	if !s.retention.Enabled() {
+		return
+	}
+	ticker := time.NewTicker(s.retention.Interval())
+	defer ticker.Stop()
+	for {
+		purged, err := s.PurgeDeletedConversations(ctx, time.Now().Add(-s.retention.RetentionPeriod()))
+		if err != nil && ctx.Err() == nil {
+			log.Printf("purge deleted conversations failed: %v", err)
+		} else if purged > 0 {
+			log.Printf("purged %d deleted conversations", purged)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeDeletedConversations 彻底删除删除时间早于 before 的会话及其消息、附件记录与摘要，
+// 随后删除不再被任何附件引用的本地或 OSS 文件；返回清除的会话数。文件删除失败只记录日志。
+func (s *Service) PurgeDeletedConversations(ctx context.Context, before time.Time) (int, error) {
+	purged := 0
+	for {
+		ids, err := s.store.ListPurgeableConversations(ctx, before, purgeBatchSize)
+		if err != nil {
+			return purged, err
+		}
+		for _, id := range ids {
+			var attachments []store.AttachmentInfo
+			err := s.store.WithTx(ctx, func(ctx context.Context) error {
+				var err error
+				attachments, err = s.store.PurgeConversation(ctx, id)
+				return err
+			})
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				return purged, err
+			}
+			purged++
+			for _, a := range attachments {
+				if err := s.deleteAttachmentBlob(ctx, a); err != nil {
+					log.Printf("delete attachment %d blob %q failed: %v", a.AttachmentID, a.URLOrPath, err)
+				}
+			}
+		}
+		if len(ids) < purgeBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+// deleteAttachmentBlob 删除附件对应的文件；仍被其他附件记录引用（分叉或编辑时复制）时保留。
+func (s *Service) deleteAttachmentBlob(ctx context.Context, attachment store.AttachmentInfo) error {
+	refs, err := s.store.CountAttachmentsByLocation(ctx, attachment.StorageType, attachment.URLOrPath)
+	if err != nil || refs > 0 {
+		return err
+	}
+	if strings.EqualFold(attachment.StorageType, store.StorageTypeOSS) {
+		return s.oss.DeleteObject(ctx, attachment.URLOrPath)
+	}
+	name, ok := strings.CutPrefix(attachment.URLOrPath, "/uploads/")
+	if !ok {
+		return nil
+	}
+	err = os.Remove(filepath.Join("uploads", filepath.Base(name)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
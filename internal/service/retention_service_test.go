@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"backend/internal/store"
+	"backend/internal/store/memstore"
+)
+
+// chdirTemp 切换到临时目录，使本地附件写入其下的 uploads 目录，测试结束后恢复。
+func chdirTemp(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	if err := os.Mkdir("uploads", 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestUpload 在 uploads 目录创建文件并登记为用户的待发送附件。
+func newTestUpload(t *testing.T, st *memstore.Store, userID int, name string) int {
+	t.Helper()
+	ctx := context.Background()
+	if err := os.WriteFile(filepath.Join("uploads", name), []byte(name), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	uploadConv, err := st.GetOrCreateUploadConversation(ctx, userID, "fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploadMsg, err := st.CreateUploadMessage(ctx, uploadConv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := st.CreateAttachment(ctx, uploadMsg, "FILE", "text/plain", store.StorageTypeLocal, "/uploads/"+name, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(filepath.Join("uploads", name))
+	return err == nil
+}
+
+func TestPurgeDeletedConversations(t *testing.T) {
+	chdirTemp(t)
+	s, st, _ := newTestService(t)
+	userID, shared := newTestConversation(t, s, st, 10000)
+	ctx := context.Background()
+
+	newConv := func(file string) int {
+		conv, err := s.NewConversation(ctx, userID, "c", sql.NullInt64{}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.SendMessage(ctx, userID, conv.ConversationID, "TEXT", "hi", []int{newTestUpload(t, st, userID, file)}); err != nil {
+			t.Fatal(err)
+		}
+		return conv.ConversationID
+	}
+	remove := func(convID int) {
+		if ok, err := s.DeleteConversation(ctx, userID, convID); err != nil || !ok {
+			t.Fatalf("delete %d = %v %v", convID, ok, err)
+		}
+	}
+
+	// shared.txt 经分叉复制后被两个会话引用，own.txt 只被一个会话引用。
+	sent, err := s.SendMessage(ctx, userID, shared, "TEXT", "hi", []int{newTestUpload(t, st, userID, "shared.txt")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fork, err := s.ForkConversation(ctx, userID, shared, sent.ModelMessage.MessageID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	own := newConv("own.txt")
+	recent := newConv("recent.txt")
+
+	remove(shared)
+	remove(own)
+	time.Sleep(time.Millisecond)
+	cutoff := time.Now()
+	time.Sleep(time.Millisecond)
+	remove(recent)
+
+	purged, err := s.PurgeDeletedConversations(ctx, cutoff)
+	if err != nil || purged != 2 {
+		t.Fatalf("purged = %d, err %v", purged, err)
+	}
+	for _, id := range []int{shared, own} {
+		if ok, err := st.RestoreConversation(ctx, id, userID); ok || err != nil {
+			t.Errorf("conversation %d restorable after purge: %v %v", id, ok, err)
+		}
+	}
+	deleted, err := s.ListMyConversations(ctx, userID, store.ConversationStatusDeleted)
+	if err != nil || len(deleted) != 1 || deleted[0].ConversationID != recent {
+		t.Errorf("deleted after purge = %+v, err %v", deleted, err)
+	}
+	if !fileExists("shared.txt") {
+		t.Error("shared.txt removed while the fork still references it")
+	}
+	if fileExists("own.txt") {
+		t.Error("own.txt kept after its only conversation was purged")
+	}
+	if !fileExists("recent.txt") {
+		t.Error("recent.txt removed before its conversation expired")
+	}
+	if _, err := st.GetConversation(ctx, fork.ConversationID, userID); err != nil {
+		t.Errorf("fork after purge: %v", err)
+	}
+
+	// 分叉也被清除后，共享文件不再被引用。
+	remove(fork.ConversationID)
+	if purged, err := s.PurgeDeletedConversations(ctx, time.Now().Add(time.Second)); err != nil || purged != 2 {
+		t.Fatalf("second purge = %d, err %v", purged, err)
+	}
+	for _, name := range []string{"shared.txt", "recent.txt"} {
+		if fileExists(name) {
+			t.Errorf("%s kept after every reference was purged", name)
+		}
+	}
+	if purged, err := s.PurgeDeletedConversations(ctx, time.Now().Add(time.Second)); err != nil || purged != 0 {
+		t.Errorf("purge with nothing expired = %d, err %v", purged, err)
+	}
+	if _, err := st.GetConversation(ctx, fork.ConversationID, userID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("purged fork err = %v", err)
+	}
+}
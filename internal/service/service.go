@@ -0,0 +1,78 @@
+package service
+
+import (
+	"sync"
+
+	"backend/internal/config"
+	"backend/internal/jwtauth"
+	"backend/internal/llm"
+	"backend/internal/loginguard"
+	"backend/internal/passwordpolicy"
+	"backend/internal/store"
+)
+
+// Service 业务逻辑入口，持有存储、模型目录、对象存储与语音客户端。
+type Service struct {
+	store     store.Store
+	models    *llm.Registry
+	oss       *OSSStorage
+	speech    *Dashscope
+	tokens    *jwtauth.Manager
+	auth      config.AuthConfig
+	guard     *loginguard.Guard
+	passwords *passwordpolicy.Policy
+	summary   config.SummaryConfig
+	title     config.TitleConfig
+	retention config.RetentionConfig
+	// summarizing 记录正在生成摘要的会话，避免同一会话并发摘要。
+	summarizing sync.Map
+	// titling 记录正在自动命名的会话，避免重复生成标题。
+	titling sync.Map
+	// generations 记录进行中的生成（会话ID -> *generation），供 StopGeneration 取消。
+	generations sync.Map
+}
+
+// Deps 创建 Service 所需的依赖；OSS 与 Speech 为 nil 时相应功能返回未就绪错误。
+type Deps struct {
+	Store  store.Store
+	Models *llm.Registry
+	OSS    *OSSStorage
+	Speech *Dashscope
+	Tokens *jwtauth.Manager
+	Auth   config.AuthConfig
+	// LoginGuard 为 nil 时不限制登录失败次数。
+	LoginGuard *loginguard.Guard
+	// PasswordPolicy 为 nil 时不校验新密码强度。
+	PasswordPolicy *passwordpolicy.Policy
+	Summary        config.SummaryConfig
+	Title          config.TitleConfig
+	Retention      config.RetentionConfig
+}
+
+// New 创建 Service。生产环境使用 store.SQLStore，测试可使用 memstore 与 llm.Fake。
+func New(deps Deps) *Service {
+	summary := deps.Summary
+	if summary.TriggerMessages <= 0 {
+		summary.TriggerMessages = defaultSummaryTriggerMessages
+	}
+	if summary.KeepRecent <= 0 {
+		summary.KeepRecent = defaultSummaryKeepRecent
+	}
+	title := deps.Title
+	if title.MaxLength <= 0 {
+		title.MaxLength = defaultTitleMaxLength
+	}
+	return &Service{
+		store:     deps.Store,
+		models:    deps.Models,
+		oss:       deps.OSS,
+		speech:    deps.Speech,
+		tokens:    deps.Tokens,
+		auth:      deps.Auth,
+		guard:     deps.LoginGuard,
+		passwords: deps.PasswordPolicy,
+		summary:   summary,
+		title:     title,
+		retention: deps.Retention,
+	}
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"backend/internal/jwtauth"
+	"backend/internal/store"
+)
+
+var (
+	// ErrInvalidRefreshToken 刷新令牌不存在或已过期。
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused 已轮换的刷新令牌被再次使用，所在会话已整体吊销。
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+	// ErrRefreshTokenRevoked 刷新令牌所在会话已被登出、改密或管理员吊销。
+	ErrRefreshTokenRevoked = errors.New("refresh token revoked")
+)
+
+// TokenPair 访问令牌与刷新令牌。
+type TokenPair struct {
+	AccessToken      string
+	AccessExpiresAt  time.Time
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+	// MustChangePassword 用户须先修改密码。
+	MustChangePassword bool
+}
+
+// RefreshSession 使用刷新令牌换取新的令牌对，旧刷新令牌随即失效（轮换）。
+// 已使用的令牌再次出现时视为泄露，吊销其所在会话的全部令牌并返回 ErrRefreshTokenReused；
+// 未使用但会话已被吊销的令牌返回 ErrRefreshTokenRevoked，已过期的返回 ErrInvalidRefreshToken。
+func (s *Service) RefreshSession(ctx context.Context, refreshToken string) (TokenPair, error) {
+	if refreshToken == "" {
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+	current, err := s.store.GetRefreshTokenByHash(ctx, hashOpaqueToken(refreshToken))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return TokenPair{}, ErrInvalidRefreshToken
+		}
+		return TokenPair{}, err
+	}
+	if current.Used || current.Revoked {
+		return TokenPair{}, s.rejectSpentRefreshToken(ctx, current)
+	}
+	if !time.Now().Before(current.ExpiresAt) {
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+	user, err := s.store.GetUserByID(ctx, current.UserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return TokenPair{}, ErrInvalidRefreshToken
+		}
+		return TokenPair{}, err
+	}
+
+	var pair TokenPair
+	err = s.store.WithTx(ctx, func(ctx context.Context) error {
+		ok, err := s.store.MarkRefreshTokenUsed(ctx, current.TokenID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			// 并发请求已先一步使用或吊销该令牌。
+			return errRefreshTokenSpent
+		}
+		pair, err = s.issueTokenPair(ctx, user, current.FamilyID)
+		return err
+	})
+	if err == errRefreshTokenSpent {
+		// 事务已回滚，重新读取令牌状态后在事务外处理。
+		if current, err = s.store.GetRefreshTokenByHash(ctx, hashOpaqueToken(refreshToken)); err != nil {
+			return TokenPair{}, err
+		}
+		return TokenPair{}, s.rejectSpentRefreshToken(ctx, current)
+	}
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return pair, nil
+}
+
+// Logout 吊销刷新令牌所在的会话；令牌无效时视为已登出。
+func (s *Service) Logout(ctx context.Context, refreshToken string) error {
+	if refreshToken == "" {
+		return nil
+	}
+	current, err := s.store.GetRefreshTokenByHash(ctx, hashOpaqueToken(refreshToken))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+	return s.store.RevokeRefreshTokenFamily(ctx, current.FamilyID)
+}
+
+// RevokeUserSessions 吊销用户的全部会话，返回吊销的会话数。
+func (s *Service) RevokeUserSessions(ctx context.Context, userID int) (int, error) {
+	if _, err := s.store.GetUserByID(ctx, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrUserNotFound
+		}
+		return 0, err
+	}
+	return s.store.RevokeUserRefreshTokens(ctx, userID)
+}
+
+// errRefreshTokenSpent 事务内发现刷新令牌已被并发请求使用或吊销。
+var errRefreshTokenSpent = errors.New("refresh token spent")
+
+// rejectSpentRefreshToken 拒绝已使用或已吊销的刷新令牌：已使用的视为泄露并吊销其所在会话，
+// 仅被吊销的说明会话已正常结束，无需再次吊销。
+func (s *Service) rejectSpentRefreshToken(ctx context.Context, token store.RefreshToken) error {
+	if !token.Used {
+		return ErrRefreshTokenRevoked
+	}
+	if err := s.store.RevokeRefreshTokenFamily(ctx, token.FamilyID); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// issueTokenPair 签发访问令牌，并在 familyID 会话下保存新的刷新令牌；familyID 为空时开启新会话。
+func (s *Service) issueTokenPair(ctx context.Context, user store.User, familyID string) (TokenPair, error) {
+	access, accessExpiresAt, err := s.tokens.Issue(jwtauth.Subject{
+		UserID:       user.UserID,
+		Username:     user.Username,
+		Role:         user.Role,
+		TokenVersion: user.TokenVersion,
+	})
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if familyID == "" {
+		if familyID, err = randomToken(16, hex.EncodeToString); err != nil {
+			return TokenPair{}, err
+		}
+	}
+	refresh, err := randomToken(32, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	refreshExpiresAt := time.Now().Add(s.auth.RefreshTokenTTL())
+	if _, err := s.store.CreateRefreshToken(ctx, user.UserID, familyID, hashOpaqueToken(refresh), refreshExpiresAt); err != nil {
+		return TokenPair{}, err
+	}
+	return TokenPair{
+		AccessToken:        access,
+		AccessExpiresAt:    accessExpiresAt,
+		RefreshToken:       refresh,
+		RefreshExpiresAt:   refreshExpiresAt,
+		MustChangePassword: user.MustChangePassword,
+	}, nil
+}
+
+func randomToken(size int, encode func([]byte) string) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return encode(buf), nil
+}
+
+// hashOpaqueToken 刷新令牌与重置令牌只以 SHA-256 摘要落库。
+func hashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
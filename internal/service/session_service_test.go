@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestLogin(t *testing.T, s *Service) int {
+	t.Helper()
+	u, err := s.CreateUser(context.Background(), "alice", "old-password", "alice", "user", 1000, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.UserID
+}
+
+func mustLogin(t *testing.T, s *Service, password string) TokenPair {
+	t.Helper()
+	pair, err := s.Login(context.Background(), "alice", password, "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pair
+}
+
+func TestRefreshSessionRotates(t *testing.T) {
+	s, _, _ := newTestService(t)
+	userID := newTestLogin(t, s)
+	ctx := context.Background()
+
+	first := mustLogin(t, s, "old-password")
+	second, err := s.RefreshSession(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.RefreshToken == first.RefreshToken || second.AccessToken == "" {
+		t.Fatalf("refresh did not rotate: %+v", second)
+	}
+	claims, err := s.tokens.Parse(second.AccessToken)
+	if err != nil || claims.UserID != userID {
+		t.Fatalf("claims = %+v, err %v", claims, err)
+	}
+	third, err := s.RefreshSession(ctx, second.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 轮换后的旧令牌再次出现视为泄露，整个会话被吊销，其他会话不受影响。
+	other := mustLogin(t, s, "old-password")
+	if _, err := s.RefreshSession(ctx, first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reused token err = %v", err)
+	}
+	if _, err := s.RefreshSession(ctx, third.RefreshToken); !errors.Is(err, ErrRefreshTokenRevoked) {
+		t.Errorf("latest token of the reused session err = %v", err)
+	}
+	if _, err := s.RefreshSession(ctx, other.RefreshToken); err != nil {
+		t.Errorf("other session: %v", err)
+	}
+}
+
+func TestRefreshSessionRejects(t *testing.T) {
+	s, st, _ := newTestService(t)
+	userID := newTestLogin(t, s)
+	ctx := context.Background()
+
+	if _, err := s.RefreshSession(ctx, ""); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("empty token err = %v", err)
+	}
+	if _, err := s.RefreshSession(ctx, "unknown"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("unknown token err = %v", err)
+	}
+
+	pair := mustLogin(t, s, "old-password")
+	if err := s.Logout(ctx, pair.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RefreshSession(ctx, pair.RefreshToken); !errors.Is(err, ErrRefreshTokenRevoked) {
+		t.Errorf("logged out token err = %v", err)
+	}
+
+	pair = mustLogin(t, s, "old-password")
+	if n, err := s.RevokeUserSessions(ctx, userID); err != nil || n != 1 {
+		t.Fatalf("RevokeUserSessions = %d, %v", n, err)
+	}
+	if _, err := s.RefreshSession(ctx, pair.RefreshToken); !errors.Is(err, ErrRefreshTokenRevoked) {
+		t.Errorf("admin revoked token err = %v", err)
+	}
+	if _, err := s.RevokeUserSessions(ctx, 9999); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("unknown user err = %v", err)
+	}
+
+	if _, err := st.CreateRefreshToken(ctx, userID, "expired-family", hashOpaqueToken("expired"), time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RefreshSession(ctx, "expired"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("expired token err = %v", err)
+	}
+}
+
+func TestPasswordChangeRevokesSessions(t *testing.T) {
+	s, st, _ := newTestService(t)
+	userID := newTestLogin(t, s)
+	ctx := context.Background()
+
+	web := mustLogin(t, s, "old-password")
+	mobile := mustLogin(t, s, "old-password")
+	rotated, err := s.RefreshSession(ctx, mobile.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := st.GetUserAuthState(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.ResetPassword(ctx, "alice", "old-password", "new-password"); err != nil {
+		t.Fatal(err)
+	}
+	after, err := st.GetUserAuthState(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.TokenVersion != before.TokenVersion+1 || after.MustChangePassword {
+		t.Errorf("auth state %+v -> %+v", before, after)
+	}
+
+	// 改密吊销的令牌未被使用过，不应被当作泄露。
+	for name, token := range map[string]string{"web": web.RefreshToken, "mobile": rotated.RefreshToken} {
+		if _, err := s.RefreshSession(ctx, token); !errors.Is(err, ErrRefreshTokenRevoked) {
+			t.Errorf("%s session err = %v", name, err)
+		}
+	}
+	// 改密前已轮换掉的令牌仍按重用处理。
+	if _, err := s.RefreshSession(ctx, mobile.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Errorf("rotated-out token err = %v", err)
+	}
+
+	if _, err := s.Login(ctx, "alice", "old-password", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("old password err = %v", err)
+	}
+	fresh := mustLogin(t, s, "new-password")
+	claims, err := s.tokens.Parse(fresh.AccessToken)
+	if err != nil || claims.TokenVersion != after.TokenVersion {
+		t.Errorf("new token version = %+v, err %v", claims, err)
+	}
+	if _, err := s.RefreshSession(ctx, fresh.RefreshToken); err != nil {
+		t.Errorf("new session: %v", err)
+	}
+}
@@ -4,10 +4,11 @@ import (
 	"context"
 	"errors"
 	"io"
-
-	"backend/internal/store"
 )
 
+// sttReserveTokens 识别前预占的额度（音频时长未知，按一分钟左右的音频估算）。
+const sttReserveTokens = 1500
+
 // STTResult 语音识别结果。
 type STTResult struct {
 	AudioText   string
@@ -15,40 +16,36 @@ type STTResult struct {
 }
 
 // SpeechToText 调用 Dashscope ASR。
-func SpeechToText(ctx context.Context, userID int, filename, mimeType string, reader io.Reader) (STTResult, error) {
+func (s *Service) SpeechToText(ctx context.Context, userID int, filename, mimeType string, reader io.Reader) (STTResult, error) {
 	if reader == nil {
 		return STTResult{}, errors.New("missing audio")
 	}
 
-	totalQuota, usedQuota, err := store.GetUserQuotaUsage(ctx, userID)
-	if err != nil {
-		return STTResult{}, err
-	}
-	if usedQuota >= totalQuota {
-		return STTResult{}, ErrQuotaExceeded
+	if s.oss == nil {
+		return STTResult{}, ErrOSSNotReady
 	}
 
-	if ossClient == nil {
-		return STTResult{}, ErrOSSNotReady
+	reservation, err := s.ReserveQuota(ctx, userID, sttReserveTokens)
+	if err != nil {
+		return STTResult{}, err
 	}
+	defer reservation.Release(ctx)
 
-	objectKey := BuildOSSObjectKey("stt", filename)
-	if err := PutObjectToOSS(ctx, objectKey, reader, mimeType); err != nil {
+	objectKey := s.oss.BuildObjectKey("stt", filename)
+	if err := s.oss.PutObject(ctx, objectKey, reader, mimeType); err != nil {
 		return STTResult{}, err
 	}
-	audioURL, err := PresignGetURL(ctx, objectKey, 0)
+	audioURL, err := s.oss.PresignGetURL(ctx, objectKey, 0)
 	if err != nil {
 		return STTResult{}, err
 	}
 
-	text, totalTokens, err := DashscopeAudioASR(ctx, audioURL)
+	text, totalTokens, err := s.speech.AudioASR(ctx, audioURL)
 	if err != nil {
 		return STTResult{}, err
 	}
-	if totalTokens > 0 {
-		if err := store.IncreaseUserUsedQuota(ctx, userID, totalTokens); err != nil {
-			return STTResult{}, err
-		}
+	if err := reservation.Settle(ctx, totalTokens); err != nil {
+		return STTResult{}, err
 	}
 
 	return STTResult{
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+
+	"backend/internal/llm"
+	"backend/internal/store"
+)
+
+const (
+	defaultSummaryTriggerMessages = 20
+	defaultSummaryKeepRecent      = 6
+	summaryTimeout                = 2 * time.Minute
+	summaryInstruction            = "请将以下对话总结为简洁的摘要，保留关键事实、用户的偏好与要求以及尚未完成的事项，使用对话所用的语言，不要添加对话之外的内容。"
+	summaryContextPrefix          = "以下是此前对话的摘要：\n"
+)
+
+// applySummary 读取覆盖到当前分支 history 上消息的最新摘要，返回摘要文本与摘要未覆盖的历史消息；
+// 其他分支生成的摘要不会被使用。
+func (s *Service) applySummary(ctx context.Context, conversationID int, history []store.MessageRow) (string, []store.MessageRow, error) {
+	summary, err := s.store.GetLatestSummary(ctx, conversationID, messageIDs(history))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", history, nil
+		}
+		return "", nil, err
+	}
+	i := indexOfMessage(history, summary.CoveredMessageID)
+	if i < 0 {
+		return "", history, nil
+	}
+	return summary.Content, history[i+1:], nil
+}
+
+// maybeSummarize 在后台为会话生成滚动摘要，失败只记录日志。
+func (s *Service) maybeSummarize(userID, conversationID int, provider llm.Provider) {
+	if !s.summary.Enabled || provider == nil {
+		return
+	}
+	if _, busy := s.summarizing.LoadOrStore(conversationID, struct{}{}); busy {
+		return
+	}
+	go func() {
+		defer s.summarizing.Delete(conversationID)
+		ctx, cancel := context.WithTimeout(context.Background(), summaryTimeout)
+		defer cancel()
+		if err := s.summarizeConversation(ctx, userID, conversationID, provider); err != nil {
+			log.Printf("summarize conversation %d failed: %v", conversationID, err)
+		}
+	}()
+}
+
+// summarizeConversation 将当前分支上未被摘要覆盖、且不在最新 KeepRecent 条内的消息并入摘要，并按用量扣减额度。
+func (s *Service) summarizeConversation(ctx context.Context, userID, conversationID int, provider llm.Provider) error {
+	_, _, history, err := s.loadBranch(ctx, userID, conversationID)
+	if err != nil {
+		return err
+	}
+	previous, pending, err := s.applySummary(ctx, conversationID, history)
+	if err != nil {
+		return err
+	}
+	if len(pending) < s.summary.TriggerMessages || len(pending) <= s.summary.KeepRecent {
+		return nil
+	}
+	toSummarize := pending[:len(pending)-s.summary.KeepRecent]
+
+	var transcript strings.Builder
+	if previous != "" {
+		transcript.WriteString("已有摘要：\n")
+		transcript.WriteString(previous)
+		transcript.WriteString("\n\n后续对话：\n")
+	}
+	for _, m := range toSummarize {
+		transcript.WriteString(summaryRoleLabel(m.SenderType))
+		transcript.WriteString("：")
+		transcript.WriteString(m.Content)
+		transcript.WriteString("\n")
+	}
+
+	messages := []llm.Message{
+		{Role: llm.RoleSystem, Content: summaryInstruction},
+		{Role: llm.RoleUser, Content: transcript.String()},
+	}
+	reservation, err := s.ReserveQuota(ctx, userID, llm.EstimateMessagesTokens(messages)+completionReserveTokens)
+	if err != nil {
+		return err
+	}
+	defer reservation.Release(ctx)
+
+	reply, usage, err := provider.ChatCompletion(ctx, messages)
+	if err != nil {
+		return err
+	}
+	if err := reservation.Settle(ctx, usage.Total()); err != nil {
+		return err
+	}
+	reply = strings.TrimSpace(reply)
+	if reply == "" {
+		return nil
+	}
+	_, err = s.store.InsertSummary(ctx, conversationID, reply, toSummarize[len(toSummarize)-1].MessageID, usage.Total())
+	return err
+}
+
+func summaryRoleLabel(sender int) string {
+	switch sender {
+	case store.SenderAssistant:
+		return "助手"
+	case store.SenderSystem:
+		return "系统"
+	default:
+		return "用户"
+	}
+}
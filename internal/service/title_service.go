@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"backend/internal/llm"
+	"backend/internal/store"
+)
+
+const (
+	defaultTitleMaxLength = 30
+	titleTimeout          = time.Minute
+	// titleExcerptRunes 生成标题时问答各自截取的最大字符数。
+	titleExcerptRunes = 500
+	titleInstruction  = "请为以下对话生成一个简短的标题，概括对话主题，使用对话所用的语言，不超过 %d 个字。只输出标题本身，不要加引号、前缀或句末标点。"
+)
+
+// maybeGenerateTitle 会话标题为空且未由用户指定时，在后台根据本轮问答生成标题，失败只记录日志。
+func (s *Service) maybeGenerateTitle(userID int, conv store.ConversationInfo, question, answer string, provider llm.Provider) {
+	if s.title.Disabled || provider == nil || conv.TitleManual || conv.Title != "" {
+		return
+	}
+	if _, busy := s.titling.LoadOrStore(conv.ConversationID, struct{}{}); busy {
+		return
+	}
+	go func() {
+		defer s.titling.Delete(conv.ConversationID)
+		ctx, cancel := context.WithTimeout(context.Background(), titleTimeout)
+		defer cancel()
+		if err := s.generateTitle(ctx, userID, conv.ConversationID, question, answer, provider); err != nil {
+			log.Printf("generate title for conversation %d failed: %v", conv.ConversationID, err)
+		}
+	}()
+}
+
+// generateTitle 调用模型生成标题并按用量扣减额度；写入时标题已由用户指定则放弃。
+func (s *Service) generateTitle(ctx context.Context, userID, conversationID int, question, answer string, provider llm.Provider) error {
+	var transcript strings.Builder
+	transcript.WriteString(summaryRoleLabel(store.SenderUser))
+	transcript.WriteString("：")
+	transcript.WriteString(truncateRunes(question, titleExcerptRunes))
+	if answer != "" {
+		transcript.WriteString("\n")
+		transcript.WriteString(summaryRoleLabel(store.SenderAssistant))
+		transcript.WriteString("：")
+		transcript.WriteString(truncateRunes(answer, titleExcerptRunes))
+	}
+
+	messages := []llm.Message{
+		{Role: llm.RoleSystem, Content: fmt.Sprintf(titleInstruction, s.title.MaxLength)},
+		{Role: llm.RoleUser, Content: transcript.String()},
+	}
+	reservation, err := s.ReserveQuota(ctx, userID, llm.EstimateMessagesTokens(messages)+completionReserveTokens)
+	if err != nil {
+		return err
+	}
+	defer reservation.Release(ctx)
+
+	reply, usage, err := provider.ChatCompletion(ctx, messages)
+	if err != nil {
+		return err
+	}
+	if err := reservation.Settle(ctx, usage.Total()); err != nil {
+		return err
+	}
+	title := cleanTitle(reply, s.title.MaxLength)
+	if title == "" {
+		return nil
+	}
+	_, err = s.store.SetGeneratedTitle(ctx, conversationID, title)
+	return err
+}
+
+// cleanTitle 取模型回复的第一行，去掉引号、空白与句末标点，并截断到 maxLength 个字符。
+func cleanTitle(reply string, maxLength int) string {
+	title := strings.TrimSpace(reply)
+	if i := strings.IndexAny(title, "\r\n"); i >= 0 {
+		title = title[:i]
+	}
+	for _, prefix := range []string{"标题：", "标题:", "Title:"} {
+		title = strings.TrimPrefix(title, prefix)
+	}
+	title = strings.Trim(title, " \t\"'`“”‘’「」『』《》。.，,！!？?：:")
+	return strings.TrimSpace(truncateRunes(title, maxLength))
+}
+
+// truncateRunes 将 s 截断到最多 n 个字符。
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
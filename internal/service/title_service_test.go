@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"backend/internal/llm"
+)
+
+func TestCleanTitle(t *testing.T) {
+	tests := []struct {
+		name      string
+		reply     string
+		maxLength int
+		want      string
+	}{
+		{"plain", "Go 并发入门", 30, "Go 并发入门"},
+		{"whitespace", "  \tGo 并发入门 \n", 30, "Go 并发入门"},
+		{"curly quotes", "“Go 并发入门”", 30, "Go 并发入门"},
+		{"corner brackets", "「天气闲聊」", 30, "天气闲聊"},
+		{"ascii quotes and period", `"Hello world."`, 30, "Hello world"},
+		{"chinese prefix", "标题：Go 并发", 30, "Go 并发"},
+		{"english prefix", "Title: Hello world!", 30, "Hello world"},
+		{"first line only", "Weekend plans\nThis title sums up the chat.", 30, "Weekend plans"},
+		{"truncates runes", strings.Repeat("长", 40), 30, strings.Repeat("长", 30)},
+		{"trims after truncating", "abc def", 4, "abc"},
+		{"only punctuation", "“。”", 30, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cleanTitle(tt.reply, tt.maxLength); got != tt.want {
+				t.Errorf("cleanTitle(%q, %d) = %q, want %q", tt.reply, tt.maxLength, got, tt.want)
+			}
+		})
+	}
+}
+
+// promptUsage 返回 Fake 对 messages 计算的提示用量。
+func promptUsage(messages []llm.Message) int {
+	n := 0
+	for _, m := range messages {
+		n += utf8.RuneCountInString(m.Text())
+	}
+	return n
+}
+
+func TestGenerateTitle(t *testing.T) {
+	s, st, fake := newTestService(t)
+	ctx := context.Background()
+	u, err := st.CreateUserWithQuota(ctx, "alice", "hash", "alice", "user", 10000, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conv, err := s.NewConversation(ctx, u.UserID, "", sql.NullInt64{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake.Reply = "「Weather talk」"
+	if err := s.generateTitle(ctx, u.UserID, conv.ConversationID, "how is the weather", "sunny", fake); err != nil {
+		t.Fatal(err)
+	}
+	got, err := st.GetConversation(ctx, conv.ConversationID, u.UserID)
+	if err != nil || got.Title != "Weather talk" || got.TitleManual {
+		t.Fatalf("conversation = %+v, err %v", got, err)
+	}
+	// 命名调用按实际用量扣减额度。
+	calls := fake.Calls()
+	charged := promptUsage(calls[0]) + utf8.RuneCountInString(fake.Reply)
+	assertBalance(t, st, u.UserID, charged)
+	if text := calls[0][1].Text(); !strings.Contains(text, "how is the weather") || !strings.Contains(text, "sunny") {
+		t.Errorf("titling prompt = %q", text)
+	}
+
+	// 用户指定的标题不被覆盖，已发生的调用照常计费。
+	if ok, err := s.RenameConversation(ctx, u.UserID, conv.ConversationID, "mine"); err != nil || !ok {
+		t.Fatalf("rename = %v %v", ok, err)
+	}
+	fake.Reply = "Other title"
+	if err := s.generateTitle(ctx, u.UserID, conv.ConversationID, "again", "", fake); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := st.GetConversation(ctx, conv.ConversationID, u.UserID); got.Title != "mine" || !got.TitleManual {
+		t.Errorf("manual title overwritten: %+v", got)
+	}
+	charged += promptUsage(fake.Calls()[1]) + utf8.RuneCountInString(fake.Reply)
+	assertBalance(t, st, u.UserID, charged)
+}
+
+func TestTitleGeneratedAfterFirstReply(t *testing.T) {
+	s, st, fake := newTestService(t)
+	s.title.Disabled = false
+	ctx := context.Background()
+	u, err := st.CreateUserWithQuota(ctx, "alice", "hash", "alice", "user", 10000, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	untitled, err := s.NewConversation(ctx, u.UserID, "", sql.NullInt64{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	named, err := s.NewConversation(ctx, u.UserID, "named", sql.NullInt64{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake.Reply = "Greeting"
+	if _, err := s.SendMessage(ctx, u.UserID, named.ConversationID, "TEXT", "hi", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SendMessage(ctx, u.UserID, untitled.ConversationID, "TEXT", "hi", nil); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, err := st.GetConversation(ctx, untitled.ConversationID, u.UserID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Title == "Greeting" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("title not generated: %+v", got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// 两次发送与一次命名，有标题的会话不触发命名。
+	if n := len(fake.Calls()); n != 3 {
+		t.Errorf("provider called %d times, want 3", n)
+	}
+	if got, _ := st.GetConversation(ctx, named.ConversationID, u.UserID); got.Title != "named" {
+		t.Errorf("named conversation title = %q", got.Title)
+	}
+}
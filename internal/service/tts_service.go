@@ -7,20 +7,12 @@ import (
 	"net/http"
 	"strings"
 	"time"
-
-	"backend/internal/store"
+	"unicode/utf8"
 )
 
 // RequestTTSURL ?? Dashscope TTS ????? URL?
-func RequestTTSURL(ctx context.Context, userID, messageID int) (string, error) {
-	totalQuota, usedQuota, err := store.GetUserQuotaUsage(ctx, userID)
-	if err != nil {
-		return "", err
-	}
-	if usedQuota >= totalQuota {
-		return "", ErrQuotaExceeded
-	}
-	text, err := store.GetMessageContent(ctx, userID, messageID)
+func (s *Service) RequestTTSURL(ctx context.Context, userID, messageID int) (string, error) {
+	text, err := s.store.GetMessageContent(ctx, userID, messageID)
 	if err != nil {
 		return "", err
 	}
@@ -28,21 +20,25 @@ func RequestTTSURL(ctx context.Context, userID, messageID int) (string, error) {
 	if text == "" {
 		return "", errors.New("missing text")
 	}
-	audioURL, tokens, err := DashscopeTTS(ctx, text, "", "")
+	reservation, err := s.ReserveQuota(ctx, userID, utf8.RuneCountInString(text))
 	if err != nil {
 		return "", err
 	}
-	if tokens > 0 {
-		if err := store.IncreaseUserUsedQuota(ctx, userID, tokens); err != nil {
-			return "", err
-		}
+	defer reservation.Release(ctx)
+
+	audioURL, tokens, err := s.speech.TTS(ctx, text, "", "")
+	if err != nil {
+		return "", err
+	}
+	if err := reservation.Settle(ctx, tokens); err != nil {
+		return "", err
 	}
 	return audioURL, nil
 }
 
 // TextToSpeech 调用 Dashscope TTS 并返回音频数据。
-func TextToSpeech(ctx context.Context, userID, messageID int) ([]byte, error) {
-	audioURL, err := RequestTTSURL(ctx, userID, messageID)
+func (s *Service) TextToSpeech(ctx context.Context, userID, messageID int) ([]byte, error) {
+	audioURL, err := s.RequestTTSURL(ctx, userID, messageID)
 	if err != nil {
 		return nil, err
 	}
@@ -64,15 +60,8 @@ func TextToSpeech(ctx context.Context, userID, messageID int) ([]byte, error) {
 }
 
 // StreamTextToSpeech streams Dashscope TTS audio chunks to writer.
-func StreamTextToSpeech(ctx context.Context, userID, messageID int, writer io.Writer, flush func()) error {
-	totalQuota, usedQuota, err := store.GetUserQuotaUsage(ctx, userID)
-	if err != nil {
-		return err
-	}
-	if usedQuota >= totalQuota {
-		return ErrQuotaExceeded
-	}
-	text, err := store.GetMessageContent(ctx, userID, messageID)
+func (s *Service) StreamTextToSpeech(ctx context.Context, userID, messageID int, writer io.Writer, flush func()) error {
+	text, err := s.store.GetMessageContent(ctx, userID, messageID)
 	if err != nil {
 		return err
 	}
@@ -80,6 +69,11 @@ func StreamTextToSpeech(ctx context.Context, userID, messageID int, writer io.Wr
 	if text == "" {
 		return errors.New("missing text")
 	}
+	reservation, err := s.ReserveQuota(ctx, userID, utf8.RuneCountInString(text))
+	if err != nil {
+		return err
+	}
+	defer reservation.Release(ctx)
 
 	lastTokens := 0
 	onChunk := func(chunk []byte) {
@@ -96,15 +90,12 @@ func StreamTextToSpeech(ctx context.Context, userID, messageID int, writer io.Wr
 			lastTokens = tokens
 		}
 	}
-	if err := DashscopeTTSStream(ctx, text, "", "", onChunk, onUsage); err != nil {
+	if err := s.speech.TTSStream(ctx, text, "", "", onChunk, onUsage); err != nil {
+		// 已推送的音频仍按上游报告的用量计费。
+		_ = reservation.Settle(ctx, lastTokens)
 		return err
 	}
-	if lastTokens > 0 {
-		if err := store.IncreaseUserUsedQuota(ctx, userID, lastTokens); err != nil {
-			return err
-		}
-	}
-	return nil
+	return reservation.Settle(ctx, lastTokens)
 }
 
 func sanitizeTTSText(text string) string {
@@ -3,16 +3,13 @@ package service
 import (
 	"context"
 	"errors"
-	"fmt"
 	"io"
 	"os"
-	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
-	"backend/internal/llm"
 	"backend/internal/store"
 )
 
@@ -49,21 +46,21 @@ func SaveLocalFile(reader io.Reader, filename string) (string, string, error) {
 }
 
 // UploadAndRecord 上传并记录附件。
-func UploadAndRecord(ctx context.Context, userID int, filename, mimeType string, reader io.Reader) (UploadFileResult, error) {
+func (s *Service) UploadAndRecord(ctx context.Context, userID int, filename, mimeType string, reader io.Reader) (UploadFileResult, error) {
 	if reader == nil {
 		return UploadFileResult{}, errors.New("missing file")
 	}
 
 	llmModel := "unknown"
-	if client := llm.Get(); client != nil && client.Model() != "" {
-		llmModel = client.Model()
+	if name := s.models.DefaultModel(); name != "" {
+		llmModel = name
 	}
 
-	uploadConvID, err := store.GetOrCreateUploadConversation(ctx, userID, llmModel)
+	uploadConvID, err := s.store.GetOrCreateUploadConversation(ctx, userID, llmModel)
 	if err != nil {
 		return UploadFileResult{}, err
 	}
-	uploadMsgID, err := store.CreateUploadMessage(ctx, uploadConvID)
+	uploadMsgID, err := s.store.CreateUploadMessage(ctx, uploadConvID)
 	if err != nil {
 		return UploadFileResult{}, err
 	}
@@ -76,14 +73,14 @@ func UploadAndRecord(ctx context.Context, userID int, filename, mimeType string,
 	urlOrPath := ""
 	publicURL := ""
 
-	if ossConfig.Enabled() {
-		objectKey := buildOSSObjectKey(filename)
-		if err := PutObjectToOSS(ctx, objectKey, reader, mimeType); err != nil {
+	if s.oss != nil {
+		objectKey := s.oss.BuildObjectKey("", filename)
+		if err := s.oss.PutObject(ctx, objectKey, reader, mimeType); err != nil {
 			return UploadFileResult{}, err
 		}
 		storageType = store.StorageTypeOSS
 		urlOrPath = objectKey
-		signedURL, err := PresignGetURL(ctx, objectKey, 0)
+		signedURL, err := s.oss.PresignGetURL(ctx, objectKey, 0)
 		if err != nil {
 			return UploadFileResult{}, err
 		}
@@ -97,7 +94,7 @@ func UploadAndRecord(ctx context.Context, userID int, filename, mimeType string,
 		publicURL = publicPath
 	}
 
-	attachID, err := store.CreateAttachment(ctx, uploadMsgID, "FILE", mimeType, storageType, urlOrPath, nil)
+	attachID, err := s.store.CreateAttachment(ctx, uploadMsgID, "FILE", mimeType, storageType, urlOrPath, nil)
 	if err != nil {
 		return UploadFileResult{}, err
 	}
@@ -110,23 +107,10 @@ func UploadAndRecord(ctx context.Context, userID int, filename, mimeType string,
 	}, nil
 }
 
-func buildOSSObjectKey(filename string) string {
-	name := "upload.bin"
-	if strings.TrimSpace(filename) != "" {
-		name = filepath.Base(filename)
-	}
-	objectName := fmt.Sprintf("%d_%s", time.Now().UnixNano(), name)
-	prefix := strings.Trim(ossConfig.Prefix, "/")
-	if prefix == "" {
-		return objectName
-	}
-	return path.Join(prefix, objectName)
-}
-
 // ResolveAttachmentURL returns a response-ready URL for attachments.
-func ResolveAttachmentURL(ctx context.Context, attachment store.AttachmentInfo) (string, error) {
+func (s *Service) ResolveAttachmentURL(ctx context.Context, attachment store.AttachmentInfo) (string, error) {
 	if strings.EqualFold(attachment.StorageType, store.StorageTypeOSS) {
-		return PresignGetURL(ctx, attachment.URLOrPath, 0)
+		return s.oss.PresignGetURL(ctx, attachment.URLOrPath, 0)
 	}
 	return attachment.URLOrPath, nil
 }
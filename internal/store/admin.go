@@ -5,8 +5,8 @@ import (
 )
 
 // ListUsers 分页获取用户列表。
-func ListUsers(ctx context.Context, page, pageSize int) ([]User, int, error) {
-	dbx, err := GetDB()
+func (s *SQLStore) ListUsers(ctx context.Context, page, pageSize int) ([]User, int, error) {
+	dbx, err := s.conn(ctx)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -16,7 +16,7 @@ func ListUsers(ctx context.Context, page, pageSize int) ([]User, int, error) {
 	}
 	offset := (page - 1) * pageSize
 	rows, err := dbx.QueryContext(ctx, `
-		SELECT user_id, username, nickname, role, total_quota, used_quota
+		SELECT user_id, username, nickname, role, total_quota, used_quota, must_change_password
 		FROM users
 		ORDER BY user_id DESC
 		LIMIT ? OFFSET ?
@@ -29,7 +29,7 @@ func ListUsers(ctx context.Context, page, pageSize int) ([]User, int, error) {
 	users := make([]User, 0)
 	for rows.Next() {
 		var u User
-		if err := rows.Scan(&u.UserID, &u.Username, &u.Nickname, &u.Role, &u.TotalQuota, &u.UsedQuota); err != nil {
+		if err := rows.Scan(&u.UserID, &u.Username, &u.Nickname, &u.Role, &u.TotalQuota, &u.UsedQuota, &u.MustChangePassword); err != nil {
 			return nil, 0, err
 		}
 		users = append(users, u)
@@ -41,8 +41,8 @@ func ListUsers(ctx context.Context, page, pageSize int) ([]User, int, error) {
 }
 
 // CreateUserWithQuota 创建用户并返回对象。
-func CreateUserWithQuota(ctx context.Context, username, password, nickname, role string, total, used int) (User, error) {
-	newID, err := CreateUser(ctx, username, password, nickname, role, int64(total), int64(used))
+func (s *SQLStore) CreateUserWithQuota(ctx context.Context, username, password, nickname, role string, total, used int) (User, error) {
+	newID, err := s.CreateUser(ctx, username, password, nickname, role, int64(total), int64(used))
 	if err != nil {
 		return User{}, err
 	}
@@ -57,8 +57,8 @@ func CreateUserWithQuota(ctx context.Context, username, password, nickname, role
 }
 
 // SetUserQuota 设置用户额度，返回是否命中。
-func SetUserQuota(ctx context.Context, userID int, quota int) (bool, error) {
-	dbx, err := GetDB()
+func (s *SQLStore) SetUserQuota(ctx context.Context, userID int, quota int) (bool, error) {
+	dbx, err := s.conn(ctx)
 	if err != nil {
 		return false, err
 	}
@@ -76,8 +76,8 @@ func SetUserQuota(ctx context.Context, userID int, quota int) (bool, error) {
 }
 
 // DeleteUser 删除用户，返回是否命中。
-func DeleteUser(ctx context.Context, userID int) (bool, error) {
-	dbx, err := GetDB()
+func (s *SQLStore) DeleteUser(ctx context.Context, userID int) (bool, error) {
+	dbx, err := s.conn(ctx)
 	if err != nil {
 		return false, err
 	}
@@ -3,8 +3,8 @@ package store
 import "context"
 
 // GetUserPassword 根据用户名获取用户ID与密码。
-func GetUserPassword(ctx context.Context, username string) (int, string, error) {
-	dbx, err := GetDB()
+func (s *SQLStore) GetUserPassword(ctx context.Context, username string) (int, string, error) {
+	dbx, err := s.conn(ctx)
 	if err != nil {
 		return 0, "", err
 	}
@@ -22,27 +22,46 @@ func GetUserPassword(ctx context.Context, username string) (int, string, error)
 }
 
 // GetUserByUsername 根据用户名获取用户信息（不含密码）。
-func GetUserByUsername(ctx context.Context, username string) (User, error) {
-	dbx, err := GetDB()
+func (s *SQLStore) GetUserByUsername(ctx context.Context, username string) (User, error) {
+	dbx, err := s.conn(ctx)
 	if err != nil {
 		return User{}, err
 	}
 
 	var u User
 	row := dbx.QueryRowContext(ctx, `
-		SELECT user_id, username, nickname, role, total_quota, used_quota
+		SELECT user_id, username, nickname, role, total_quota, used_quota, must_change_password, token_version
 		FROM users
 		WHERE username = ? AND status = 1
 	`, username)
-	if err := row.Scan(&u.UserID, &u.Username, &u.Nickname, &u.Role, &u.TotalQuota, &u.UsedQuota); err != nil {
+	if err := row.Scan(&u.UserID, &u.Username, &u.Nickname, &u.Role, &u.TotalQuota, &u.UsedQuota, &u.MustChangePassword, &u.TokenVersion); err != nil {
+		return User{}, err
+	}
+	return u, nil
+}
+
+// GetUserByID 根据用户ID获取用户信息（不含密码）。
+func (s *SQLStore) GetUserByID(ctx context.Context, userID int) (User, error) {
+	dbx, err := s.conn(ctx)
+	if err != nil {
+		return User{}, err
+	}
+
+	var u User
+	row := dbx.QueryRowContext(ctx, `
+		SELECT user_id, username, nickname, role, total_quota, used_quota, must_change_password, token_version
+		FROM users
+		WHERE user_id = ? AND status = 1
+	`, userID)
+	if err := row.Scan(&u.UserID, &u.Username, &u.Nickname, &u.Role, &u.TotalQuota, &u.UsedQuota, &u.MustChangePassword, &u.TokenVersion); err != nil {
 		return User{}, err
 	}
 	return u, nil
 }
 
 // CreateUser 创建用户并返回新ID。
-func CreateUser(ctx context.Context, username, password, nickname, role string, total, used int64) (int, error) {
-	dbx, err := GetDB()
+func (s *SQLStore) CreateUser(ctx context.Context, username, password, nickname, role string, total, used int64) (int, error) {
+	dbx, err := s.conn(ctx)
 	if err != nil {
 		return 0, err
 	}
@@ -61,8 +80,8 @@ func CreateUser(ctx context.Context, username, password, nickname, role string,
 }
 
 // UpdateUserPassword 更新用户密码。
-func UpdateUserPassword(ctx context.Context, username, newPassword string) error {
-	dbx, err := GetDB()
+func (s *SQLStore) UpdateUserPassword(ctx context.Context, username, newPassword string) error {
+	dbx, err := s.conn(ctx)
 	if err != nil {
 		return err
 	}
@@ -70,47 +89,50 @@ func UpdateUserPassword(ctx context.Context, username, newPassword string) error
 	return err
 }
 
-// CountUsersByUsername 统计用户名数量。
-func CountUsersByUsername(ctx context.Context, username string) (int, error) {
-	dbx, err := GetDB()
+// GetUserAuthState 获取用户当前令牌版本与改密标记，用户不存在或已停用时返回 sql.ErrNoRows。
+func (s *SQLStore) GetUserAuthState(ctx context.Context, userID int) (UserAuthState, error) {
+	dbx, err := s.conn(ctx)
 	if err != nil {
-		return 0, err
+		return UserAuthState{}, err
 	}
-	var count int
-	if err := dbx.QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE username = ?`, username).Scan(&count); err != nil {
-		return 0, err
+	var st UserAuthState
+	if err := dbx.QueryRowContext(ctx, `
+		SELECT role, token_version, must_change_password FROM users WHERE user_id = ? AND status = 1
+	`, userID).Scan(&st.Role, &st.TokenVersion, &st.MustChangePassword); err != nil {
+		return UserAuthState{}, err
 	}
-	return count, nil
+	return st, nil
 }
 
-// GetUserQuotaUsage 获取用户额度使用情况。
-func GetUserQuotaUsage(ctx context.Context, userID int) (int, int, error) {
-	dbx, err := GetDB()
+// BumpUserTokenVersion 递增用户令牌版本。
+func (s *SQLStore) BumpUserTokenVersion(ctx context.Context, userID int) error {
+	dbx, err := s.conn(ctx)
 	if err != nil {
-		return 0, 0, err
-	}
-	var total int
-	var used int
-	if err := dbx.QueryRowContext(ctx, `
-		SELECT total_quota, used_quota
-		FROM users
-		WHERE user_id = ? AND status = 1
-	`, userID).Scan(&total, &used); err != nil {
-		return 0, 0, err
+		return err
 	}
-	return total, used, nil
+	_, err = dbx.ExecContext(ctx, `UPDATE users SET token_version = token_version + 1 WHERE user_id = ?`, userID)
+	return err
 }
 
-// IncreaseUserUsedQuota 增加用户已使用额度（允许超出总额）。
-func IncreaseUserUsedQuota(ctx context.Context, userID int, delta int) error {
-	dbx, err := GetDB()
+// SetUserMustChangePassword 设置用户是否须修改密码。
+func (s *SQLStore) SetUserMustChangePassword(ctx context.Context, userID int, must bool) error {
+	dbx, err := s.conn(ctx)
 	if err != nil {
 		return err
 	}
-	_, err = dbx.ExecContext(ctx, `
-		UPDATE users
-		SET used_quota = used_quota + ?
-		WHERE user_id = ?
-	`, delta, userID)
+	_, err = dbx.ExecContext(ctx, `UPDATE users SET must_change_password = ? WHERE user_id = ?`, must, userID)
 	return err
 }
+
+// CountUsersByUsername 统计用户名数量。
+func (s *SQLStore) CountUsersByUsername(ctx context.Context, username string) (int, error) {
+	dbx, err := s.conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	if err := dbx.QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE username = ?`, username).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
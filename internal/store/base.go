@@ -2,19 +2,18 @@ package store
 
 import (
 	"database/sql"
-	"errors"
 	"fmt"
-
-	"backend/internal/db"
+	"time"
 )
 
-// GetDB 获取全局数据库连接。
-func GetDB() (*sql.DB, error) {
-	dbx := db.Get()
-	if dbx == nil {
-		return nil, errors.New("db not initialized")
-	}
-	return dbx, nil
+// SQLStore 基于 database/sql 的 Store 实现，MySQL 与 SQLite 共用同一套 SQL。
+type SQLStore struct {
+	db *sql.DB
+}
+
+// NewSQLStore 使用给定连接创建 SQLStore。
+func NewSQLStore(dbx *sql.DB) *SQLStore {
+	return &SQLStore{db: dbx}
 }
 
 // BuildInClause 生成 IN 子句及其参数列表。
@@ -33,3 +32,14 @@ func BuildInClause(ids []int) (string, []any) {
 	}
 	return fmt.Sprintf("(%s)", string(placeholders)), args
 }
+
+// utcDateTime 将时间格式化为 UTC 的 DATETIME 字面值。deleted_at 按 UTC 保存（迁移回填使用 UTC_TIMESTAMP()），
+// 而 MySQL 驱动会把 time.Time 参数换算到 DSN 的 loc，因此以字符串传参，避免按服务器时区偏移。
+func utcDateTime(t time.Time) string {
+	return t.UTC().Format(time.DateTime)
+}
+
+// asUTC 将按 UTC 保存的 DATETIME 读出值重新解释为 UTC，忽略驱动按 loc 附加的时区。
+func asUTC(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
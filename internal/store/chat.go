@@ -3,36 +3,39 @@ package store
 import (
 	"context"
 	"database/sql"
+	"time"
 )
 
-// GetConversation 获取用户的指定会话。
-func GetConversation(ctx context.Context, conversationID int, userID int) (ConversationInfo, error) {
-	dbx, err := GetDB()
+// GetConversation 获取用户未删除的指定会话（含已归档）。
+func (s *SQLStore) GetConversation(ctx context.Context, conversationID int, userID int) (ConversationInfo, error) {
+	dbx, err := s.conn(ctx)
 	if err != nil {
 		return ConversationInfo{}, err
 	}
 	var cinfo ConversationInfo
 	row := dbx.QueryRowContext(ctx, `
-		SELECT conversation_id, title, status, llm_model
+		SELECT conversation_id, title, title_manual, status, llm_model, COALESCE(active_message_id, 0),
+		       COALESCE(forked_from_conversation_id, 0), COALESCE(forked_from_message_id, 0)
 		FROM conversations
-		WHERE conversation_id = ? AND user_id = ?
+		WHERE conversation_id = ? AND user_id = ? AND status <> 'DELETED'
 	`, conversationID, userID)
-	if err := row.Scan(&cinfo.ConversationID, &cinfo.Title, &cinfo.Status, &cinfo.LLMModel); err != nil {
+	if err := row.Scan(&cinfo.ConversationID, &cinfo.Title, &cinfo.TitleManual, &cinfo.Status, &cinfo.LLMModel, &cinfo.ActiveMessageID,
+		&cinfo.ForkedFromConversationID, &cinfo.ForkedFromMessageID); err != nil {
 		return ConversationInfo{}, err
 	}
 	return cinfo, nil
 }
 
-// CreateConversation 创建会话并返回概要信息。
-func CreateConversation(ctx context.Context, userID int, title, llmModel string, systemPrompt sql.NullInt64) (ConversationInfo, error) {
-	dbx, err := GetDB()
+// CreateConversation 创建会话并返回概要信息，systemPromptContent 为创建时的预设内容快照；标题为空时等待自动命名。
+func (s *SQLStore) CreateConversation(ctx context.Context, userID int, title, llmModel string, systemPrompt sql.NullInt64, systemPromptContent sql.NullString) (ConversationInfo, error) {
+	dbx, err := s.conn(ctx)
 	if err != nil {
 		return ConversationInfo{}, err
 	}
 	res, err := dbx.ExecContext(ctx, `
-		INSERT INTO conversations (user_id, title, status, llm_model, system_prompt)
-		VALUES (?, ?, 'ACTIVE', ?, ?)
-	`, userID, title, llmModel, systemPrompt)
+		INSERT INTO conversations (user_id, title, title_manual, status, llm_model, system_prompt, system_prompt_content)
+		VALUES (?, ?, ?, 'ACTIVE', ?, ?, ?)
+	`, userID, title, title != "", llmModel, systemPrompt, systemPromptContent)
 	if err != nil {
 		return ConversationInfo{}, err
 	}
@@ -43,20 +46,67 @@ func CreateConversation(ctx context.Context, userID int, title, llmModel string,
 	return ConversationInfo{
 		ConversationID: int(convID),
 		Title:          title,
+		TitleManual:    title != "",
 		Status:         "ACTIVE",
 		LLMModel:       llmModel,
 	}, nil
 }
 
-// RenameConversation 更新会话标题。
-func RenameConversation(ctx context.Context, conversationID, userID int, title string) (bool, error) {
-	dbx, err := GetDB()
+// CreateForkedConversation 以用户的 sourceConversationID 为来源创建分叉会话，沿用来源的模型与系统提示词，
+// 并记录来源会话与分叉点消息；来源会话不存在时返回 sql.ErrNoRows。消息由调用方另行复制。
+func (s *SQLStore) CreateForkedConversation(ctx context.Context, userID, sourceConversationID, forkedFromMessageID int, title string) (ConversationInfo, error) {
+	dbx, err := s.conn(ctx)
 	if err != nil {
-		return false, err
+		return ConversationInfo{}, err
 	}
 	res, err := dbx.ExecContext(ctx, `
-		UPDATE conversations SET title = ?
+		INSERT INTO conversations (user_id, title, title_manual, status, llm_model, system_prompt, system_prompt_content,
+		                           forked_from_conversation_id, forked_from_message_id)
+		SELECT user_id, ?, ?, 'ACTIVE', llm_model, system_prompt, system_prompt_content, conversation_id, ?
+		FROM conversations
 		WHERE conversation_id = ? AND user_id = ?
+	`, title, title != "", forkedFromMessageID, sourceConversationID, userID)
+	if err != nil {
+		return ConversationInfo{}, err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return ConversationInfo{}, sql.ErrNoRows
+	}
+	convID, err := res.LastInsertId()
+	if err != nil {
+		return ConversationInfo{}, err
+	}
+	return s.GetConversation(ctx, int(convID), userID)
+}
+
+// GetConversationSystemPrompt 获取会话的系统提示词：优先使用预设当前内容，预设已删除时回退到创建时的快照。
+func (s *SQLStore) GetConversationSystemPrompt(ctx context.Context, conversationID int) (string, error) {
+	dbx, err := s.conn(ctx)
+	if err != nil {
+		return "", err
+	}
+	var content sql.NullString
+	row := dbx.QueryRowContext(ctx, `
+		SELECT COALESCE(p.content, c.system_prompt_content)
+		FROM conversations c
+		LEFT JOIN prompt_presets p ON c.system_prompt = p.prompt_preset_id
+		WHERE c.conversation_id = ?
+	`, conversationID)
+	if err := row.Scan(&content); err != nil {
+		return "", err
+	}
+	return content.String, nil
+}
+
+// RenameConversation 更新会话标题，并标记为用户指定，此后不再自动命名。
+func (s *SQLStore) RenameConversation(ctx context.Context, conversationID, userID int, title string) (bool, error) {
+	dbx, err := s.conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	res, err := dbx.ExecContext(ctx, `
+		UPDATE conversations SET title = ?, title_manual = 1
+		WHERE conversation_id = ? AND user_id = ? AND status <> 'DELETED'
 	`, title, conversationID, userID)
 	if err != nil {
 		return false, err
@@ -65,16 +115,16 @@ func RenameConversation(ctx context.Context, conversationID, userID int, title s
 	return affected > 0, nil
 }
 
-// DeleteConversation 逻辑删除会话。
-func DeleteConversation(ctx context.Context, conversationID, userID int) (bool, error) {
-	dbx, err := GetDB()
+// SetGeneratedTitle 写入自动生成的标题，标题已由用户指定或会话已删除时不修改并返回 false。
+func (s *SQLStore) SetGeneratedTitle(ctx context.Context, conversationID int, title string) (bool, error) {
+	dbx, err := s.conn(ctx)
 	if err != nil {
 		return false, err
 	}
 	res, err := dbx.ExecContext(ctx, `
-		UPDATE conversations SET status = 'DELETED'
-		WHERE conversation_id = ? AND user_id = ?
-	`, conversationID, userID)
+		UPDATE conversations SET title = ?
+		WHERE conversation_id = ? AND title_manual = 0 AND status <> 'DELETED'
+	`, title, conversationID)
 	if err != nil {
 		return false, err
 	}
@@ -82,71 +132,69 @@ func DeleteConversation(ctx context.Context, conversationID, userID int) (bool,
 	return affected > 0, nil
 }
 
-// CountMessages 统计会话消息数量。
-func CountMessages(ctx context.Context, userID, conversationID int) (int, error) {
-	dbx, err := GetDB()
+// DeleteConversation 逻辑删除会话并记录删除时间，保留期内可恢复；已删除的会话返回 false。
+func (s *SQLStore) DeleteConversation(ctx context.Context, conversationID, userID int) (bool, error) {
+	dbx, err := s.conn(ctx)
 	if err != nil {
-		return 0, err
+		return false, err
 	}
-	var total int
-	if err := dbx.QueryRowContext(ctx, `
-		SELECT COUNT(*) FROM messages m
-		JOIN conversations c ON m.conversation_id = c.conversation_id
-		WHERE c.user_id = ? AND m.conversation_id = ?
-	`, userID, conversationID).Scan(&total); err != nil {
-		return 0, err
+	res, err := dbx.ExecContext(ctx, `
+		UPDATE conversations SET status = 'DELETED', deleted_at = ?
+		WHERE conversation_id = ? AND user_id = ? AND status <> 'DELETED'
+	`, utcDateTime(time.Now()), conversationID, userID)
+	if err != nil {
+		return false, err
 	}
-	return total, nil
+	affected, _ := res.RowsAffected()
+	return affected > 0, nil
 }
 
-// ListMessages 获取会话消息列表与消息ID。
-func ListMessages(ctx context.Context, userID, conversationID, page, pageSize int) ([]MessageRow, []int, error) {
-	dbx, err := GetDB()
+// ArchiveConversation 归档进行中的会话，归档后仍可查看但不能继续对话；非 ACTIVE 状态返回 false。
+func (s *SQLStore) ArchiveConversation(ctx context.Context, conversationID, userID int) (bool, error) {
+	dbx, err := s.conn(ctx)
 	if err != nil {
-		return nil, nil, err
+		return false, err
 	}
-	offset := (page - 1) * pageSize
-	rows, err := dbx.QueryContext(ctx, `
-		SELECT m.message_id, m.sender_type, m.content_type, m.content, m.token_total
-		FROM messages m
-		JOIN conversations c ON m.conversation_id = c.conversation_id
-		WHERE c.user_id = ? AND m.conversation_id = ?
-		ORDER BY m.created_at DESC
-		LIMIT ? OFFSET ?
-	`, userID, conversationID, pageSize, offset)
+	res, err := dbx.ExecContext(ctx, `
+		UPDATE conversations SET status = 'ARCHIVED'
+		WHERE conversation_id = ? AND user_id = ? AND status = 'ACTIVE'
+	`, conversationID, userID)
 	if err != nil {
-		return nil, nil, err
+		return false, err
 	}
-	defer rows.Close()
+	affected, _ := res.RowsAffected()
+	return affected > 0, nil
+}
 
-	items := make([]MessageRow, 0)
-	ids := make([]int, 0)
-	for rows.Next() {
-		var m MessageRow
-		if err := rows.Scan(&m.MessageID, &m.SenderType, &m.ContentType, &m.Content, &m.TokenTotal); err != nil {
-			return nil, nil, err
-		}
-		items = append(items, m)
-		ids = append(ids, m.MessageID)
+// RestoreConversation 将已归档或已删除（尚未清除）的会话恢复为 ACTIVE；已是 ACTIVE 或不存在时返回 false。
+func (s *SQLStore) RestoreConversation(ctx context.Context, conversationID, userID int) (bool, error) {
+	dbx, err := s.conn(ctx)
+	if err != nil {
+		return false, err
 	}
-	if err := rows.Err(); err != nil {
-		return nil, nil, err
+	res, err := dbx.ExecContext(ctx, `
+		UPDATE conversations SET status = 'ACTIVE', deleted_at = NULL
+		WHERE conversation_id = ? AND user_id = ? AND status IN ('ARCHIVED', 'DELETED')
+	`, conversationID, userID)
+	if err != nil {
+		return false, err
 	}
-	return items, ids, nil
+	affected, _ := res.RowsAffected()
+	return affected > 0, nil
 }
 
-// ListAllMessages 获取会话全部消息（按时间升序）。
-func ListAllMessages(ctx context.Context, userID, conversationID int) ([]MessageRow, []int, error) {
-	dbx, err := GetDB()
+// ListAllMessages 获取会话全部消息（含各分支，按时间升序）。
+func (s *SQLStore) ListAllMessages(ctx context.Context, userID, conversationID int) ([]MessageRow, []int, error) {
+	dbx, err := s.conn(ctx)
 	if err != nil {
 		return nil, nil, err
 	}
 	rows, err := dbx.QueryContext(ctx, `
-		SELECT m.message_id, m.sender_type, m.content_type, m.content, m.token_total
+		SELECT m.message_id, COALESCE(m.parent_id, 0), m.sender_type, m.content_type, m.content, m.status, m.prompt_tokens, m.completion_tokens, m.token_total
 		FROM messages m
 		JOIN conversations c ON m.conversation_id = c.conversation_id
-		WHERE c.user_id = ? AND m.conversation_id = ?
-		ORDER BY m.created_at ASC
+		WHERE c.user_id = ? AND m.conversation_id = ? AND c.status <> 'DELETED'
+		ORDER BY m.created_at ASC, m.message_id ASC
 	`, userID, conversationID)
 	if err != nil {
 		return nil, nil, err
@@ -157,7 +205,7 @@ func ListAllMessages(ctx context.Context, userID, conversationID int) ([]Message
 	ids := make([]int, 0)
 	for rows.Next() {
 		var m MessageRow
-		if err := rows.Scan(&m.MessageID, &m.SenderType, &m.ContentType, &m.Content, &m.TokenTotal); err != nil {
+		if err := rows.Scan(&m.MessageID, &m.ParentID, &m.SenderType, &m.ContentType, &m.Content, &m.Status, &m.PromptTokens, &m.CompletionTokens, &m.TokenTotal); err != nil {
 			return nil, nil, err
 		}
 		items = append(items, m)
@@ -170,8 +218,8 @@ func ListAllMessages(ctx context.Context, userID, conversationID int) ([]Message
 }
 
 // GetMessageContent 获取用户消息文本内容。
-func GetMessageContent(ctx context.Context, userID, messageID int) (string, error) {
-	dbx, err := GetDB()
+func (s *SQLStore) GetMessageContent(ctx context.Context, userID, messageID int) (string, error) {
+	dbx, err := s.conn(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -180,7 +228,7 @@ func GetMessageContent(ctx context.Context, userID, messageID int) (string, erro
 		SELECT m.content
 		FROM messages m
 		JOIN conversations c ON m.conversation_id = c.conversation_id
-		WHERE c.user_id = ? AND m.message_id = ?
+		WHERE c.user_id = ? AND m.message_id = ? AND c.status <> 'DELETED'
 	`, userID, messageID)
 	if err := row.Scan(&content); err != nil {
 		return "", err
@@ -188,16 +236,18 @@ func GetMessageContent(ctx context.Context, userID, messageID int) (string, erro
 	return content, nil
 }
 
-// InsertMessage 创建消息并返回 ID。
-func InsertMessage(ctx context.Context, conversationID int, senderType int, contentType, content string, tokenTotal int) (int, error) {
-	dbx, err := GetDB()
+// InsertMessage 创建状态为 COMPLETED 的消息并返回 ID。parentID 为同一分支上的上一条消息，0 表示会话开头；
+// token_total 为 promptTokens 与 completionTokens 之和。
+func (s *SQLStore) InsertMessage(ctx context.Context, conversationID, parentID int, senderType int, contentType, content string, promptTokens, completionTokens int) (int, error) {
+	dbx, err := s.conn(ctx)
 	if err != nil {
 		return 0, err
 	}
+	parent := sql.NullInt64{Int64: int64(parentID), Valid: parentID > 0}
 	res, err := dbx.ExecContext(ctx, `
-		INSERT INTO messages (conversation_id, sender_type, content_type, content, token_total)
-		VALUES (?, ?, ?, ?, ?)
-	`, conversationID, senderType, contentType, content, tokenTotal)
+		INSERT INTO messages (conversation_id, parent_id, sender_type, content_type, content, prompt_tokens, completion_tokens, token_total)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, conversationID, parent, senderType, contentType, content, promptTokens, completionTokens, promptTokens+completionTokens)
 	if err != nil {
 		return 0, err
 	}
@@ -208,9 +258,58 @@ func InsertMessage(ctx context.Context, conversationID int, senderType int, cont
 	return int(id), nil
 }
 
+// SetMessageStatus 更新消息状态（store.MessageStatusCompleted 等）。
+func (s *SQLStore) SetMessageStatus(ctx context.Context, messageID int, status string) error {
+	dbx, err := s.conn(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = dbx.ExecContext(ctx, `UPDATE messages SET status = ? WHERE message_id = ?`, status, messageID)
+	return err
+}
+
+// SetActiveMessage 设置会话当前分支的末条消息。
+func (s *SQLStore) SetActiveMessage(ctx context.Context, conversationID, messageID int) error {
+	dbx, err := s.conn(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = dbx.ExecContext(ctx, `
+		UPDATE conversations SET active_message_id = ?
+		WHERE conversation_id = ?
+	`, messageID, conversationID)
+	return err
+}
+
 // AttachFilesToMessage 绑定附件到消息。
-func AttachFilesToMessage(ctx context.Context, userID, messageID int, attachmentIDs []int) error {
-	dbx, err := GetDB()
+func (s *SQLStore) AttachFilesToMessage(ctx context.Context, userID, messageID int, attachmentIDs []int) error {
+	dbx, err := s.conn(ctx)
+	if err != nil {
+		return err
+	}
+	inClause, args := BuildInClause(attachmentIDs)
+	if inClause == "" {
+		return nil
+	}
+	args = append([]any{messageID}, args...)
+	args = append(args, userID)
+	// 使用子查询而非 UPDATE ... JOIN，以兼容 MySQL 与 SQLite。
+	_, err = dbx.ExecContext(ctx, `
+		UPDATE message_attachments
+		SET message_id = ?
+		WHERE attachment_id IN `+inClause+`
+		  AND message_id IN (
+			SELECT m.message_id
+			FROM messages m
+			JOIN conversations c ON m.conversation_id = c.conversation_id
+			WHERE c.user_id = ?
+		  )`, args...)
+	return err
+}
+
+// CopyAttachmentsToMessage 将用户已有的附件复制一份绑定到消息，原附件保持不变。
+func (s *SQLStore) CopyAttachmentsToMessage(ctx context.Context, userID, messageID int, attachmentIDs []int) error {
+	dbx, err := s.conn(ctx)
 	if err != nil {
 		return err
 	}
@@ -220,17 +319,19 @@ func AttachFilesToMessage(ctx context.Context, userID, messageID int, attachment
 	}
 	args = append([]any{messageID, userID}, args...)
 	_, err = dbx.ExecContext(ctx, `
-		UPDATE message_attachments ma
+		INSERT INTO message_attachments (message_id, attachment_type, mime_type, storage_type, url_or_path, duration_ms)
+		SELECT ?, ma.attachment_type, ma.mime_type, ma.storage_type, ma.url_or_path, ma.duration_ms
+		FROM message_attachments ma
 		JOIN messages m ON ma.message_id = m.message_id
 		JOIN conversations c ON m.conversation_id = c.conversation_id
-		SET ma.message_id = ?
-		WHERE c.user_id = ? AND ma.attachment_id IN `+inClause, args...)
+		WHERE c.user_id = ? AND ma.attachment_id IN `+inClause+`
+		ORDER BY ma.attachment_id`, args...)
 	return err
 }
 
 // LoadAttachmentsMap 按 message_id 返回附件列表。
-func LoadAttachmentsMap(ctx context.Context, messageIDs []int) (map[int][]AttachmentInfo, error) {
-	dbx, err := GetDB()
+func (s *SQLStore) LoadAttachmentsMap(ctx context.Context, messageIDs []int) (map[int][]AttachmentInfo, error) {
+	dbx, err := s.conn(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -281,8 +382,8 @@ func LoadAttachmentsMap(ctx context.Context, messageIDs []int) (map[int][]Attach
 }
 
 // LoadAttachmentsByIDs loads attachments by IDs with user ownership check.
-func LoadAttachmentsByIDs(ctx context.Context, userID int, attachmentIDs []int) ([]AttachmentInfo, error) {
-	dbx, err := GetDB()
+func (s *SQLStore) LoadAttachmentsByIDs(ctx context.Context, userID int, attachmentIDs []int) ([]AttachmentInfo, error) {
+	dbx, err := s.conn(ctx)
 	if err != nil {
 		return nil, err
 	}
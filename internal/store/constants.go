@@ -12,3 +12,16 @@ const (
 	StorageTypeLocal = "LOCAL"
 	StorageTypeOSS   = "OSS"
 )
+
+// 消息状态（与数据库保持一致）：STOPPED 表示生成被停止或客户端断开，内容为部分回复。
+const (
+	MessageStatusCompleted = "COMPLETED"
+	MessageStatusStopped   = "STOPPED"
+)
+
+// 会话状态（与数据库保持一致）：ARCHIVED 仅可查看不可继续对话，DELETED 在保留期后被彻底清除。
+const (
+	ConversationStatusActive   = "ACTIVE"
+	ConversationStatusArchived = "ARCHIVED"
+	ConversationStatusDeleted  = "DELETED"
+)
@@ -1,19 +1,23 @@
 package store
 
-import "context"
+import (
+	"context"
+	"database/sql"
+)
 
-// ListConversationsByUser 获取用户会话列表。
-func ListConversationsByUser(ctx context.Context, userID int) ([]ConversationInfo, error) {
-	dbx, err := GetDB()
+// ListConversationsByUser 获取用户指定状态的会话列表。
+func (s *SQLStore) ListConversationsByUser(ctx context.Context, userID int, status string) ([]ConversationInfo, error) {
+	dbx, err := s.conn(ctx)
 	if err != nil {
 		return nil, err
 	}
 	rows, err := dbx.QueryContext(ctx, `
-		SELECT conversation_id, title, status, llm_model
+		SELECT conversation_id, title, title_manual, status, llm_model,
+		       COALESCE(forked_from_conversation_id, 0), COALESCE(forked_from_message_id, 0), deleted_at
 		FROM conversations
-		WHERE user_id = ?
+		WHERE user_id = ? AND status = ?
 		ORDER BY conversation_id DESC
-	`, userID)
+	`, userID, status)
 	if err != nil {
 		return nil, err
 	}
@@ -21,10 +25,18 @@ func ListConversationsByUser(ctx context.Context, userID int) ([]ConversationInf
 
 	conversations := make([]ConversationInfo, 0)
 	for rows.Next() {
-		var info ConversationInfo
-		if err := rows.Scan(&info.ConversationID, &info.Title, &info.Status, &info.LLMModel); err != nil {
+		var (
+			info      ConversationInfo
+			deletedAt sql.NullTime
+		)
+		if err := rows.Scan(&info.ConversationID, &info.Title, &info.TitleManual, &info.Status, &info.LLMModel,
+			&info.ForkedFromConversationID, &info.ForkedFromMessageID, &deletedAt); err != nil {
 			return nil, err
 		}
+		if deletedAt.Valid {
+			t := asUTC(deletedAt.Time)
+			info.DeletedAt = &t
+		}
 		conversations = append(conversations, info)
 	}
 	if err := rows.Err(); err != nil {
@@ -0,0 +1,227 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"sort"
+	"time"
+
+	"backend/internal/store"
+)
+
+// uploadConversationTitle 与 SQLStore.GetOrCreateUploadConversation 使用的标题一致。
+const uploadConversationTitle = "Uploads"
+
+func (s *Store) GetConversation(ctx context.Context, conversationID int, userID int) (store.ConversationInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.data.conversations[conversationID]
+	if !ok || c.UserID != userID || c.Status == store.ConversationStatusDeleted {
+		return store.ConversationInfo{}, sql.ErrNoRows
+	}
+	return c.ConversationInfo, nil
+}
+
+func (s *Store) CreateConversation(ctx context.Context, userID int, title, llmModel string, systemPrompt sql.NullInt64, systemPromptContent sql.NullString) (store.ConversationInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := conversation{
+		ConversationInfo: store.ConversationInfo{
+			ConversationID: s.newID(),
+			Title:          title,
+			TitleManual:    title != "",
+			Status:         store.ConversationStatusActive,
+			LLMModel:       llmModel,
+		},
+		UserID: userID,
+	}
+	if systemPrompt.Valid {
+		id := int(systemPrompt.Int64)
+		c.SystemPrompt = &id
+	}
+	if systemPromptContent.Valid {
+		content := systemPromptContent.String
+		c.SystemPromptContent = &content
+	}
+	s.data.conversations[c.ConversationID] = c
+	return c.ConversationInfo, nil
+}
+
+func (s *Store) CreateForkedConversation(ctx context.Context, userID, sourceConversationID, forkedFromMessageID int, title string) (store.ConversationInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	src, ok := s.data.conversations[sourceConversationID]
+	if !ok || src.UserID != userID {
+		return store.ConversationInfo{}, sql.ErrNoRows
+	}
+	c := conversation{
+		ConversationInfo: store.ConversationInfo{
+			ConversationID:           s.newID(),
+			Title:                    title,
+			TitleManual:              title != "",
+			Status:                   store.ConversationStatusActive,
+			LLMModel:                 src.LLMModel,
+			ForkedFromConversationID: sourceConversationID,
+			ForkedFromMessageID:      forkedFromMessageID,
+		},
+		UserID:              userID,
+		SystemPrompt:        src.SystemPrompt,
+		SystemPromptContent: src.SystemPromptContent,
+	}
+	s.data.conversations[c.ConversationID] = c
+	return c.ConversationInfo, nil
+}
+
+func (s *Store) GetConversationSystemPrompt(ctx context.Context, conversationID int) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.data.conversations[conversationID]
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+	if c.SystemPrompt != nil {
+		if p, ok := s.data.presets[*c.SystemPrompt]; ok {
+			return p.Content, nil
+		}
+	}
+	if c.SystemPromptContent != nil {
+		return *c.SystemPromptContent, nil
+	}
+	return "", nil
+}
+
+func (s *Store) RenameConversation(ctx context.Context, conversationID, userID int, title string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.data.conversations[conversationID]
+	if !ok || c.UserID != userID || c.Status == store.ConversationStatusDeleted {
+		return false, nil
+	}
+	c.Title = title
+	c.TitleManual = true
+	s.data.conversations[conversationID] = c
+	return true, nil
+}
+
+func (s *Store) SetGeneratedTitle(ctx context.Context, conversationID int, title string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.data.conversations[conversationID]
+	if !ok || c.TitleManual || c.Status == store.ConversationStatusDeleted {
+		return false, nil
+	}
+	c.Title = title
+	s.data.conversations[conversationID] = c
+	return true, nil
+}
+
+func (s *Store) DeleteConversation(ctx context.Context, conversationID, userID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.data.conversations[conversationID]
+	if !ok || c.UserID != userID || c.Status == store.ConversationStatusDeleted {
+		return false, nil
+	}
+	now := time.Now().UTC()
+	c.Status = store.ConversationStatusDeleted
+	c.DeletedAt = &now
+	s.data.conversations[conversationID] = c
+	return true, nil
+}
+
+func (s *Store) ArchiveConversation(ctx context.Context, conversationID, userID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.data.conversations[conversationID]
+	if !ok || c.UserID != userID || c.Status != store.ConversationStatusActive {
+		return false, nil
+	}
+	c.Status = store.ConversationStatusArchived
+	s.data.conversations[conversationID] = c
+	return true, nil
+}
+
+func (s *Store) RestoreConversation(ctx context.Context, conversationID, userID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.data.conversations[conversationID]
+	if !ok || c.UserID != userID || c.Status == store.ConversationStatusActive {
+		return false, nil
+	}
+	c.Status = store.ConversationStatusActive
+	c.DeletedAt = nil
+	s.data.conversations[conversationID] = c
+	return true, nil
+}
+
+func (s *Store) ListConversationsByUser(ctx context.Context, userID int, status string) ([]store.ConversationInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]store.ConversationInfo, 0)
+	for _, c := range s.data.conversations {
+		if c.UserID == userID && c.Status == status {
+			out = append(out, c.ConversationInfo)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ConversationID > out[j].ConversationID })
+	return out, nil
+}
+
+func (s *Store) GetOrCreateUploadConversation(ctx context.Context, userID int, llmModel string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	found := 0
+	for _, c := range s.data.conversations {
+		if c.UserID == userID && c.Title == uploadConversationTitle && c.Status == store.ConversationStatusActive && c.ConversationID > found {
+			found = c.ConversationID
+		}
+	}
+	if found != 0 {
+		return found, nil
+	}
+	id := s.newID()
+	s.data.conversations[id] = conversation{
+		ConversationInfo: store.ConversationInfo{
+			ConversationID: id,
+			Title:          uploadConversationTitle,
+			TitleManual:    true,
+			Status:         store.ConversationStatusActive,
+			LLMModel:       llmModel,
+		},
+		UserID: userID,
+	}
+	return id, nil
+}
+
+func (s *Store) GetLatestSummary(ctx context.Context, conversationID int, messageIDs []int) (store.ConversationSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var (
+		latest store.ConversationSummary
+		found  bool
+	)
+	for _, sm := range s.data.summaries {
+		if sm.ConversationID == conversationID && slices.Contains(messageIDs, sm.CoveredMessageID) && sm.SummaryID > latest.SummaryID {
+			latest, found = sm, true
+		}
+	}
+	if !found {
+		return store.ConversationSummary{}, sql.ErrNoRows
+	}
+	return latest, nil
+}
+
+func (s *Store) InsertSummary(ctx context.Context, conversationID int, content string, coveredMessageID, tokenTotal int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.newID()
+	s.data.summaries[id] = store.ConversationSummary{
+		SummaryID:        id,
+		ConversationID:   conversationID,
+		Content:          content,
+		CoveredMessageID: coveredMessageID,
+		TokenTotal:       tokenTotal,
+	}
+	return id, nil
+}
@@ -0,0 +1,142 @@
+// Package memstore 提供 store.Store 的内存实现，便于在没有数据库的环境下测试 service。
+package memstore
+
+import (
+	"context"
+	"sync"
+
+	"backend/internal/store"
+)
+
+type user struct {
+	store.User
+	Password      string
+	ReservedQuota int
+}
+
+type conversation struct {
+	store.ConversationInfo
+	UserID              int
+	SystemPrompt        *int
+	SystemPromptContent *string
+}
+
+type message struct {
+	store.MessageRow
+	ConversationID int
+}
+
+type attachment struct {
+	store.AttachmentInfo
+	MessageID int
+}
+
+type refreshToken struct {
+	store.RefreshToken
+	TokenHash string
+}
+
+type passwordReset struct {
+	store.PasswordResetToken
+	TokenHash string
+}
+
+// state 内存中的全部数据，事务回滚时整体恢复。
+type state struct {
+	users          map[int]user
+	presets        map[int]store.PromptPreset
+	conversations  map[int]conversation
+	messages       map[int]message
+	attachments    map[int]attachment
+	summaries      map[int]store.ConversationSummary
+	refreshTokens  map[int]refreshToken
+	passwordResets map[int]passwordReset
+	nextID         int
+}
+
+func (st *state) clone() *state {
+	out := &state{
+		users:          make(map[int]user, len(st.users)),
+		presets:        make(map[int]store.PromptPreset, len(st.presets)),
+		conversations:  make(map[int]conversation, len(st.conversations)),
+		messages:       make(map[int]message, len(st.messages)),
+		attachments:    make(map[int]attachment, len(st.attachments)),
+		summaries:      make(map[int]store.ConversationSummary, len(st.summaries)),
+		refreshTokens:  make(map[int]refreshToken, len(st.refreshTokens)),
+		passwordResets: make(map[int]passwordReset, len(st.passwordResets)),
+		nextID:         st.nextID,
+	}
+	for k, v := range st.users {
+		out.users[k] = v
+	}
+	for k, v := range st.presets {
+		out.presets[k] = v
+	}
+	for k, v := range st.conversations {
+		out.conversations[k] = v
+	}
+	for k, v := range st.messages {
+		out.messages[k] = v
+	}
+	for k, v := range st.attachments {
+		out.attachments[k] = v
+	}
+	for k, v := range st.summaries {
+		out.summaries[k] = v
+	}
+	for k, v := range st.refreshTokens {
+		out.refreshTokens[k] = v
+	}
+	for k, v := range st.passwordResets {
+		out.passwordResets[k] = v
+	}
+	return out
+}
+
+// Store store.Store 的内存实现，可并发使用。
+//
+// 事务串行执行：WithTx 开始时对数据做快照，fn 返回错误时整体恢复；
+// 事务期间其他 goroutine 的非事务写入在回滚时会一并丢失，仅适用于测试。
+type Store struct {
+	mu   sync.Mutex
+	txMu sync.Mutex
+	data *state
+}
+
+var _ store.Store = (*Store)(nil)
+
+// New 创建空的内存 Store。
+func New() *Store {
+	return &Store{data: (&state{}).clone()}
+}
+
+type txKey struct{}
+
+// WithTx 串行执行 fn，fn 返回错误时回滚到执行前的快照。已处于事务中时直接复用外层事务。
+func (s *Store) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(txKey{}) == s {
+		return fn(ctx)
+	}
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+
+	s.mu.Lock()
+	snapshot := s.data.clone()
+	s.mu.Unlock()
+
+	ctx, runHooks := store.BeginTxHooks(ctx)
+	if err := fn(context.WithValue(ctx, txKey{}, s)); err != nil {
+		s.mu.Lock()
+		s.data = snapshot
+		s.mu.Unlock()
+		return err
+	}
+	runHooks()
+	return nil
+}
+
+// newID 分配自增 ID，调用方需持有 s.mu。
+func (s *Store) newID() int {
+	s.data.nextID++
+	return s.data.nextID
+}
@@ -0,0 +1,208 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"backend/internal/store"
+)
+
+// conversationMessages 按创建顺序返回用户会话中的消息，调用方需持有 s.mu。
+func (s *Store) conversationMessages(userID, conversationID int) []store.MessageRow {
+	out := make([]store.MessageRow, 0)
+	c, ok := s.data.conversations[conversationID]
+	if !ok || c.UserID != userID || c.Status == store.ConversationStatusDeleted {
+		return out
+	}
+	for _, m := range s.data.messages {
+		if m.ConversationID == conversationID {
+			out = append(out, m.MessageRow)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].MessageID < out[j].MessageID })
+	return out
+}
+
+// ownsMessage 判断消息是否属于用户，调用方需持有 s.mu。
+func (s *Store) ownsMessage(userID, messageID int) bool {
+	m, ok := s.data.messages[messageID]
+	if !ok {
+		return false
+	}
+	c, ok := s.data.conversations[m.ConversationID]
+	return ok && c.UserID == userID
+}
+
+func messageIDs(items []store.MessageRow) []int {
+	ids := make([]int, 0, len(items))
+	for _, m := range items {
+		ids = append(ids, m.MessageID)
+	}
+	return ids
+}
+
+func (s *Store) ListAllMessages(ctx context.Context, userID, conversationID int) ([]store.MessageRow, []int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items := s.conversationMessages(userID, conversationID)
+	return items, messageIDs(items), nil
+}
+
+func (s *Store) GetMessageContent(ctx context.Context, userID, messageID int) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ownsMessage(userID, messageID) {
+		return "", sql.ErrNoRows
+	}
+	m := s.data.messages[messageID]
+	if s.data.conversations[m.ConversationID].Status == store.ConversationStatusDeleted {
+		return "", sql.ErrNoRows
+	}
+	return m.Content, nil
+}
+
+func (s *Store) InsertMessage(ctx context.Context, conversationID, parentID int, senderType int, contentType, content string, promptTokens, completionTokens int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.newID()
+	s.data.messages[id] = message{
+		MessageRow: store.MessageRow{
+			MessageID:        id,
+			ParentID:         parentID,
+			SenderType:       senderType,
+			ContentType:      contentType,
+			Content:          content,
+			Status:           store.MessageStatusCompleted,
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+			TokenTotal:       promptTokens + completionTokens,
+		},
+		ConversationID: conversationID,
+	}
+	return id, nil
+}
+
+func (s *Store) CreateUploadMessage(ctx context.Context, conversationID int) (int, error) {
+	return s.InsertMessage(ctx, conversationID, 0, store.SenderSystem, "FILE", "UPLOAD", 0, 0)
+}
+
+func (s *Store) SetMessageStatus(ctx context.Context, messageID int, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.data.messages[messageID]
+	if !ok {
+		return nil
+	}
+	m.Status = status
+	s.data.messages[messageID] = m
+	return nil
+}
+
+func (s *Store) SetActiveMessage(ctx context.Context, conversationID, messageID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.data.conversations[conversationID]
+	if !ok {
+		return nil
+	}
+	c.ActiveMessageID = messageID
+	s.data.conversations[conversationID] = c
+	return nil
+}
+
+func (s *Store) CreateAttachment(ctx context.Context, messageID int, attachmentType, mimeType, storageType, urlOrPath string, duration *float64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.newID()
+	a := attachment{
+		AttachmentInfo: store.AttachmentInfo{
+			AttachmentID:   id,
+			AttachmentType: attachmentType,
+			MimeType:       mimeType,
+			StorageType:    storageType,
+			URLOrPath:      urlOrPath,
+		},
+		MessageID: messageID,
+	}
+	if duration != nil {
+		val := *duration
+		a.DurationMS = &val
+	}
+	s.data.attachments[id] = a
+	return id, nil
+}
+
+func (s *Store) AttachFilesToMessage(ctx context.Context, userID, messageID int, attachmentIDs []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range attachmentIDs {
+		a, ok := s.data.attachments[id]
+		if !ok || !s.ownsMessage(userID, a.MessageID) {
+			continue
+		}
+		a.MessageID = messageID
+		s.data.attachments[id] = a
+	}
+	return nil
+}
+
+func (s *Store) CopyAttachmentsToMessage(ctx context.Context, userID, messageID int, attachmentIDs []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := append([]int(nil), attachmentIDs...)
+	sort.Ints(ids)
+	for _, id := range ids {
+		a, ok := s.data.attachments[id]
+		if !ok || !s.ownsMessage(userID, a.MessageID) {
+			continue
+		}
+		a.AttachmentID = s.newID()
+		a.MessageID = messageID
+		if a.DurationMS != nil {
+			val := *a.DurationMS
+			a.DurationMS = &val
+		}
+		s.data.attachments[a.AttachmentID] = a
+	}
+	return nil
+}
+
+func (s *Store) LoadAttachmentsMap(ctx context.Context, messageIDs []int) (map[int][]store.AttachmentInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wanted := make(map[int]struct{}, len(messageIDs))
+	for _, id := range messageIDs {
+		wanted[id] = struct{}{}
+	}
+	out := make(map[int][]store.AttachmentInfo)
+	for _, a := range s.sortedAttachments() {
+		if _, ok := wanted[a.MessageID]; ok {
+			out[a.MessageID] = append(out[a.MessageID], a.AttachmentInfo)
+		}
+	}
+	return out, nil
+}
+
+func (s *Store) LoadAttachmentsByIDs(ctx context.Context, userID int, attachmentIDs []int) ([]store.AttachmentInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items := make([]store.AttachmentInfo, 0, len(attachmentIDs))
+	for _, id := range attachmentIDs {
+		a, ok := s.data.attachments[id]
+		if ok && s.ownsMessage(userID, a.MessageID) {
+			items = append(items, a.AttachmentInfo)
+		}
+	}
+	return items, nil
+}
+
+// sortedAttachments 按 ID 升序返回全部附件，调用方需持有 s.mu。
+func (s *Store) sortedAttachments() []attachment {
+	out := make([]attachment, 0, len(s.data.attachments))
+	for _, a := range s.data.attachments {
+		out = append(out, a)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].AttachmentID < out[j].AttachmentID })
+	return out
+}
@@ -0,0 +1,59 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"backend/internal/store"
+)
+
+func (s *Store) CreatePasswordResetToken(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.newID()
+	s.data.passwordResets[id] = passwordReset{
+		PasswordResetToken: store.PasswordResetToken{
+			TokenID:   id,
+			UserID:    userID,
+			ExpiresAt: expiresAt,
+		},
+		TokenHash: tokenHash,
+	}
+	return id, nil
+}
+
+func (s *Store) GetPasswordResetTokenByHash(ctx context.Context, tokenHash string) (store.PasswordResetToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.data.passwordResets {
+		if t.TokenHash == tokenHash {
+			return t.PasswordResetToken, nil
+		}
+	}
+	return store.PasswordResetToken{}, sql.ErrNoRows
+}
+
+func (s *Store) MarkPasswordResetTokenUsed(ctx context.Context, tokenID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.data.passwordResets[tokenID]
+	if !ok || t.Used {
+		return false, nil
+	}
+	t.Used = true
+	s.data.passwordResets[tokenID] = t
+	return true, nil
+}
+
+func (s *Store) InvalidateUserPasswordResetTokens(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, t := range s.data.passwordResets {
+		if t.UserID == userID && !t.Used {
+			t.Used = true
+			s.data.passwordResets[id] = t
+		}
+	}
+	return nil
+}
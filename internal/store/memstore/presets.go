@@ -0,0 +1,53 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"backend/internal/store"
+)
+
+func (s *Store) ListPromptPresets(ctx context.Context) ([]store.PromptPreset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]store.PromptPreset, 0, len(s.data.presets))
+	for _, p := range s.data.presets {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].PromptPresetID > list[j].PromptPresetID })
+	return list, nil
+}
+
+func (s *Store) GetPromptPreset(ctx context.Context, id int) (store.PromptPreset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.data.presets[id]
+	if !ok {
+		return store.PromptPreset{}, sql.ErrNoRows
+	}
+	return p, nil
+}
+
+func (s *Store) CreatePromptPreset(ctx context.Context, name, description, content string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.newID()
+	s.data.presets[id] = store.PromptPreset{
+		PromptPresetID: id,
+		Name:           name,
+		Description:    description,
+		Content:        content,
+	}
+	return nil
+}
+
+func (s *Store) DeletePromptPreset(ctx context.Context, id int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.data.presets[id]; !ok {
+		return false, nil
+	}
+	delete(s.data.presets, id)
+	return true, nil
+}
@@ -0,0 +1,77 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"backend/internal/store"
+)
+
+func (s *Store) ListPurgeableConversations(ctx context.Context, deletedBefore time.Time, limit int) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	candidates := make([]conversation, 0)
+	for _, c := range s.data.conversations {
+		if c.Status == store.ConversationStatusDeleted && c.DeletedAt != nil && c.DeletedAt.Before(deletedBefore) {
+			candidates = append(candidates, c)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if !candidates[i].DeletedAt.Equal(*candidates[j].DeletedAt) {
+			return candidates[i].DeletedAt.Before(*candidates[j].DeletedAt)
+		}
+		return candidates[i].ConversationID < candidates[j].ConversationID
+	})
+	ids := make([]int, 0, len(candidates))
+	for _, c := range candidates {
+		if len(ids) >= limit {
+			break
+		}
+		ids = append(ids, c.ConversationID)
+	}
+	return ids, nil
+}
+
+func (s *Store) PurgeConversation(ctx context.Context, conversationID int) ([]store.AttachmentInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.data.conversations[conversationID]
+	if !ok || c.Status != store.ConversationStatusDeleted {
+		return nil, sql.ErrNoRows
+	}
+	delete(s.data.conversations, conversationID)
+	attachments := make([]store.AttachmentInfo, 0)
+	for id, m := range s.data.messages {
+		if m.ConversationID != conversationID {
+			continue
+		}
+		for aid, a := range s.data.attachments {
+			if a.MessageID == id {
+				attachments = append(attachments, a.AttachmentInfo)
+				delete(s.data.attachments, aid)
+			}
+		}
+		delete(s.data.messages, id)
+	}
+	for id, sm := range s.data.summaries {
+		if sm.ConversationID == conversationID {
+			delete(s.data.summaries, id)
+		}
+	}
+	sort.Slice(attachments, func(i, j int) bool { return attachments[i].AttachmentID < attachments[j].AttachmentID })
+	return attachments, nil
+}
+
+func (s *Store) CountAttachmentsByLocation(ctx context.Context, storageType, urlOrPath string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, a := range s.data.attachments {
+		if a.StorageType == storageType && a.URLOrPath == urlOrPath {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,77 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"backend/internal/store"
+)
+
+func (s *Store) CreateRefreshToken(ctx context.Context, userID int, familyID, tokenHash string, expiresAt time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.newID()
+	s.data.refreshTokens[id] = refreshToken{
+		RefreshToken: store.RefreshToken{
+			TokenID:   id,
+			UserID:    userID,
+			FamilyID:  familyID,
+			ExpiresAt: expiresAt,
+		},
+		TokenHash: tokenHash,
+	}
+	return id, nil
+}
+
+func (s *Store) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (store.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.data.refreshTokens {
+		if t.TokenHash == tokenHash {
+			return t.RefreshToken, nil
+		}
+	}
+	return store.RefreshToken{}, sql.ErrNoRows
+}
+
+func (s *Store) MarkRefreshTokenUsed(ctx context.Context, tokenID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.data.refreshTokens[tokenID]
+	if !ok || t.Used || t.Revoked {
+		return false, nil
+	}
+	t.Used = true
+	s.data.refreshTokens[tokenID] = t
+	return true, nil
+}
+
+func (s *Store) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, t := range s.data.refreshTokens {
+		if t.FamilyID == familyID {
+			t.Revoked = true
+			s.data.refreshTokens[id] = t
+		}
+	}
+	return nil
+}
+
+func (s *Store) RevokeUserRefreshTokens(ctx context.Context, userID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sessions := make(map[string]struct{})
+	for id, t := range s.data.refreshTokens {
+		if t.UserID != userID || t.Revoked {
+			continue
+		}
+		if !t.Used {
+			sessions[t.FamilyID] = struct{}{}
+		}
+		t.Revoked = true
+		s.data.refreshTokens[id] = t
+	}
+	return len(sessions), nil
+}
@@ -0,0 +1,224 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"backend/internal/store"
+)
+
+// findUser 按用户名查找用户，调用方需持有 s.mu。
+func (s *Store) findUser(username string) (user, bool) {
+	for _, u := range s.data.users {
+		if u.Username == username {
+			return u, true
+		}
+	}
+	return user{}, false
+}
+
+func (s *Store) GetUserPassword(ctx context.Context, username string) (int, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.findUser(username)
+	if !ok {
+		return 0, "", sql.ErrNoRows
+	}
+	return u.UserID, u.Password, nil
+}
+
+func (s *Store) GetUserByUsername(ctx context.Context, username string) (store.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.findUser(username)
+	if !ok {
+		return store.User{}, sql.ErrNoRows
+	}
+	return u.User, nil
+}
+
+func (s *Store) GetUserByID(ctx context.Context, userID int) (store.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.data.users[userID]
+	if !ok {
+		return store.User{}, sql.ErrNoRows
+	}
+	return u.User, nil
+}
+
+func (s *Store) CreateUser(ctx context.Context, username, password, nickname, role string, total, used int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.findUser(username); ok {
+		return 0, fmt.Errorf("duplicate username: %s", username)
+	}
+	id := s.newID()
+	s.data.users[id] = user{
+		User: store.User{
+			UserID:     id,
+			Username:   username,
+			Nickname:   nickname,
+			Role:       role,
+			TotalQuota: int(total),
+			UsedQuota:  int(used),
+		},
+		Password: password,
+	}
+	return id, nil
+}
+
+func (s *Store) CreateUserWithQuota(ctx context.Context, username, password, nickname, role string, total, used int) (store.User, error) {
+	id, err := s.CreateUser(ctx, username, password, nickname, role, int64(total), int64(used))
+	if err != nil {
+		return store.User{}, err
+	}
+	return s.GetUserByID(ctx, id)
+}
+
+func (s *Store) UpdateUserPassword(ctx context.Context, username, newPassword string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.findUser(username)
+	if !ok {
+		return nil
+	}
+	u.Password = newPassword
+	s.data.users[u.UserID] = u
+	return nil
+}
+
+func (s *Store) GetUserAuthState(ctx context.Context, userID int) (store.UserAuthState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.data.users[userID]
+	if !ok {
+		return store.UserAuthState{}, sql.ErrNoRows
+	}
+	return store.UserAuthState{Role: u.Role, TokenVersion: u.TokenVersion, MustChangePassword: u.MustChangePassword}, nil
+}
+
+func (s *Store) BumpUserTokenVersion(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u, ok := s.data.users[userID]; ok {
+		u.TokenVersion++
+		s.data.users[userID] = u
+	}
+	return nil
+}
+
+func (s *Store) SetUserMustChangePassword(ctx context.Context, userID int, must bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u, ok := s.data.users[userID]; ok {
+		u.MustChangePassword = must
+		s.data.users[userID] = u
+	}
+	return nil
+}
+
+func (s *Store) CountUsersByUsername(ctx context.Context, username string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.findUser(username); ok {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (s *Store) ListUsers(ctx context.Context, page, pageSize int) ([]store.User, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all := make([]store.User, 0, len(s.data.users))
+	for _, u := range s.data.users {
+		all = append(all, u.User)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].UserID > all[j].UserID })
+	return paginate(all, page, pageSize), len(all), nil
+}
+
+func (s *Store) SetUserQuota(ctx context.Context, userID int, quota int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.data.users[userID]
+	if !ok {
+		return false, nil
+	}
+	u.TotalQuota = quota
+	if u.UsedQuota > quota {
+		u.UsedQuota = quota
+	}
+	s.data.users[userID] = u
+	return true, nil
+}
+
+func (s *Store) DeleteUser(ctx context.Context, userID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.data.users[userID]; !ok {
+		return false, nil
+	}
+	delete(s.data.users, userID)
+	return true, nil
+}
+
+func (s *Store) GetUserQuotaBalance(ctx context.Context, userID int) (int, int, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.data.users[userID]
+	if !ok {
+		return 0, 0, 0, sql.ErrNoRows
+	}
+	return u.TotalQuota, u.UsedQuota, u.ReservedQuota, nil
+}
+
+func (s *Store) ReserveQuota(ctx context.Context, userID, amount int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.data.users[userID]
+	if !ok || u.UsedQuota+u.ReservedQuota+amount > u.TotalQuota {
+		return false, nil
+	}
+	u.ReservedQuota += amount
+	s.data.users[userID] = u
+	return true, nil
+}
+
+func (s *Store) SettleQuota(ctx context.Context, userID, reserved, actual int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.data.users[userID]
+	if !ok {
+		return nil
+	}
+	u.ReservedQuota -= reserved
+	if u.ReservedQuota < 0 {
+		u.ReservedQuota = 0
+	}
+	if u.UsedQuota+actual <= u.TotalQuota {
+		u.UsedQuota += actual
+	} else if u.UsedQuota < u.TotalQuota {
+		u.UsedQuota = u.TotalQuota
+	}
+	s.data.users[userID] = u
+	return nil
+}
+
+// paginate 返回第 page 页（从 1 开始）的切片。
+func paginate[T any](items []T, page, pageSize int) []T {
+	offset := (page - 1) * pageSize
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(items) {
+		return []T{}
+	}
+	end := offset + pageSize
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[offset:end]
+}
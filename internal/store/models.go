@@ -1,5 +1,7 @@
 package store
 
+import "time"
+
 // User 用户信息。
 type User struct {
 	UserID     int    `json:"user_id"`
@@ -8,14 +10,34 @@ type User struct {
 	Role       string `json:"role"`
 	TotalQuota int    `json:"total_quota"`
 	UsedQuota  int    `json:"used_quota"`
+	// MustChangePassword 为 true 时用户须先修改密码才能访问其他接口。
+	MustChangePassword bool `json:"must_change_password"`
+	// TokenVersion 令牌版本，递增后此前签发的访问令牌全部失效。
+	TokenVersion int `json:"-"`
+}
+
+// UserAuthState 鉴权时按用户ID读取的状态。
+type UserAuthState struct {
+	Role               string
+	TokenVersion       int
+	MustChangePassword bool
 }
 
 // ConversationInfo 对话概要信息。
 type ConversationInfo struct {
 	ConversationID int    `json:"conversation_id"`
 	Title          string `json:"title"`
-	Status         string `json:"status"`
-	LLMModel       string `json:"llm_model"`
+	// TitleManual 标题由用户指定；为 false 时标题可由模型自动生成。
+	TitleManual bool   `json:"title_manual"`
+	Status      string `json:"status"`
+	LLMModel    string `json:"llm_model"`
+	// ActiveMessageID 当前分支的末条消息，0 表示尚无消息。
+	ActiveMessageID int `json:"-"`
+	// ForkedFromConversationID/ForkedFromMessageID 分叉来源会话与分叉点消息，非分叉会话为 0。
+	ForkedFromConversationID int `json:"forked_from_conversation_id,omitempty"`
+	ForkedFromMessageID      int `json:"forked_from_message_id,omitempty"`
+	// DeletedAt 删除时间，仅 DELETED 状态的会话有值。
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 // PromptPreset 提示词预设。
@@ -28,11 +50,16 @@ type PromptPreset struct {
 
 // MessageRow 消息基础字段。
 type MessageRow struct {
-	MessageID   int
-	SenderType  int
-	ContentType string
-	Content     string
-	TokenTotal  int
+	MessageID int
+	// ParentID 同一分支上的上一条消息，0 表示会话开头；重新生成或编辑产生的版本与原消息同父。
+	ParentID         int
+	SenderType       int
+	ContentType      string
+	Content          string
+	Status           string
+	PromptTokens     int
+	CompletionTokens int
+	TokenTotal       int
 }
 
 // AttachmentInfo 附件信息。
@@ -44,3 +71,31 @@ type AttachmentInfo struct {
 	URLOrPath      string   `json:"url_or_path"`
 	DurationMS     *float64 `json:"duration_ms,omitempty"`
 }
+
+// ConversationSummary 会话滚动摘要，替代 CoveredMessageID 及之前的消息进入模型上下文。
+type ConversationSummary struct {
+	SummaryID        int
+	ConversationID   int
+	Content          string
+	CoveredMessageID int
+	TokenTotal       int
+}
+
+// RefreshToken 刷新令牌记录，令牌明文不落库，只保存其 SHA-256 哈希。
+// 同一次登录轮换出的令牌共享 FamilyID，用于复用检测时整体吊销。
+type RefreshToken struct {
+	TokenID   int
+	UserID    int
+	FamilyID  string
+	ExpiresAt time.Time
+	Used      bool
+	Revoked   bool
+}
+
+// PasswordResetToken 管理员签发的一次性重置密码令牌，只保存其 SHA-256 哈希。
+type PasswordResetToken struct {
+	TokenID   int
+	UserID    int
+	ExpiresAt time.Time
+	Used      bool
+}
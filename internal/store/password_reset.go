@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// CreatePasswordResetToken 保存重置令牌哈希并返回 ID。
+func (s *SQLStore) CreatePasswordResetToken(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) (int, error) {
+	dbx, err := s.conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	res, err := dbx.ExecContext(ctx, `
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
+		VALUES (?, ?, ?)
+	`, userID, tokenHash, expiresAt.UTC())
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// GetPasswordResetTokenByHash 按哈希查找重置令牌，不存在时返回 sql.ErrNoRows。
+func (s *SQLStore) GetPasswordResetTokenByHash(ctx context.Context, tokenHash string) (PasswordResetToken, error) {
+	dbx, err := s.conn(ctx)
+	if err != nil {
+		return PasswordResetToken{}, err
+	}
+	var t PasswordResetToken
+	row := dbx.QueryRowContext(ctx, `
+		SELECT token_id, user_id, expires_at, CASE WHEN used_at IS NULL THEN 0 ELSE 1 END
+		FROM password_reset_tokens
+		WHERE token_hash = ?
+	`, tokenHash)
+	if err := row.Scan(&t.TokenID, &t.UserID, &t.ExpiresAt, &t.Used); err != nil {
+		return PasswordResetToken{}, err
+	}
+	return t, nil
+}
+
+// MarkPasswordResetTokenUsed 原子地将未使用的重置令牌标记为已使用，返回是否成功。
+func (s *SQLStore) MarkPasswordResetTokenUsed(ctx context.Context, tokenID int) (bool, error) {
+	dbx, err := s.conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	res, err := dbx.ExecContext(ctx, `
+		UPDATE password_reset_tokens SET used_at = ?
+		WHERE token_id = ? AND used_at IS NULL
+	`, time.Now().UTC(), tokenID)
+	if err != nil {
+		return false, err
+	}
+	affected, _ := res.RowsAffected()
+	return affected > 0, nil
+}
+
+// InvalidateUserPasswordResetTokens 作废用户尚未使用的全部重置令牌。
+func (s *SQLStore) InvalidateUserPasswordResetTokens(ctx context.Context, userID int) error {
+	dbx, err := s.conn(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = dbx.ExecContext(ctx, `
+		UPDATE password_reset_tokens SET used_at = ?
+		WHERE user_id = ? AND used_at IS NULL
+	`, time.Now().UTC(), userID)
+	return err
+}
@@ -3,8 +3,8 @@ package store
 import "context"
 
 // ListPromptPresets 获取提示词列表。
-func ListPromptPresets(ctx context.Context) ([]PromptPreset, error) {
-	dbx, err := GetDB()
+func (s *SQLStore) ListPromptPresets(ctx context.Context) ([]PromptPreset, error) {
+	dbx, err := s.conn(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -32,9 +32,27 @@ func ListPromptPresets(ctx context.Context) ([]PromptPreset, error) {
 	return list, nil
 }
 
+// GetPromptPreset 获取指定提示词。
+func (s *SQLStore) GetPromptPreset(ctx context.Context, id int) (PromptPreset, error) {
+	dbx, err := s.conn(ctx)
+	if err != nil {
+		return PromptPreset{}, err
+	}
+	var p PromptPreset
+	row := dbx.QueryRowContext(ctx, `
+		SELECT prompt_preset_id, name, description, content
+		FROM prompt_presets
+		WHERE prompt_preset_id = ?
+	`, id)
+	if err := row.Scan(&p.PromptPresetID, &p.Name, &p.Description, &p.Content); err != nil {
+		return PromptPreset{}, err
+	}
+	return p, nil
+}
+
 // CreatePromptPreset 创建提示词。
-func CreatePromptPreset(ctx context.Context, name, description, content string) error {
-	dbx, err := GetDB()
+func (s *SQLStore) CreatePromptPreset(ctx context.Context, name, description, content string) error {
+	dbx, err := s.conn(ctx)
 	if err != nil {
 		return err
 	}
@@ -46,8 +64,8 @@ func CreatePromptPreset(ctx context.Context, name, description, content string)
 }
 
 // DeletePromptPreset 删除提示词，返回是否命中。
-func DeletePromptPreset(ctx context.Context, id int) (bool, error) {
-	dbx, err := GetDB()
+func (s *SQLStore) DeletePromptPreset(ctx context.Context, id int) (bool, error) {
+	dbx, err := s.conn(ctx)
 	if err != nil {
 		return false, err
 	}
@@ -0,0 +1,58 @@
+package store
+
+import "context"
+
+// GetUserQuotaBalance 获取用户总额度、已用额度与预占额度。
+func (s *SQLStore) GetUserQuotaBalance(ctx context.Context, userID int) (int, int, int, error) {
+	dbx, err := s.conn(ctx)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	var total, used, reserved int
+	if err := dbx.QueryRowContext(ctx, `
+		SELECT total_quota, used_quota, reserved_quota
+		FROM users
+		WHERE user_id = ? AND status = 1
+	`, userID).Scan(&total, &used, &reserved); err != nil {
+		return 0, 0, 0, err
+	}
+	return total, used, reserved, nil
+}
+
+// ReserveQuota 原子地预占额度，仅当已用与预占之和加上 amount 不超过总额度时成功。
+func (s *SQLStore) ReserveQuota(ctx context.Context, userID, amount int) (bool, error) {
+	dbx, err := s.conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	res, err := dbx.ExecContext(ctx, `
+		UPDATE users
+		SET reserved_quota = reserved_quota + ?
+		WHERE user_id = ? AND status = 1 AND used_quota + reserved_quota + ? <= total_quota
+	`, amount, userID, amount)
+	if err != nil {
+		return false, err
+	}
+	affected, _ := res.RowsAffected()
+	return affected > 0, nil
+}
+
+// SettleQuota 结算预占：释放 reserved 并按实际用量 actual 计入已用额度。实际用量可能超出预占，
+// 计入后的已用额度不超过总额度（已超出的旧数据保持不变），并发的多轮对话也不会透支。
+func (s *SQLStore) SettleQuota(ctx context.Context, userID, reserved, actual int) error {
+	dbx, err := s.conn(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = dbx.ExecContext(ctx, `
+		UPDATE users
+		SET reserved_quota = CASE WHEN reserved_quota > ? THEN reserved_quota - ? ELSE 0 END,
+		    used_quota = CASE
+		        WHEN used_quota + ? <= total_quota THEN used_quota + ?
+		        WHEN used_quota > total_quota THEN used_quota
+		        ELSE total_quota
+		    END
+		WHERE user_id = ?
+	`, reserved, reserved, actual, actual, userID)
+	return err
+}
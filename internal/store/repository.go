@@ -0,0 +1,116 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// UserRepository 用户与额度的存取。
+type UserRepository interface {
+	GetUserPassword(ctx context.Context, username string) (int, string, error)
+	GetUserByUsername(ctx context.Context, username string) (User, error)
+	GetUserByID(ctx context.Context, userID int) (User, error)
+	CreateUser(ctx context.Context, username, password, nickname, role string, total, used int64) (int, error)
+	CreateUserWithQuota(ctx context.Context, username, password, nickname, role string, total, used int) (User, error)
+	UpdateUserPassword(ctx context.Context, username, newPassword string) error
+	GetUserAuthState(ctx context.Context, userID int) (UserAuthState, error)
+	BumpUserTokenVersion(ctx context.Context, userID int) error
+	SetUserMustChangePassword(ctx context.Context, userID int, must bool) error
+	CountUsersByUsername(ctx context.Context, username string) (int, error)
+	ListUsers(ctx context.Context, page, pageSize int) ([]User, int, error)
+	SetUserQuota(ctx context.Context, userID int, quota int) (bool, error)
+	DeleteUser(ctx context.Context, userID int) (bool, error)
+	GetUserQuotaBalance(ctx context.Context, userID int) (int, int, int, error)
+	ReserveQuota(ctx context.Context, userID, amount int) (bool, error)
+	SettleQuota(ctx context.Context, userID, reserved, actual int) error
+}
+
+// ConversationRepository 会话及其滚动摘要的存取。
+type ConversationRepository interface {
+	GetConversation(ctx context.Context, conversationID int, userID int) (ConversationInfo, error)
+	CreateConversation(ctx context.Context, userID int, title, llmModel string, systemPrompt sql.NullInt64, systemPromptContent sql.NullString) (ConversationInfo, error)
+	CreateForkedConversation(ctx context.Context, userID, sourceConversationID, forkedFromMessageID int, title string) (ConversationInfo, error)
+	GetConversationSystemPrompt(ctx context.Context, conversationID int) (string, error)
+	RenameConversation(ctx context.Context, conversationID, userID int, title string) (bool, error)
+	SetGeneratedTitle(ctx context.Context, conversationID int, title string) (bool, error)
+	DeleteConversation(ctx context.Context, conversationID, userID int) (bool, error)
+	ArchiveConversation(ctx context.Context, conversationID, userID int) (bool, error)
+	RestoreConversation(ctx context.Context, conversationID, userID int) (bool, error)
+	ListConversationsByUser(ctx context.Context, userID int, status string) ([]ConversationInfo, error)
+	GetOrCreateUploadConversation(ctx context.Context, userID int, llmModel string) (int, error)
+	GetLatestSummary(ctx context.Context, conversationID int, messageIDs []int) (ConversationSummary, error)
+	InsertSummary(ctx context.Context, conversationID int, content string, coveredMessageID, tokenTotal int) (int, error)
+}
+
+// MessageRepository 消息的存取。消息通过 parent_id 组成树，会话记录当前分支的末条消息。
+type MessageRepository interface {
+	ListAllMessages(ctx context.Context, userID, conversationID int) ([]MessageRow, []int, error)
+	GetMessageContent(ctx context.Context, userID, messageID int) (string, error)
+	InsertMessage(ctx context.Context, conversationID, parentID int, senderType int, contentType, content string, promptTokens, completionTokens int) (int, error)
+	CreateUploadMessage(ctx context.Context, conversationID int) (int, error)
+	SetMessageStatus(ctx context.Context, messageID int, status string) error
+	SetActiveMessage(ctx context.Context, conversationID, messageID int) error
+}
+
+// AttachmentRepository 消息附件的存取。
+type AttachmentRepository interface {
+	CreateAttachment(ctx context.Context, messageID int, attachmentType, mimeType, storageType, urlOrPath string, duration *float64) (int, error)
+	AttachFilesToMessage(ctx context.Context, userID, messageID int, attachmentIDs []int) error
+	CopyAttachmentsToMessage(ctx context.Context, userID, messageID int, attachmentIDs []int) error
+	LoadAttachmentsMap(ctx context.Context, messageIDs []int) (map[int][]AttachmentInfo, error)
+	LoadAttachmentsByIDs(ctx context.Context, userID int, attachmentIDs []int) ([]AttachmentInfo, error)
+}
+
+// RetentionRepository 已删除会话在保留期后的彻底清除。
+type RetentionRepository interface {
+	ListPurgeableConversations(ctx context.Context, deletedBefore time.Time, limit int) ([]int, error)
+	PurgeConversation(ctx context.Context, conversationID int) ([]AttachmentInfo, error)
+	CountAttachmentsByLocation(ctx context.Context, storageType, urlOrPath string) (int, error)
+}
+
+// PresetRepository 提示词预设的存取。
+type PresetRepository interface {
+	ListPromptPresets(ctx context.Context) ([]PromptPreset, error)
+	GetPromptPreset(ctx context.Context, id int) (PromptPreset, error)
+	CreatePromptPreset(ctx context.Context, name, description, content string) error
+	DeletePromptPreset(ctx context.Context, id int) (bool, error)
+}
+
+// SessionRepository 刷新令牌（登录会话）的存取。
+type SessionRepository interface {
+	CreateRefreshToken(ctx context.Context, userID int, familyID, tokenHash string, expiresAt time.Time) (int, error)
+	GetRefreshTokenByHash(ctx context.Context, tokenHash string) (RefreshToken, error)
+	MarkRefreshTokenUsed(ctx context.Context, tokenID int) (bool, error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string) error
+	RevokeUserRefreshTokens(ctx context.Context, userID int) (int, error)
+}
+
+// PasswordResetRepository 一次性重置密码令牌的存取。
+type PasswordResetRepository interface {
+	CreatePasswordResetToken(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) (int, error)
+	GetPasswordResetTokenByHash(ctx context.Context, tokenHash string) (PasswordResetToken, error)
+	MarkPasswordResetTokenUsed(ctx context.Context, tokenID int) (bool, error)
+	InvalidateUserPasswordResetTokens(ctx context.Context, userID int) error
+}
+
+// Transactor 在单个事务中执行 fn，fn 内应使用传入的 ctx 调用仓储方法；
+// 实现需通过 BeginTxHooks 支持 AfterCommit。
+type Transactor interface {
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Store 聚合各仓储接口，注入到 service 使用。
+type Store interface {
+	UserRepository
+	ConversationRepository
+	MessageRepository
+	AttachmentRepository
+	RetentionRepository
+	PresetRepository
+	SessionRepository
+	PasswordResetRepository
+	Transactor
+}
+
+var _ Store = (*SQLStore)(nil)
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// ListPurgeableConversations 获取删除时间早于 deletedBefore 的会话ID，按删除时间升序，最多 limit 条。
+func (s *SQLStore) ListPurgeableConversations(ctx context.Context, deletedBefore time.Time, limit int) ([]int, error) {
+	dbx, err := s.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := dbx.QueryContext(ctx, `
+		SELECT conversation_id
+		FROM conversations
+		WHERE status = 'DELETED' AND deleted_at < ?
+		ORDER BY deleted_at ASC, conversation_id ASC
+		LIMIT ?
+	`, utcDateTime(deletedBefore), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// PurgeConversation 彻底删除已删除的会话及其消息、附件记录与摘要，返回被删除的附件以便清理文件；
+// 会话不存在或已被恢复时返回 sql.ErrNoRows。多条语句需由调用方置于同一事务中。
+func (s *SQLStore) PurgeConversation(ctx context.Context, conversationID int) ([]AttachmentInfo, error) {
+	dbx, err := s.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := dbx.ExecContext(ctx, `
+		DELETE FROM conversations
+		WHERE conversation_id = ? AND status = 'DELETED'
+	`, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	rows, err := dbx.QueryContext(ctx, `
+		SELECT a.attachment_id, a.attachment_type, a.mime_type, a.storage_type, a.url_or_path
+		FROM message_attachments a
+		JOIN messages m ON a.message_id = m.message_id
+		WHERE m.conversation_id = ?
+	`, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	attachments := make([]AttachmentInfo, 0)
+	for rows.Next() {
+		var a AttachmentInfo
+		if err := rows.Scan(&a.AttachmentID, &a.AttachmentType, &a.MimeType, &a.StorageType, &a.URLOrPath); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for _, stmt := range []string{
+		`DELETE FROM message_attachments WHERE message_id IN (SELECT message_id FROM messages WHERE conversation_id = ?)`,
+		`DELETE FROM messages WHERE conversation_id = ?`,
+		`DELETE FROM conversation_summaries WHERE conversation_id = ?`,
+	} {
+		if _, err := dbx.ExecContext(ctx, stmt, conversationID); err != nil {
+			return nil, err
+		}
+	}
+	return attachments, nil
+}
+
+// CountAttachmentsByLocation 统计引用同一存储位置的附件记录数；分叉与编辑复制的附件共享文件，无引用时才可删除文件。
+func (s *SQLStore) CountAttachmentsByLocation(ctx context.Context, storageType, urlOrPath string) (int, error) {
+	dbx, err := s.conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	row := dbx.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM message_attachments
+		WHERE storage_type = ? AND url_or_path = ?
+	`, storageType, urlOrPath)
+	if err := row.Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// CreateRefreshToken 保存刷新令牌哈希并返回 ID。
+func (s *SQLStore) CreateRefreshToken(ctx context.Context, userID int, familyID, tokenHash string, expiresAt time.Time) (int, error) {
+	dbx, err := s.conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	res, err := dbx.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+		VALUES (?, ?, ?, ?)
+	`, userID, familyID, tokenHash, expiresAt.UTC())
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// GetRefreshTokenByHash 按哈希查找刷新令牌，不存在时返回 sql.ErrNoRows。
+func (s *SQLStore) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	dbx, err := s.conn(ctx)
+	if err != nil {
+		return RefreshToken{}, err
+	}
+	var t RefreshToken
+	row := dbx.QueryRowContext(ctx, `
+		SELECT token_id, user_id, family_id, expires_at,
+		       CASE WHEN used_at IS NULL THEN 0 ELSE 1 END,
+		       CASE WHEN revoked_at IS NULL THEN 0 ELSE 1 END
+		FROM refresh_tokens
+		WHERE token_hash = ?
+	`, tokenHash)
+	if err := row.Scan(&t.TokenID, &t.UserID, &t.FamilyID, &t.ExpiresAt, &t.Used, &t.Revoked); err != nil {
+		return RefreshToken{}, err
+	}
+	return t, nil
+}
+
+// MarkRefreshTokenUsed 原子地将未使用且未吊销的刷新令牌标记为已使用，返回是否成功；
+// 并发刷新时只有一个请求能成功。
+func (s *SQLStore) MarkRefreshTokenUsed(ctx context.Context, tokenID int) (bool, error) {
+	dbx, err := s.conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	res, err := dbx.ExecContext(ctx, `
+		UPDATE refresh_tokens SET used_at = ?
+		WHERE token_id = ? AND used_at IS NULL AND revoked_at IS NULL
+	`, time.Now().UTC(), tokenID)
+	if err != nil {
+		return false, err
+	}
+	affected, _ := res.RowsAffected()
+	return affected > 0, nil
+}
+
+// RevokeRefreshTokenFamily 吊销同一登录会话轮换出的全部刷新令牌。
+func (s *SQLStore) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	dbx, err := s.conn(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = dbx.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = ?
+		WHERE family_id = ? AND revoked_at IS NULL
+	`, time.Now().UTC(), familyID)
+	return err
+}
+
+// RevokeUserRefreshTokens 吊销用户的全部刷新令牌，返回吊销的会话数。
+func (s *SQLStore) RevokeUserRefreshTokens(ctx context.Context, userID int) (int, error) {
+	dbx, err := s.conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var sessions int
+	if err := dbx.QueryRowContext(ctx, `
+		SELECT COUNT(DISTINCT family_id) FROM refresh_tokens
+		WHERE user_id = ? AND revoked_at IS NULL AND used_at IS NULL
+	`, userID).Scan(&sessions); err != nil {
+		return 0, err
+	}
+	if _, err := dbx.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = ?
+		WHERE user_id = ? AND revoked_at IS NULL
+	`, time.Now().UTC(), userID); err != nil {
+		return 0, err
+	}
+	return sessions, nil
+}
@@ -0,0 +1,698 @@
+package store_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/db"
+	"backend/internal/store"
+	"backend/internal/store/memstore"
+)
+
+// newSQLiteStore 创建迁移到最新版本的进程内 sqlite SQLStore。
+func newSQLiteStore(t *testing.T) store.Store {
+	t.Helper()
+	dbx, err := db.Open(config.DatabaseConfig{Driver: "sqlite", Path: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dbx.Close() })
+	if _, err := db.MigrateUp(context.Background(), dbx, db.DialectSQLite); err != nil {
+		t.Fatal(err)
+	}
+	return store.NewSQLStore(dbx)
+}
+
+func TestSQLiteStore(t *testing.T) {
+	runStoreTests(t, newSQLiteStore)
+}
+
+// memstore 供 service 测试替代数据库，须与 SQLStore 行为一致。
+func TestMemStore(t *testing.T) {
+	runStoreTests(t, func(t *testing.T) store.Store { return memstore.New() })
+}
+
+// runStoreTests 以同一组用例验证 Store 实现，每个子测试使用新建的空存储。
+func runStoreTests(t *testing.T, newStore func(t *testing.T) store.Store) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, st store.Store)
+	}{
+		{"users", testUsers},
+		{"quota", testQuota},
+		{"presets and system prompt", testPresets},
+		{"conversation lifecycle", testConversationLifecycle},
+		{"message tree", testMessageTree},
+		{"attachments", testAttachments},
+		{"summaries and fork", testSummariesAndFork},
+		{"transactions", testTransactions},
+		{"refresh tokens", testRefreshTokens},
+		{"password reset tokens", testPasswordResetTokens},
+		{"retention purge", testRetentionPurge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+func mustCreateUser(t *testing.T, st store.Store, username string, total int) store.User {
+	t.Helper()
+	u, err := st.CreateUserWithQuota(context.Background(), username, "hash", username+"-nick", "user", total, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func mustCreateConversation(t *testing.T, st store.Store, userID int, title string) store.ConversationInfo {
+	t.Helper()
+	conv, err := st.CreateConversation(context.Background(), userID, title, "fake", sql.NullInt64{}, sql.NullString{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conv
+}
+
+func mustInsertMessage(t *testing.T, st store.Store, convID, parentID, sender int, content string) int {
+	t.Helper()
+	id, err := st.InsertMessage(context.Background(), convID, parentID, sender, "TEXT", content, 3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func testUsers(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	alice := mustCreateUser(t, st, "alice", 100)
+	bob := mustCreateUser(t, st, "bob", 50)
+
+	id, password, err := st.GetUserPassword(ctx, "alice")
+	if err != nil || id != alice.UserID || password != "hash" {
+		t.Fatalf("GetUserPassword = %d %q %v", id, password, err)
+	}
+	if _, _, err := st.GetUserPassword(ctx, "nobody"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("unknown user err = %v", err)
+	}
+	got, err := st.GetUserByUsername(ctx, "bob")
+	if err != nil || got.UserID != bob.UserID || got.TotalQuota != 50 || got.Nickname != "bob-nick" {
+		t.Fatalf("GetUserByUsername = %+v %v", got, err)
+	}
+	if n, err := st.CountUsersByUsername(ctx, "alice"); err != nil || n != 1 {
+		t.Errorf("CountUsersByUsername = %d %v", n, err)
+	}
+
+	users, total, err := st.ListUsers(ctx, 1, 1)
+	if err != nil || total != 2 || len(users) != 1 || users[0].UserID != bob.UserID {
+		t.Fatalf("ListUsers page 1 = %+v %d %v", users, total, err)
+	}
+	users, _, err = st.ListUsers(ctx, 2, 1)
+	if err != nil || len(users) != 1 || users[0].UserID != alice.UserID {
+		t.Fatalf("ListUsers page 2 = %+v %v", users, err)
+	}
+
+	if err := st.UpdateUserPassword(ctx, "alice", "new-hash"); err != nil {
+		t.Fatal(err)
+	}
+	if _, password, _ := st.GetUserPassword(ctx, "alice"); password != "new-hash" {
+		t.Errorf("password = %q", password)
+	}
+
+	if err := st.BumpUserTokenVersion(ctx, alice.UserID); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SetUserMustChangePassword(ctx, alice.UserID, true); err != nil {
+		t.Fatal(err)
+	}
+	state, err := st.GetUserAuthState(ctx, alice.UserID)
+	if err != nil || state.Role != "user" || state.TokenVersion != 1 || !state.MustChangePassword {
+		t.Fatalf("GetUserAuthState = %+v %v", state, err)
+	}
+	if got, _ := st.GetUserByID(ctx, alice.UserID); !got.MustChangePassword {
+		t.Error("GetUserByID lost must_change_password")
+	}
+
+	if ok, err := st.SetUserQuota(ctx, bob.UserID, 10); err != nil || !ok {
+		t.Fatalf("SetUserQuota = %v %v", ok, err)
+	}
+	if ok, _ := st.SetUserQuota(ctx, 9999, 10); ok {
+		t.Error("SetUserQuota on unknown user reported a hit")
+	}
+	if ok, err := st.DeleteUser(ctx, bob.UserID); err != nil || !ok {
+		t.Fatalf("DeleteUser = %v %v", ok, err)
+	}
+	if _, err := st.GetUserByID(ctx, bob.UserID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("deleted user err = %v", err)
+	}
+	if _, err := st.GetUserAuthState(ctx, bob.UserID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("deleted user auth state err = %v", err)
+	}
+}
+
+func testQuota(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	u := mustCreateUser(t, st, "alice", 100)
+
+	if ok, err := st.ReserveQuota(ctx, u.UserID, 60); err != nil || !ok {
+		t.Fatalf("ReserveQuota 60 = %v %v", ok, err)
+	}
+	if ok, _ := st.ReserveQuota(ctx, u.UserID, 50); ok {
+		t.Fatal("reservation beyond total quota succeeded")
+	}
+	if err := st.SettleQuota(ctx, u.UserID, 60, 25); err != nil {
+		t.Fatal(err)
+	}
+	total, used, reserved, err := st.GetUserQuotaBalance(ctx, u.UserID)
+	if err != nil || total != 100 || used != 25 || reserved != 0 {
+		t.Fatalf("balance = %d %d %d %v", total, used, reserved, err)
+	}
+	if ok, _ := st.ReserveQuota(ctx, u.UserID, 75); !ok {
+		t.Fatal("reservation within remaining quota failed")
+	}
+	// 释放预占（实际用量为 0）。
+	if err := st.SettleQuota(ctx, u.UserID, 75, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, used, reserved, _ := st.GetUserQuotaBalance(ctx, u.UserID); used != 25 || reserved != 0 {
+		t.Fatalf("after release used=%d reserved=%d", used, reserved)
+	}
+	// 实际用量超出预占时已用额度至多计到总额度，并发的两轮先后结算也不会透支。
+	for range 2 {
+		if ok, _ := st.ReserveQuota(ctx, u.UserID, 10); !ok {
+			t.Fatal("reservation within remaining quota failed")
+		}
+	}
+	if err := st.SettleQuota(ctx, u.UserID, 10, 70); err != nil {
+		t.Fatal(err)
+	}
+	if _, used, reserved, _ := st.GetUserQuotaBalance(ctx, u.UserID); used != 95 || reserved != 10 {
+		t.Fatalf("after first settle used=%d reserved=%d", used, reserved)
+	}
+	if err := st.SettleQuota(ctx, u.UserID, 10, 500); err != nil {
+		t.Fatal(err)
+	}
+	if _, used, reserved, _ := st.GetUserQuotaBalance(ctx, u.UserID); used != 100 || reserved != 0 {
+		t.Fatalf("after overspend used=%d reserved=%d", used, reserved)
+	}
+	// 设置的总额度低于已用额度时，已用额度被截断。
+	if _, err := st.SetUserQuota(ctx, u.UserID, 10); err != nil {
+		t.Fatal(err)
+	}
+	if total, used, _, _ := st.GetUserQuotaBalance(ctx, u.UserID); total != 10 || used != 10 {
+		t.Fatalf("after SetUserQuota total=%d used=%d", total, used)
+	}
+}
+
+func testPresets(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	u := mustCreateUser(t, st, "alice", 100)
+	if err := st.CreatePromptPreset(ctx, "tutor", "desc", "be a tutor"); err != nil {
+		t.Fatal(err)
+	}
+	presets, err := st.ListPromptPresets(ctx)
+	if err != nil || len(presets) != 1 {
+		t.Fatalf("ListPromptPresets = %+v %v", presets, err)
+	}
+	p, err := st.GetPromptPreset(ctx, presets[0].PromptPresetID)
+	if err != nil || p.Content != "be a tutor" {
+		t.Fatalf("GetPromptPreset = %+v %v", p, err)
+	}
+
+	conv, err := st.CreateConversation(ctx, u.UserID, "c", "fake",
+		sql.NullInt64{Int64: int64(p.PromptPresetID), Valid: true}, sql.NullString{String: "snapshot", Valid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prompt, err := st.GetConversationSystemPrompt(ctx, conv.ConversationID); err != nil || prompt != "be a tutor" {
+		t.Fatalf("system prompt = %q %v", prompt, err)
+	}
+	if ok, err := st.DeletePromptPreset(ctx, p.PromptPresetID); err != nil || !ok {
+		t.Fatalf("DeletePromptPreset = %v %v", ok, err)
+	}
+	if prompt, err := st.GetConversationSystemPrompt(ctx, conv.ConversationID); err != nil || prompt != "snapshot" {
+		t.Fatalf("system prompt after preset deletion = %q %v", prompt, err)
+	}
+	plain := mustCreateConversation(t, st, u.UserID, "plain")
+	if prompt, err := st.GetConversationSystemPrompt(ctx, plain.ConversationID); err != nil || prompt != "" {
+		t.Fatalf("system prompt without preset = %q %v", prompt, err)
+	}
+}
+
+func testConversationLifecycle(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	u := mustCreateUser(t, st, "alice", 100)
+	other := mustCreateUser(t, st, "mallory", 100)
+	conv := mustCreateConversation(t, st, u.UserID, "")
+	if conv.TitleManual || conv.Status != store.ConversationStatusActive {
+		t.Fatalf("new conversation = %+v", conv)
+	}
+
+	if _, err := st.GetConversation(ctx, conv.ConversationID, other.UserID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("other user's GetConversation err = %v", err)
+	}
+	if ok, _ := st.SetGeneratedTitle(ctx, conv.ConversationID, "auto"); !ok {
+		t.Error("SetGeneratedTitle on untitled conversation failed")
+	}
+	if ok, _ := st.RenameConversation(ctx, conv.ConversationID, u.UserID, "mine"); !ok {
+		t.Error("RenameConversation failed")
+	}
+	if ok, _ := st.SetGeneratedTitle(ctx, conv.ConversationID, "auto again"); ok {
+		t.Error("SetGeneratedTitle overwrote a manual title")
+	}
+	got, err := st.GetConversation(ctx, conv.ConversationID, u.UserID)
+	if err != nil || got.Title != "mine" || !got.TitleManual {
+		t.Fatalf("after rename = %+v %v", got, err)
+	}
+
+	listIDs := func(status string) []int {
+		t.Helper()
+		list, err := st.ListConversationsByUser(ctx, u.UserID, status)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]int, 0, len(list))
+		for _, c := range list {
+			ids = append(ids, c.ConversationID)
+		}
+		return ids
+	}
+	second := mustCreateConversation(t, st, u.UserID, "second")
+	if ids := listIDs(store.ConversationStatusActive); !slices.Equal(ids, []int{second.ConversationID, conv.ConversationID}) {
+		t.Fatalf("active = %v", ids)
+	}
+
+	if ok, _ := st.ArchiveConversation(ctx, conv.ConversationID, other.UserID); ok {
+		t.Error("archived another user's conversation")
+	}
+	if ok, _ := st.ArchiveConversation(ctx, conv.ConversationID, u.UserID); !ok {
+		t.Fatal("ArchiveConversation failed")
+	}
+	if ok, _ := st.ArchiveConversation(ctx, conv.ConversationID, u.UserID); ok {
+		t.Error("archived twice")
+	}
+	if got, err := st.GetConversation(ctx, conv.ConversationID, u.UserID); err != nil || got.Status != store.ConversationStatusArchived {
+		t.Fatalf("archived conversation = %+v %v", got, err)
+	}
+	if ids := listIDs(store.ConversationStatusArchived); !slices.Equal(ids, []int{conv.ConversationID}) {
+		t.Fatalf("archived = %v", ids)
+	}
+
+	if ok, _ := st.DeleteConversation(ctx, conv.ConversationID, u.UserID); !ok {
+		t.Fatal("DeleteConversation failed")
+	}
+	if ok, _ := st.DeleteConversation(ctx, conv.ConversationID, u.UserID); ok {
+		t.Error("deleted twice")
+	}
+	if _, err := st.GetConversation(ctx, conv.ConversationID, u.UserID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("deleted GetConversation err = %v", err)
+	}
+	if ok, _ := st.RenameConversation(ctx, conv.ConversationID, u.UserID, "x"); ok {
+		t.Error("renamed a deleted conversation")
+	}
+	deleted, err := st.ListConversationsByUser(ctx, u.UserID, store.ConversationStatusDeleted)
+	if err != nil || len(deleted) != 1 || deleted[0].DeletedAt == nil {
+		t.Fatalf("deleted list = %+v %v", deleted, err)
+	}
+	if since := time.Since(*deleted[0].DeletedAt); since < -time.Minute || since > time.Minute {
+		t.Errorf("deleted_at = %v, want about now", deleted[0].DeletedAt)
+	}
+
+	if ok, _ := st.RestoreConversation(ctx, conv.ConversationID, u.UserID); !ok {
+		t.Fatal("RestoreConversation failed")
+	}
+	if ok, _ := st.RestoreConversation(ctx, conv.ConversationID, u.UserID); ok {
+		t.Error("restored an active conversation")
+	}
+	restored, err := st.ListConversationsByUser(ctx, u.UserID, store.ConversationStatusActive)
+	if err != nil || len(restored) != 2 || restored[1].DeletedAt != nil {
+		t.Fatalf("active after restore = %+v %v", restored, err)
+	}
+	// 生成中的标题在会话删除后写回时不生效。
+	untitled := mustCreateConversation(t, st, u.UserID, "")
+	if ok, _ := st.DeleteConversation(ctx, untitled.ConversationID, u.UserID); !ok {
+		t.Fatal("DeleteConversation failed")
+	}
+	if ok, _ := st.SetGeneratedTitle(ctx, untitled.ConversationID, "auto"); ok {
+		t.Error("SetGeneratedTitle titled a deleted conversation")
+	}
+
+	uploadID, err := st.GetOrCreateUploadConversation(ctx, u.UserID, "fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := st.GetOrCreateUploadConversation(ctx, u.UserID, "fake"); again != uploadID {
+		t.Errorf("upload conversation %d then %d", uploadID, again)
+	}
+}
+
+func testMessageTree(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	u := mustCreateUser(t, st, "alice", 100)
+	other := mustCreateUser(t, st, "mallory", 100)
+	conv := mustCreateConversation(t, st, u.UserID, "c")
+
+	q := mustInsertMessage(t, st, conv.ConversationID, 0, store.SenderUser, "q")
+	a1 := mustInsertMessage(t, st, conv.ConversationID, q, store.SenderAssistant, "a1")
+	a2 := mustInsertMessage(t, st, conv.ConversationID, q, store.SenderAssistant, "a2")
+	if err := st.SetMessageStatus(ctx, a2, store.MessageStatusStopped); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SetActiveMessage(ctx, conv.ConversationID, a1); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, ids, err := st.ListAllMessages(ctx, u.UserID, conv.ConversationID)
+	if err != nil || !slices.Equal(ids, []int{q, a1, a2}) {
+		t.Fatalf("ListAllMessages ids = %v %v", ids, err)
+	}
+	if rows[0].ParentID != 0 || rows[1].ParentID != q || rows[2].ParentID != q {
+		t.Errorf("parents = %d %d %d", rows[0].ParentID, rows[1].ParentID, rows[2].ParentID)
+	}
+	if rows[1].Status != store.MessageStatusCompleted || rows[2].Status != store.MessageStatusStopped {
+		t.Errorf("statuses = %q %q", rows[1].Status, rows[2].Status)
+	}
+	if rows[0].PromptTokens != 3 || rows[0].CompletionTokens != 4 || rows[0].TokenTotal != 7 {
+		t.Errorf("tokens = %+v", rows[0])
+	}
+	if got, _ := st.GetConversation(ctx, conv.ConversationID, u.UserID); got.ActiveMessageID != a1 {
+		t.Errorf("active message = %d, want %d", got.ActiveMessageID, a1)
+	}
+
+	if content, err := st.GetMessageContent(ctx, u.UserID, a2); err != nil || content != "a2" {
+		t.Fatalf("GetMessageContent = %q %v", content, err)
+	}
+	if _, err := st.GetMessageContent(ctx, other.UserID, a2); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("other user's GetMessageContent err = %v", err)
+	}
+	if rows, _, _ := st.ListAllMessages(ctx, other.UserID, conv.ConversationID); len(rows) != 0 {
+		t.Errorf("other user sees %d messages", len(rows))
+	}
+
+	if _, err := st.DeleteConversation(ctx, conv.ConversationID, u.UserID); err != nil {
+		t.Fatal(err)
+	}
+	if rows, _, _ := st.ListAllMessages(ctx, u.UserID, conv.ConversationID); len(rows) != 0 {
+		t.Errorf("deleted conversation still lists %d messages", len(rows))
+	}
+	if _, err := st.GetMessageContent(ctx, u.UserID, a1); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("deleted conversation GetMessageContent err = %v", err)
+	}
+}
+
+func testAttachments(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	u := mustCreateUser(t, st, "alice", 100)
+	other := mustCreateUser(t, st, "mallory", 100)
+
+	uploadConv, err := st.GetOrCreateUploadConversation(ctx, u.UserID, "fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploadMsg, err := st.CreateUploadMessage(ctx, uploadConv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	duration := 1.5
+	attID, err := st.CreateAttachment(ctx, uploadMsg, "AUDIO", "audio/wav", store.StorageTypeLocal, "/uploads/a.wav", &duration)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if list, err := st.LoadAttachmentsByIDs(ctx, other.UserID, []int{attID}); err != nil || len(list) != 0 {
+		t.Fatalf("other user's LoadAttachmentsByIDs = %+v %v", list, err)
+	}
+	list, err := st.LoadAttachmentsByIDs(ctx, u.UserID, []int{attID})
+	if err != nil || len(list) != 1 || list[0].URLOrPath != "/uploads/a.wav" || list[0].DurationMS == nil || *list[0].DurationMS != 1.5 {
+		t.Fatalf("LoadAttachmentsByIDs = %+v %v", list, err)
+	}
+
+	conv := mustCreateConversation(t, st, u.UserID, "c")
+	msg := mustInsertMessage(t, st, conv.ConversationID, 0, store.SenderUser, "look")
+	if err := st.AttachFilesToMessage(ctx, other.UserID, msg, []int{attID}); err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := st.LoadAttachmentsMap(ctx, []int{msg}); len(m[msg]) != 0 {
+		t.Fatal("attached another user's file")
+	}
+	if err := st.AttachFilesToMessage(ctx, u.UserID, msg, []int{attID}); err != nil {
+		t.Fatal(err)
+	}
+
+	edited := mustInsertMessage(t, st, conv.ConversationID, 0, store.SenderUser, "look again")
+	if err := st.CopyAttachmentsToMessage(ctx, u.UserID, edited, []int{attID}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := st.LoadAttachmentsMap(ctx, []int{msg, edited})
+	if err != nil || len(m[msg]) != 1 || len(m[edited]) != 1 {
+		t.Fatalf("LoadAttachmentsMap = %+v %v", m, err)
+	}
+	if m[msg][0].AttachmentID != attID || m[edited][0].AttachmentID == attID || m[edited][0].URLOrPath != "/uploads/a.wav" {
+		t.Errorf("copy = %+v, original = %+v", m[edited][0], m[msg][0])
+	}
+	if n, err := st.CountAttachmentsByLocation(ctx, store.StorageTypeLocal, "/uploads/a.wav"); err != nil || n != 2 {
+		t.Errorf("CountAttachmentsByLocation = %d %v", n, err)
+	}
+	if m, err := st.LoadAttachmentsMap(ctx, nil); err != nil || len(m) != 0 {
+		t.Errorf("LoadAttachmentsMap(nil) = %v %v", m, err)
+	}
+}
+
+func testSummariesAndFork(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	u := mustCreateUser(t, st, "alice", 100)
+	conv := mustCreateConversation(t, st, u.UserID, "c")
+	q := mustInsertMessage(t, st, conv.ConversationID, 0, store.SenderUser, "q")
+	a1 := mustInsertMessage(t, st, conv.ConversationID, q, store.SenderAssistant, "a1")
+	a2 := mustInsertMessage(t, st, conv.ConversationID, q, store.SenderAssistant, "a2")
+
+	if _, err := st.GetLatestSummary(ctx, conv.ConversationID, []int{q, a1}); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("no summary err = %v", err)
+	}
+	s1, err := st.InsertSummary(ctx, conv.ConversationID, "covers a1", a1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.InsertSummary(ctx, conv.ConversationID, "covers a2", a2, 10); err != nil {
+		t.Fatal(err)
+	}
+	got, err := st.GetLatestSummary(ctx, conv.ConversationID, []int{q, a1})
+	if err != nil || got.SummaryID != s1 || got.Content != "covers a1" || got.CoveredMessageID != a1 {
+		t.Fatalf("branch summary = %+v %v", got, err)
+	}
+	if got, _ := st.GetLatestSummary(ctx, conv.ConversationID, []int{q, a2}); got.Content != "covers a2" {
+		t.Errorf("other branch summary = %+v", got)
+	}
+	if _, err := st.GetLatestSummary(ctx, conv.ConversationID, nil); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("empty branch err = %v", err)
+	}
+
+	fork, err := st.CreateForkedConversation(ctx, u.UserID, conv.ConversationID, a1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fork.ForkedFromConversationID != conv.ConversationID || fork.ForkedFromMessageID != a1 || fork.TitleManual || fork.LLMModel != "fake" {
+		t.Errorf("fork = %+v", fork)
+	}
+	other := mustCreateUser(t, st, "mallory", 100)
+	if _, err := st.CreateForkedConversation(ctx, other.UserID, conv.ConversationID, a1, "x"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("forking another user's conversation err = %v", err)
+	}
+}
+
+func testTransactions(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	u := mustCreateUser(t, st, "alice", 100)
+	conv := mustCreateConversation(t, st, u.UserID, "c")
+	boom := errors.New("boom")
+
+	committed := false
+	err := st.WithTx(ctx, func(ctx context.Context) error {
+		store.AfterCommit(ctx, func() { committed = true })
+		if _, err := st.InsertMessage(ctx, conv.ConversationID, 0, store.SenderUser, "TEXT", "rolled back", 0, 0); err != nil {
+			return err
+		}
+		if _, err := st.ReserveQuota(ctx, u.UserID, 40); err != nil {
+			return err
+		}
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("WithTx err = %v", err)
+	}
+	if committed {
+		t.Error("AfterCommit ran for a rolled back transaction")
+	}
+	if rows, _, _ := st.ListAllMessages(ctx, u.UserID, conv.ConversationID); len(rows) != 0 {
+		t.Errorf("rolled back message persisted: %+v", rows)
+	}
+	if _, _, reserved, _ := st.GetUserQuotaBalance(ctx, u.UserID); reserved != 0 {
+		t.Errorf("rolled back reservation persisted: %d", reserved)
+	}
+
+	err = st.WithTx(ctx, func(ctx context.Context) error {
+		store.AfterCommit(ctx, func() { committed = true })
+		// 嵌套调用复用外层事务。
+		return st.WithTx(ctx, func(ctx context.Context) error {
+			_, err := st.InsertMessage(ctx, conv.ConversationID, 0, store.SenderUser, "TEXT", "kept", 0, 0)
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !committed {
+		t.Error("AfterCommit did not run after commit")
+	}
+	if rows, _, _ := st.ListAllMessages(ctx, u.UserID, conv.ConversationID); len(rows) != 1 || rows[0].Content != "kept" {
+		t.Errorf("committed messages = %+v", rows)
+	}
+}
+
+func testRefreshTokens(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	u := mustCreateUser(t, st, "alice", 100)
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	first, err := st.CreateRefreshToken(ctx, u.UserID, "fam-1", "h1", expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.CreateRefreshToken(ctx, u.UserID, "fam-1", "h2", expires); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.CreateRefreshToken(ctx, u.UserID, "fam-2", "h3", expires); err != nil {
+		t.Fatal(err)
+	}
+
+	tok, err := st.GetRefreshTokenByHash(ctx, "h1")
+	if err != nil || tok.TokenID != first || tok.UserID != u.UserID || tok.FamilyID != "fam-1" || tok.Used || tok.Revoked {
+		t.Fatalf("GetRefreshTokenByHash = %+v %v", tok, err)
+	}
+	if !tok.ExpiresAt.Equal(expires) {
+		t.Errorf("expires_at = %v, want %v", tok.ExpiresAt, expires)
+	}
+	if _, err := st.GetRefreshTokenByHash(ctx, "missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("missing token err = %v", err)
+	}
+
+	if ok, _ := st.MarkRefreshTokenUsed(ctx, first); !ok {
+		t.Fatal("first MarkRefreshTokenUsed failed")
+	}
+	if ok, _ := st.MarkRefreshTokenUsed(ctx, first); ok {
+		t.Error("token marked used twice")
+	}
+	if err := st.RevokeRefreshTokenFamily(ctx, "fam-1"); err != nil {
+		t.Fatal(err)
+	}
+	if tok, _ := st.GetRefreshTokenByHash(ctx, "h2"); !tok.Revoked || tok.Used {
+		t.Errorf("family member after revoke = %+v", tok)
+	}
+	if tok, _ := st.GetRefreshTokenByHash(ctx, "h3"); tok.Revoked {
+		t.Error("revoking one family revoked another")
+	}
+
+	n, err := st.RevokeUserRefreshTokens(ctx, u.UserID)
+	if err != nil || n != 1 {
+		t.Fatalf("RevokeUserRefreshTokens = %d %v", n, err)
+	}
+	if tok, _ := st.GetRefreshTokenByHash(ctx, "h3"); !tok.Revoked {
+		t.Error("user token not revoked")
+	}
+}
+
+func testPasswordResetTokens(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	u := mustCreateUser(t, st, "alice", 100)
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	id, err := st.CreatePasswordResetToken(ctx, u.UserID, "r1", expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := st.GetPasswordResetTokenByHash(ctx, "r1")
+	if err != nil || tok.TokenID != id || tok.UserID != u.UserID || tok.Used || !tok.ExpiresAt.Equal(expires) {
+		t.Fatalf("GetPasswordResetTokenByHash = %+v %v", tok, err)
+	}
+	if ok, _ := st.MarkPasswordResetTokenUsed(ctx, id); !ok {
+		t.Fatal("MarkPasswordResetTokenUsed failed")
+	}
+	if ok, _ := st.MarkPasswordResetTokenUsed(ctx, id); ok {
+		t.Error("reset token used twice")
+	}
+
+	if _, err := st.CreatePasswordResetToken(ctx, u.UserID, "r2", expires); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.InvalidateUserPasswordResetTokens(ctx, u.UserID); err != nil {
+		t.Fatal(err)
+	}
+	if tok, _ := st.GetPasswordResetTokenByHash(ctx, "r2"); !tok.Used {
+		t.Error("InvalidateUserPasswordResetTokens left a usable token")
+	}
+}
+
+func testRetentionPurge(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	u := mustCreateUser(t, st, "alice", 100)
+	kept := mustCreateConversation(t, st, u.UserID, "kept")
+	gone := mustCreateConversation(t, st, u.UserID, "gone")
+
+	msg := mustInsertMessage(t, st, gone.ConversationID, 0, store.SenderUser, "bye")
+	keptMsg := mustInsertMessage(t, st, kept.ConversationID, 0, store.SenderUser, "hi")
+	attID, err := st.CreateAttachment(ctx, msg, "FILE", "text/plain", store.StorageTypeLocal, "/uploads/f.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.CopyAttachmentsToMessage(ctx, u.UserID, keptMsg, []int{attID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.InsertSummary(ctx, gone.ConversationID, "s", msg, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.DeleteConversation(ctx, gone.ConversationID, u.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	if ids, err := st.ListPurgeableConversations(ctx, time.Now().Add(-time.Hour), 10); err != nil || len(ids) != 0 {
+		t.Fatalf("purgeable before retention = %v %v", ids, err)
+	}
+	ids, err := st.ListPurgeableConversations(ctx, time.Now().Add(time.Hour), 10)
+	if err != nil || !slices.Equal(ids, []int{gone.ConversationID}) {
+		t.Fatalf("purgeable = %v %v", ids, err)
+	}
+
+	if _, err := st.PurgeConversation(ctx, kept.ConversationID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("purging an active conversation err = %v", err)
+	}
+	var purged []store.AttachmentInfo
+	err = st.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		purged, err = st.PurgeConversation(ctx, gone.ConversationID)
+		return err
+	})
+	if err != nil || len(purged) != 1 || purged[0].AttachmentID != attID {
+		t.Fatalf("PurgeConversation = %+v %v", purged, err)
+	}
+	if _, err := st.PurgeConversation(ctx, gone.ConversationID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("second purge err = %v", err)
+	}
+	if ok, _ := st.RestoreConversation(ctx, gone.ConversationID, u.UserID); ok {
+		t.Error("restored a purged conversation")
+	}
+	if n, _ := st.CountAttachmentsByLocation(ctx, store.StorageTypeLocal, "/uploads/f.txt"); n != 1 {
+		t.Errorf("remaining references = %d, want 1 (the copy)", n)
+	}
+	if _, err := st.GetLatestSummary(ctx, gone.ConversationID, []int{msg}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("summary survived purge: %v", err)
+	}
+	if rows, _, _ := st.ListAllMessages(ctx, u.UserID, kept.ConversationID); len(rows) != 1 {
+		t.Errorf("purge touched another conversation: %+v", rows)
+	}
+}
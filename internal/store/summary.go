@@ -0,0 +1,50 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+// GetLatestSummary 获取会话中覆盖到 messageIDs（通常为当前分支）之一的最新滚动摘要，不存在时返回 sql.ErrNoRows。
+func (s *SQLStore) GetLatestSummary(ctx context.Context, conversationID int, messageIDs []int) (ConversationSummary, error) {
+	dbx, err := s.conn(ctx)
+	if err != nil {
+		return ConversationSummary{}, err
+	}
+	inClause, args := BuildInClause(messageIDs)
+	if inClause == "" {
+		return ConversationSummary{}, sql.ErrNoRows
+	}
+	var summary ConversationSummary
+	row := dbx.QueryRowContext(ctx, `
+		SELECT summary_id, conversation_id, content, covered_message_id, token_total
+		FROM conversation_summaries
+		WHERE conversation_id = ? AND covered_message_id IN `+inClause+`
+		ORDER BY summary_id DESC
+		LIMIT 1
+	`, append([]any{conversationID}, args...)...)
+	if err := row.Scan(&summary.SummaryID, &summary.ConversationID, &summary.Content, &summary.CoveredMessageID, &summary.TokenTotal); err != nil {
+		return ConversationSummary{}, err
+	}
+	return summary, nil
+}
+
+// InsertSummary 写入会话摘要，coveredMessageID 为摘要覆盖到的最后一条消息ID。
+func (s *SQLStore) InsertSummary(ctx context.Context, conversationID int, content string, coveredMessageID, tokenTotal int) (int, error) {
+	dbx, err := s.conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	res, err := dbx.ExecContext(ctx, `
+		INSERT INTO conversation_summaries (conversation_id, content, covered_message_id, token_total)
+		VALUES (?, ?, ?, ?)
+	`, conversationID, content, coveredMessageID, tokenTotal)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
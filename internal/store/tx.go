@@ -0,0 +1,81 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// Querier 为 *sql.DB 与 *sql.Tx 的公共查询接口。
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type txKey struct{}
+
+type txState struct {
+	tx *sql.Tx
+}
+
+type hooksKey struct{}
+
+type txHooks struct {
+	afterCommit []func()
+}
+
+// BeginTxHooks 在 ctx 上挂载事务提交回调容器，返回新的 ctx 及提交成功后执行回调的函数，供 Transactor 实现使用。
+func BeginTxHooks(ctx context.Context) (context.Context, func()) {
+	hooks := &txHooks{}
+	return context.WithValue(ctx, hooksKey{}, hooks), func() {
+		for _, f := range hooks.afterCommit {
+			f()
+		}
+	}
+}
+
+// AfterCommit 登记事务提交后执行的回调；不在事务中时立即执行。
+func AfterCommit(ctx context.Context, f func()) {
+	if hooks, ok := ctx.Value(hooksKey{}).(*txHooks); ok {
+		hooks.afterCommit = append(hooks.afterCommit, f)
+		return
+	}
+	f()
+}
+
+// WithTx 在单个事务中执行 fn：fn 内通过传入的 ctx 调用的方法均使用同一个 *sql.Tx，
+// fn 返回错误时回滚，否则提交。已处于事务中时直接复用外层事务。
+func (s *SQLStore) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*txState); ok {
+		return fn(ctx)
+	}
+	if s.db == nil {
+		return errors.New("db not initialized")
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	ctx, runHooks := BeginTxHooks(ctx)
+	if err := fn(context.WithValue(ctx, txKey{}, &txState{tx: tx})); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	runHooks()
+	return nil
+}
+
+// conn 返回 ctx 中的事务，不在事务中时返回 s 持有的连接。
+func (s *SQLStore) conn(ctx context.Context) (Querier, error) {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return state.tx, nil
+	}
+	if s.db == nil {
+		return nil, errors.New("db not initialized")
+	}
+	return s.db, nil
+}
@@ -5,8 +5,8 @@ import (
 )
 
 // GetOrCreateUploadConversation 获取或创建上传用会话。
-func GetOrCreateUploadConversation(ctx context.Context, userID int, llmModel string) (int, error) {
-	dbx, err := GetDB()
+func (s *SQLStore) GetOrCreateUploadConversation(ctx context.Context, userID int, llmModel string) (int, error) {
+	dbx, err := s.conn(ctx)
 	if err != nil {
 		return 0, err
 	}
@@ -36,14 +36,14 @@ func GetOrCreateUploadConversation(ctx context.Context, userID int, llmModel str
 }
 
 // CreateUploadMessage 创建上传占位消息。
-func CreateUploadMessage(ctx context.Context, conversationID int) (int, error) {
-	dbx, err := GetDB()
+func (s *SQLStore) CreateUploadMessage(ctx context.Context, conversationID int) (int, error) {
+	dbx, err := s.conn(ctx)
 	if err != nil {
 		return 0, err
 	}
 	res, err := dbx.ExecContext(ctx, `
-		INSERT INTO messages (conversation_id, sender_type, content_type, content, token_total)
-		VALUES (?, ?, 'FILE', 'UPLOAD', 0)
+		INSERT INTO messages (conversation_id, sender_type, content_type, content, prompt_tokens, completion_tokens, token_total)
+		VALUES (?, ?, 'FILE', 'UPLOAD', 0, 0, 0)
 	`, conversationID, SenderSystem)
 	if err != nil {
 		return 0, err
@@ -56,8 +56,8 @@ func CreateUploadMessage(ctx context.Context, conversationID int) (int, error) {
 }
 
 // CreateAttachment 记录附件并返回 ID。
-func CreateAttachment(ctx context.Context, messageID int, attachmentType, mimeType, storageType, urlOrPath string, duration *float64) (int, error) {
-	dbx, err := GetDB()
+func (s *SQLStore) CreateAttachment(ctx context.Context, messageID int, attachmentType, mimeType, storageType, urlOrPath string, duration *float64) (int, error) {
+	dbx, err := s.conn(ctx)
 	if err != nil {
 		return 0, err
 	}